@@ -0,0 +1,42 @@
+package wl
+
+// Array is the value of an array argument, in the byte order of the
+// host.  The protocol does not say what the elements are; most arrays
+// hold uint32 values, such as the keys of wl_keyboard.enter or the
+// states of xdg_toplevel.configure.
+type Array []byte
+
+// Uint32s decodes the array as uint32 values.
+func (a Array) Uint32s() []uint32 {
+	ret := make([]uint32, len(a)/4)
+	for i := range ret {
+		ret[i] = order.Uint32(a[i*4:])
+	}
+	return ret
+}
+
+// Int32s decodes the array as int32 values.
+func (a Array) Int32s() []int32 {
+	ret := make([]int32, len(a)/4)
+	for i := range ret {
+		ret[i] = int32(order.Uint32(a[i*4:]))
+	}
+	return ret
+}
+
+// ArrayOf decodes a as values of an enum type, as in
+//
+//	for _, state := range wl.ArrayOf[xdg.ToplevelState](ev.States) {
+func ArrayOf[T ~uint32](a Array) []T {
+	ret := make([]T, len(a)/4)
+	for i := range ret {
+		ret[i] = T(order.Uint32(a[i*4:]))
+	}
+	return ret
+}
+
+// padding returns the number of bytes needed to align n bytes to 32
+// bits.
+func padding(n int) int {
+	return (4 - n&3) & 3
+}
@@ -0,0 +1,50 @@
+package wl
+
+import (
+	"testing"
+)
+
+func TestArrayEncoding(t *testing.T) {
+	for n := 0; n < 9; n++ {
+		a := make([]byte, n)
+		for i := range a {
+			a[i] = byte(i + 1)
+		}
+		r := NewRequest(1, 0, a, "ab", uint32(7))
+		if len(r.data)%4 != 0 {
+			t.Fatalf("%d bytes: message not padded", n)
+		}
+		ev := &Event{data: r.data}
+		if got := ev.Array(); string(got) != string(a) {
+			t.Errorf("%d bytes: got %v", n, got)
+		}
+		if s := ev.String(); s != "ab" {
+			t.Errorf("%d bytes: string after array decoded as %q", n, s)
+		}
+		if u := ev.Uint32(); u != 7 {
+			t.Errorf("%d bytes: uint after array decoded as %d", n, u)
+		}
+	}
+
+	r := NewRequest(1, 0, "abc")
+	if l := order.Uint32(r.data); l != 4 || len(r.data) != 8 {
+		t.Errorf("string encoded with length %d in %d bytes", l, len(r.data))
+	}
+
+	var a Array
+	for _, v := range []uint32{1, 4, 0xffffffff} {
+		var b [4]byte
+		order.PutUint32(b[:], v)
+		a = append(a, b[:]...)
+	}
+	if u := a.Uint32s(); len(u) != 3 || u[1] != 4 || u[2] != 0xffffffff {
+		t.Errorf("Uint32s: %v", u)
+	}
+	if i := a.Int32s(); i[2] != -1 {
+		t.Errorf("Int32s: %v", i)
+	}
+	type state uint32
+	if s := ArrayOf[state](a); s[1] != 4 {
+		t.Errorf("ArrayOf: %v", s)
+	}
+}
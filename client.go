@@ -1,9 +1,6 @@
-// package wl acts as a client for the wayland wayland protocol.
+// Code generated by wl-scanner from wayland.xml. DO NOT EDIT.
 
-// generated by wl-scanner
-// https://github.com/dkolbly/wl-scanner
-// from: https://cgit.freedesktop.org/wayland/wayland/plain/protocol/wayland.xml
-// on 2018-02-19 14:50:40 -0600
+// Package wl acts as a client for the wayland protocol.
 package wl
 
 import (
@@ -36,12 +33,28 @@ func (p *Display) RemoveErrorHandler(h DisplayErrorHandler) {
 
 	for i, e := range p.errorHandlers {
 		if e == h {
-			p.errorHandlers = append(p.errorHandlers[:i], p.errorHandlers[i+1:]...)
+			// copied, as Dispatch may be iterating over the old slice
+			p.errorHandlers = append(p.errorHandlers[:i:i], p.errorHandlers[i+1:]...)
 			break
 		}
 	}
 }
 
+type displayErrorFunc struct {
+	f func(DisplayErrorEvent)
+}
+
+func (h *displayErrorFunc) HandleDisplayError(ev DisplayErrorEvent) {
+	h.f(ev)
+}
+
+// OnError calls f for every error event, until cancel is called.
+func (p *Display) OnError(f func(DisplayErrorEvent)) (cancel func()) {
+	h := &displayErrorFunc{f}
+	p.AddErrorHandler(h)
+	return func() { p.RemoveErrorHandler(h) }
+}
+
 type DisplayDeleteIdEvent struct {
 	EventContext context.Context
 	Id           uint32
@@ -65,37 +78,55 @@ func (p *Display) RemoveDeleteIdHandler(h DisplayDeleteIdHandler) {
 
 	for i, e := range p.deleteIdHandlers {
 		if e == h {
-			p.deleteIdHandlers = append(p.deleteIdHandlers[:i], p.deleteIdHandlers[i+1:]...)
+			// copied, as Dispatch may be iterating over the old slice
+			p.deleteIdHandlers = append(p.deleteIdHandlers[:i:i], p.deleteIdHandlers[i+1:]...)
 			break
 		}
 	}
 }
 
+type displayDeleteIdFunc struct {
+	f func(DisplayDeleteIdEvent)
+}
+
+func (h *displayDeleteIdFunc) HandleDisplayDeleteId(ev DisplayDeleteIdEvent) {
+	h.f(ev)
+}
+
+// OnDeleteId calls f for every delete_id event, until cancel is called.
+func (p *Display) OnDeleteId(f func(DisplayDeleteIdEvent)) (cancel func()) {
+	h := &displayDeleteIdFunc{f}
+	p.AddDeleteIdHandler(h)
+	return func() { p.RemoveDeleteIdHandler(h) }
+}
+
 func (p *Display) Dispatch(ctx context.Context, event *Event) {
 	switch event.Opcode {
 	case 0:
-		if len(p.errorHandlers) > 0 {
+		p.mu.RLock()
+		handlers := p.errorHandlers
+		p.mu.RUnlock()
+		if len(handlers) > 0 {
 			ev := DisplayErrorEvent{}
 			ev.EventContext = ctx
 			ev.ObjectId = event.Proxy(p.Context())
 			ev.Code = event.Uint32()
 			ev.Message = event.String()
-			p.mu.RLock()
-			for _, h := range p.errorHandlers {
+			for _, h := range handlers {
 				h.HandleDisplayError(ev)
 			}
-			p.mu.RUnlock()
 		}
 	case 1:
-		if len(p.deleteIdHandlers) > 0 {
+		p.mu.RLock()
+		handlers := p.deleteIdHandlers
+		p.mu.RUnlock()
+		if len(handlers) > 0 {
 			ev := DisplayDeleteIdEvent{}
 			ev.EventContext = ctx
 			ev.Id = event.Uint32()
-			p.mu.RLock()
-			for _, h := range p.deleteIdHandlers {
+			for _, h := range handlers {
 				h.HandleDisplayDeleteId(ev)
 			}
-			p.mu.RUnlock()
 		}
 	}
 }
@@ -113,9 +144,32 @@ func NewDisplay(ctx *Context) *Display {
 	return ret
 }
 
+var displayInterface = &Interface{
+	Name:    "wl_display",
+	Version: 1,
+	New:     func() Proxy { return new(Display) },
+	Requests: []Message{
+		{Name: "sync", Signature: "n", Types: []string{"wl_callback"}},
+		{Name: "get_registry", Signature: "n", Types: []string{"wl_registry"}},
+	},
+	Events: []Message{
+		{Name: "error", Signature: "ous"},
+		{Name: "delete_id", Signature: "u"},
+	},
+	Errors: map[uint32]string{
+		DisplayErrorInvalidObject: "invalid_object",
+		DisplayErrorInvalidMethod: "invalid_method",
+		DisplayErrorNoMemory:      "no_memory",
+	},
+}
+
+// Interface returns the description of the wl_display interface.
+func (p *Display) Interface() *Interface {
+	return displayInterface
+}
+
 // Sync will asynchronous roundtrip.
 //
-//
 // The sync request asks the server to emit the 'done' event
 // on the returned wl_callback object.  Since requests are
 // handled in-order and events are delivered in-order, this can
@@ -127,15 +181,19 @@ func NewDisplay(ctx *Context) *Display {
 // attempt to use it after that point.
 //
 // The callback_data passed in the callback is the event serial.
-//
 func (p *Display) Sync() (*Callback, error) {
 	ret := NewCallback(p.Context())
-	return ret, p.Context().SendRequest(p, 0, Proxy(ret))
+	ret.SetQueue(p.Queue())
+	ret.SetVersion(p.Version())
+	if err := p.Context().SendRequest(p, 0, Proxy(ret)); err != nil {
+		p.Context().Discard(ret)
+		return nil, err
+	}
+	return ret, nil
 }
 
 // GetRegistry will get global registry object.
 //
-//
 // This request creates a registry object that allows the client
 // to list and bind the global objects available from the
 // compositor.
@@ -145,12 +203,18 @@ func (p *Display) Sync() (*Callback, error) {
 // client disconnects, not when the client side proxy is destroyed.
 // Therefore, clients should invoke get_registry as infrequently as
 // possible to avoid wasting memory.
-//
 func (p *Display) GetRegistry() (*Registry, error) {
 	ret := NewRegistry(p.Context())
-	return ret, p.Context().SendRequest(p, 1, Proxy(ret))
+	ret.SetQueue(p.Queue())
+	ret.SetVersion(p.Version())
+	if err := p.Context().SendRequest(p, 1, Proxy(ret)); err != nil {
+		p.Context().Discard(ret)
+		return nil, err
+	}
+	return ret, nil
 }
 
+// Error codes of wl_display, reported with wl_display.error.
 const (
 	DisplayErrorInvalidObject = 0
 	DisplayErrorInvalidMethod = 1
@@ -182,12 +246,28 @@ func (p *Registry) RemoveGlobalHandler(h RegistryGlobalHandler) {
 
 	for i, e := range p.globalHandlers {
 		if e == h {
-			p.globalHandlers = append(p.globalHandlers[:i], p.globalHandlers[i+1:]...)
+			// copied, as Dispatch may be iterating over the old slice
+			p.globalHandlers = append(p.globalHandlers[:i:i], p.globalHandlers[i+1:]...)
 			break
 		}
 	}
 }
 
+type registryGlobalFunc struct {
+	f func(RegistryGlobalEvent)
+}
+
+func (h *registryGlobalFunc) HandleRegistryGlobal(ev RegistryGlobalEvent) {
+	h.f(ev)
+}
+
+// OnGlobal calls f for every global event, until cancel is called.
+func (p *Registry) OnGlobal(f func(RegistryGlobalEvent)) (cancel func()) {
+	h := &registryGlobalFunc{f}
+	p.AddGlobalHandler(h)
+	return func() { p.RemoveGlobalHandler(h) }
+}
+
 type RegistryGlobalRemoveEvent struct {
 	EventContext context.Context
 	Name         uint32
@@ -211,37 +291,55 @@ func (p *Registry) RemoveGlobalRemoveHandler(h RegistryGlobalRemoveHandler) {
 
 	for i, e := range p.globalRemoveHandlers {
 		if e == h {
-			p.globalRemoveHandlers = append(p.globalRemoveHandlers[:i], p.globalRemoveHandlers[i+1:]...)
+			// copied, as Dispatch may be iterating over the old slice
+			p.globalRemoveHandlers = append(p.globalRemoveHandlers[:i:i], p.globalRemoveHandlers[i+1:]...)
 			break
 		}
 	}
 }
 
+type registryGlobalRemoveFunc struct {
+	f func(RegistryGlobalRemoveEvent)
+}
+
+func (h *registryGlobalRemoveFunc) HandleRegistryGlobalRemove(ev RegistryGlobalRemoveEvent) {
+	h.f(ev)
+}
+
+// OnGlobalRemove calls f for every global_remove event, until cancel is called.
+func (p *Registry) OnGlobalRemove(f func(RegistryGlobalRemoveEvent)) (cancel func()) {
+	h := &registryGlobalRemoveFunc{f}
+	p.AddGlobalRemoveHandler(h)
+	return func() { p.RemoveGlobalRemoveHandler(h) }
+}
+
 func (p *Registry) Dispatch(ctx context.Context, event *Event) {
 	switch event.Opcode {
 	case 0:
-		if len(p.globalHandlers) > 0 {
+		p.mu.RLock()
+		handlers := p.globalHandlers
+		p.mu.RUnlock()
+		if len(handlers) > 0 {
 			ev := RegistryGlobalEvent{}
 			ev.EventContext = ctx
 			ev.Name = event.Uint32()
 			ev.Interface = event.String()
 			ev.Version = event.Uint32()
-			p.mu.RLock()
-			for _, h := range p.globalHandlers {
+			for _, h := range handlers {
 				h.HandleRegistryGlobal(ev)
 			}
-			p.mu.RUnlock()
 		}
 	case 1:
-		if len(p.globalRemoveHandlers) > 0 {
+		p.mu.RLock()
+		handlers := p.globalRemoveHandlers
+		p.mu.RUnlock()
+		if len(handlers) > 0 {
 			ev := RegistryGlobalRemoveEvent{}
 			ev.EventContext = ctx
 			ev.Name = event.Uint32()
-			p.mu.RLock()
-			for _, h := range p.globalRemoveHandlers {
+			for _, h := range handlers {
 				h.HandleRegistryGlobalRemove(ev)
 			}
-			p.mu.RUnlock()
 		}
 	}
 }
@@ -259,14 +357,35 @@ func NewRegistry(ctx *Context) *Registry {
 	return ret
 }
 
+var registryInterface = &Interface{
+	Name:    "wl_registry",
+	Version: 1,
+	New:     func() Proxy { return new(Registry) },
+	Requests: []Message{
+		{Name: "bind", Signature: "usun"},
+	},
+	Events: []Message{
+		{Name: "global", Signature: "usu"},
+		{Name: "global_remove", Signature: "u"},
+	},
+}
+
+// Interface returns the description of the wl_registry interface.
+func (p *Registry) Interface() *Interface {
+	return registryInterface
+}
+
 // Bind will bind an object to the display.
 //
-//
 // Binds a new, client-created object to the server using the
 // specified name as the identifier.
-//
 func (p *Registry) Bind(name uint32, iface string, version uint32, id Proxy) error {
-	return p.Context().SendRequest(p, 0, name, iface, version, id)
+	id.SetVersion(version)
+	if err := p.Context().SendRequest(p, 0, name, iface, version, id); err != nil {
+		p.Context().Discard(id)
+		return err
+	}
+	return nil
 }
 
 type CallbackDoneEvent struct {
@@ -292,24 +411,41 @@ func (p *Callback) RemoveDoneHandler(h CallbackDoneHandler) {
 
 	for i, e := range p.doneHandlers {
 		if e == h {
-			p.doneHandlers = append(p.doneHandlers[:i], p.doneHandlers[i+1:]...)
+			// copied, as Dispatch may be iterating over the old slice
+			p.doneHandlers = append(p.doneHandlers[:i:i], p.doneHandlers[i+1:]...)
 			break
 		}
 	}
 }
 
+type callbackDoneFunc struct {
+	f func(CallbackDoneEvent)
+}
+
+func (h *callbackDoneFunc) HandleCallbackDone(ev CallbackDoneEvent) {
+	h.f(ev)
+}
+
+// OnDone calls f for every done event, until cancel is called.
+func (p *Callback) OnDone(f func(CallbackDoneEvent)) (cancel func()) {
+	h := &callbackDoneFunc{f}
+	p.AddDoneHandler(h)
+	return func() { p.RemoveDoneHandler(h) }
+}
+
 func (p *Callback) Dispatch(ctx context.Context, event *Event) {
 	switch event.Opcode {
 	case 0:
-		if len(p.doneHandlers) > 0 {
+		p.mu.RLock()
+		handlers := p.doneHandlers
+		p.mu.RUnlock()
+		if len(handlers) > 0 {
 			ev := CallbackDoneEvent{}
 			ev.EventContext = ctx
 			ev.CallbackData = event.Uint32()
-			p.mu.RLock()
-			for _, h := range p.doneHandlers {
+			for _, h := range handlers {
 				h.HandleCallbackDone(ev)
 			}
-			p.mu.RUnlock()
 		}
 	}
 }
@@ -326,6 +462,20 @@ func NewCallback(ctx *Context) *Callback {
 	return ret
 }
 
+var callbackInterface = &Interface{
+	Name:    "wl_callback",
+	Version: 1,
+	New:     func() Proxy { return new(Callback) },
+	Events: []Message{
+		{Name: "done", Signature: "u"},
+	},
+}
+
+// Interface returns the description of the wl_callback interface.
+func (p *Callback) Interface() *Interface {
+	return callbackInterface
+}
+
 type Compositor struct {
 	BaseProxy
 }
@@ -336,24 +486,47 @@ func NewCompositor(ctx *Context) *Compositor {
 	return ret
 }
 
+var compositorInterface = &Interface{
+	Name:    "wl_compositor",
+	Version: 4,
+	New:     func() Proxy { return new(Compositor) },
+	Requests: []Message{
+		{Name: "create_surface", Signature: "n", Types: []string{"wl_surface"}},
+		{Name: "create_region", Signature: "n", Types: []string{"wl_region"}},
+	},
+}
+
+// Interface returns the description of the wl_compositor interface.
+func (p *Compositor) Interface() *Interface {
+	return compositorInterface
+}
+
 // CreateSurface will create new surface.
 //
-//
 // Ask the compositor to create a new surface.
-//
 func (p *Compositor) CreateSurface() (*Surface, error) {
 	ret := NewSurface(p.Context())
-	return ret, p.Context().SendRequest(p, 0, Proxy(ret))
+	ret.SetQueue(p.Queue())
+	ret.SetVersion(p.Version())
+	if err := p.Context().SendRequest(p, 0, Proxy(ret)); err != nil {
+		p.Context().Discard(ret)
+		return nil, err
+	}
+	return ret, nil
 }
 
 // CreateRegion will create new region.
 //
-//
 // Ask the compositor to create a new region.
-//
 func (p *Compositor) CreateRegion() (*Region, error) {
 	ret := NewRegion(p.Context())
-	return ret, p.Context().SendRequest(p, 1, Proxy(ret))
+	ret.SetQueue(p.Queue())
+	ret.SetVersion(p.Version())
+	if err := p.Context().SendRequest(p, 1, Proxy(ret)); err != nil {
+		p.Context().Discard(ret)
+		return nil, err
+	}
+	return ret, nil
 }
 
 type ShmPool struct {
@@ -366,9 +539,24 @@ func NewShmPool(ctx *Context) *ShmPool {
 	return ret
 }
 
+var shmPoolInterface = &Interface{
+	Name:    "wl_shm_pool",
+	Version: 1,
+	New:     func() Proxy { return new(ShmPool) },
+	Requests: []Message{
+		{Name: "create_buffer", Signature: "niiiiu", Types: []string{"wl_buffer", "", "", "", "", ""}},
+		{Name: "destroy"},
+		{Name: "resize", Signature: "i"},
+	},
+}
+
+// Interface returns the description of the wl_shm_pool interface.
+func (p *ShmPool) Interface() *Interface {
+	return shmPoolInterface
+}
+
 // CreateBuffer will create a buffer from the pool.
 //
-//
 // Create a wl_buffer object from the pool.
 //
 // The buffer is created offset bytes into the pool and has
@@ -380,40 +568,45 @@ func NewShmPool(ctx *Context) *ShmPool {
 // A buffer will keep a reference to the pool it was created from
 // so it is valid to destroy the pool immediately after creating
 // a buffer from it.
-//
 func (p *ShmPool) CreateBuffer(offset int32, width int32, height int32, stride int32, format uint32) (*Buffer, error) {
 	ret := NewBuffer(p.Context())
-	return ret, p.Context().SendRequest(p, 0, Proxy(ret), offset, width, height, stride, format)
+	ret.SetQueue(p.Queue())
+	ret.SetVersion(p.Version())
+	if err := p.Context().SendRequest(p, 0, Proxy(ret), offset, width, height, stride, format); err != nil {
+		p.Context().Discard(ret)
+		return nil, err
+	}
+	return ret, nil
 }
 
 // Destroy will destroy the pool.
 //
-//
 // Destroy the shared memory pool.
 //
 // The mmapped memory will be released when all
 // buffers that have been created from this pool
 // are gone.
-//
 func (p *ShmPool) Destroy() error {
-	return p.Context().SendRequest(p, 1)
+	if err := p.Context().SendRequest(p, 1); err != nil {
+		return err
+	}
+	p.Context().Unregister(p)
+	return nil
 }
 
 // Resize will change the size of the pool mapping.
 //
-//
 // This request will cause the server to remap the backing memory
 // for the pool from the file descriptor passed when the pool was
 // created, but using the new size.  This request can only be
 // used to make the pool bigger.
-//
 func (p *ShmPool) Resize(size int32) error {
 	return p.Context().SendRequest(p, 2, size)
 }
 
 type ShmFormatEvent struct {
 	EventContext context.Context
-	Format       uint32
+	Format       ShmFormat
 }
 
 type ShmFormatHandler interface {
@@ -434,24 +627,41 @@ func (p *Shm) RemoveFormatHandler(h ShmFormatHandler) {
 
 	for i, e := range p.formatHandlers {
 		if e == h {
-			p.formatHandlers = append(p.formatHandlers[:i], p.formatHandlers[i+1:]...)
+			// copied, as Dispatch may be iterating over the old slice
+			p.formatHandlers = append(p.formatHandlers[:i:i], p.formatHandlers[i+1:]...)
 			break
 		}
 	}
 }
 
+type shmFormatFunc struct {
+	f func(ShmFormatEvent)
+}
+
+func (h *shmFormatFunc) HandleShmFormat(ev ShmFormatEvent) {
+	h.f(ev)
+}
+
+// OnFormat calls f for every format event, until cancel is called.
+func (p *Shm) OnFormat(f func(ShmFormatEvent)) (cancel func()) {
+	h := &shmFormatFunc{f}
+	p.AddFormatHandler(h)
+	return func() { p.RemoveFormatHandler(h) }
+}
+
 func (p *Shm) Dispatch(ctx context.Context, event *Event) {
 	switch event.Opcode {
 	case 0:
-		if len(p.formatHandlers) > 0 {
+		p.mu.RLock()
+		handlers := p.formatHandlers
+		p.mu.RUnlock()
+		if len(handlers) > 0 {
 			ev := ShmFormatEvent{}
 			ev.EventContext = ctx
-			ev.Format = event.Uint32()
-			p.mu.RLock()
-			for _, h := range p.formatHandlers {
+			ev.Format = ShmFormat(event.Uint32())
+			for _, h := range handlers {
 				h.HandleShmFormat(ev)
 			}
-			p.mu.RUnlock()
 		}
 	}
 }
@@ -468,26 +678,57 @@ func NewShm(ctx *Context) *Shm {
 	return ret
 }
 
+var shmInterface = &Interface{
+	Name:    "wl_shm",
+	Version: 1,
+	New:     func() Proxy { return new(Shm) },
+	Requests: []Message{
+		{Name: "create_pool", Signature: "nhi", Types: []string{"wl_shm_pool", "", ""}},
+	},
+	Events: []Message{
+		{Name: "format", Signature: "u"},
+	},
+	Errors: map[uint32]string{
+		ShmErrorInvalidFormat: "invalid_format",
+		ShmErrorInvalidStride: "invalid_stride",
+		ShmErrorInvalidFd:     "invalid_fd",
+	},
+}
+
+// Interface returns the description of the wl_shm interface.
+func (p *Shm) Interface() *Interface {
+	return shmInterface
+}
+
 // CreatePool will create a shm pool.
 //
-//
 // Create a new wl_shm_pool object.
 //
 // The pool can be used to create shared memory based buffer
 // objects.  The server will mmap size bytes of the passed file
 // descriptor, to use as backing memory for the pool.
-//
 func (p *Shm) CreatePool(fd uintptr, size int32) (*ShmPool, error) {
 	ret := NewShmPool(p.Context())
-	return ret, p.Context().SendRequest(p, 0, Proxy(ret), fd, size)
+	ret.SetQueue(p.Queue())
+	ret.SetVersion(p.Version())
+	if err := p.Context().SendRequest(p, 0, Proxy(ret), fd, size); err != nil {
+		p.Context().Discard(ret)
+		return nil, err
+	}
+	return ret, nil
 }
 
+// Error codes of wl_shm, reported with wl_display.error.
 const (
 	ShmErrorInvalidFormat = 0
 	ShmErrorInvalidStride = 1
 	ShmErrorInvalidFd     = 2
 )
 
+// ShmFormat is the format enum of wl_shm.  It is the type of the format
+// argument of wl_shm.format.
+type ShmFormat uint32
+
 const (
 	ShmFormatArgb8888    = 0
 	ShmFormatXrgb8888    = 1
@@ -571,23 +812,40 @@ func (p *Buffer) RemoveReleaseHandler(h BufferReleaseHandler) {
 
 	for i, e := range p.releaseHandlers {
 		if e == h {
-			p.releaseHandlers = append(p.releaseHandlers[:i], p.releaseHandlers[i+1:]...)
+			// copied, as Dispatch may be iterating over the old slice
+			p.releaseHandlers = append(p.releaseHandlers[:i:i], p.releaseHandlers[i+1:]...)
 			break
 		}
 	}
 }
 
+type bufferReleaseFunc struct {
+	f func(BufferReleaseEvent)
+}
+
+func (h *bufferReleaseFunc) HandleBufferRelease(ev BufferReleaseEvent) {
+	h.f(ev)
+}
+
+// OnRelease calls f for every release event, until cancel is called.
+func (p *Buffer) OnRelease(f func(BufferReleaseEvent)) (cancel func()) {
+	h := &bufferReleaseFunc{f}
+	p.AddReleaseHandler(h)
+	return func() { p.RemoveReleaseHandler(h) }
+}
+
 func (p *Buffer) Dispatch(ctx context.Context, event *Event) {
 	switch event.Opcode {
 	case 0:
-		if len(p.releaseHandlers) > 0 {
+		p.mu.RLock()
+		handlers := p.releaseHandlers
+		p.mu.RUnlock()
+		if len(handlers) > 0 {
 			ev := BufferReleaseEvent{}
 			ev.EventContext = ctx
-			p.mu.RLock()
-			for _, h := range p.releaseHandlers {
+			for _, h := range handlers {
 				h.HandleBufferRelease(ev)
 			}
-			p.mu.RUnlock()
 		}
 	}
 }
@@ -604,16 +862,35 @@ func NewBuffer(ctx *Context) *Buffer {
 	return ret
 }
 
+var bufferInterface = &Interface{
+	Name:    "wl_buffer",
+	Version: 1,
+	New:     func() Proxy { return new(Buffer) },
+	Requests: []Message{
+		{Name: "destroy"},
+	},
+	Events: []Message{
+		{Name: "release"},
+	},
+}
+
+// Interface returns the description of the wl_buffer interface.
+func (p *Buffer) Interface() *Interface {
+	return bufferInterface
+}
+
 // Destroy will destroy a buffer.
 //
-//
 // Destroy a buffer. If and how you need to release the backing
 // storage is defined by the buffer factory interface.
 //
 // For possible side-effects to a surface, see wl_surface.attach.
-//
 func (p *Buffer) Destroy() error {
-	return p.Context().SendRequest(p, 0)
+	if err := p.Context().SendRequest(p, 0); err != nil {
+		return err
+	}
+	p.Context().Unregister(p)
+	return nil
 }
 
 type DataOfferOfferEvent struct {
@@ -639,15 +916,31 @@ func (p *DataOffer) RemoveOfferHandler(h DataOfferOfferHandler) {
 
 	for i, e := range p.offerHandlers {
 		if e == h {
-			p.offerHandlers = append(p.offerHandlers[:i], p.offerHandlers[i+1:]...)
+			// copied, as Dispatch may be iterating over the old slice
+			p.offerHandlers = append(p.offerHandlers[:i:i], p.offerHandlers[i+1:]...)
 			break
 		}
 	}
 }
 
+type dataOfferOfferFunc struct {
+	f func(DataOfferOfferEvent)
+}
+
+func (h *dataOfferOfferFunc) HandleDataOfferOffer(ev DataOfferOfferEvent) {
+	h.f(ev)
+}
+
+// OnOffer calls f for every offer event, until cancel is called.
+func (p *DataOffer) OnOffer(f func(DataOfferOfferEvent)) (cancel func()) {
+	h := &dataOfferOfferFunc{f}
+	p.AddOfferHandler(h)
+	return func() { p.RemoveOfferHandler(h) }
+}
+
 type DataOfferSourceActionsEvent struct {
 	EventContext  context.Context
-	SourceActions uint32
+	SourceActions DataDeviceManagerDndAction
 }
 
 type DataOfferSourceActionsHandler interface {
@@ -668,15 +961,31 @@ func (p *DataOffer) RemoveSourceActionsHandler(h DataOfferSourceActionsHandler)
 
 	for i, e := range p.sourceActionsHandlers {
 		if e == h {
-			p.sourceActionsHandlers = append(p.sourceActionsHandlers[:i], p.sourceActionsHandlers[i+1:]...)
+			// copied, as Dispatch may be iterating over the old slice
+			p.sourceActionsHandlers = append(p.sourceActionsHandlers[:i:i], p.sourceActionsHandlers[i+1:]...)
 			break
 		}
 	}
 }
 
+type dataOfferSourceActionsFunc struct {
+	f func(DataOfferSourceActionsEvent)
+}
+
+func (h *dataOfferSourceActionsFunc) HandleDataOfferSourceActions(ev DataOfferSourceActionsEvent) {
+	h.f(ev)
+}
+
+// OnSourceActions calls f for every source_actions event, until cancel is called.
+func (p *DataOffer) OnSourceActions(f func(DataOfferSourceActionsEvent)) (cancel func()) {
+	h := &dataOfferSourceActionsFunc{f}
+	p.AddSourceActionsHandler(h)
+	return func() { p.RemoveSourceActionsHandler(h) }
+}
+
 type DataOfferActionEvent struct {
 	EventContext context.Context
-	DndAction    uint32
+	DndAction    DataDeviceManagerDndAction
 }
 
 type DataOfferActionHandler interface {
@@ -697,46 +1006,65 @@ func (p *DataOffer) RemoveActionHandler(h DataOfferActionHandler) {
 
 	for i, e := range p.actionHandlers {
 		if e == h {
-			p.actionHandlers = append(p.actionHandlers[:i], p.actionHandlers[i+1:]...)
+			// copied, as Dispatch may be iterating over the old slice
+			p.actionHandlers = append(p.actionHandlers[:i:i], p.actionHandlers[i+1:]...)
 			break
 		}
 	}
 }
 
+type dataOfferActionFunc struct {
+	f func(DataOfferActionEvent)
+}
+
+func (h *dataOfferActionFunc) HandleDataOfferAction(ev DataOfferActionEvent) {
+	h.f(ev)
+}
+
+// OnAction calls f for every action event, until cancel is called.
+func (p *DataOffer) OnAction(f func(DataOfferActionEvent)) (cancel func()) {
+	h := &dataOfferActionFunc{f}
+	p.AddActionHandler(h)
+	return func() { p.RemoveActionHandler(h) }
+}
+
 func (p *DataOffer) Dispatch(ctx context.Context, event *Event) {
 	switch event.Opcode {
 	case 0:
-		if len(p.offerHandlers) > 0 {
+		p.mu.RLock()
+		handlers := p.offerHandlers
+		p.mu.RUnlock()
+		if len(handlers) > 0 {
 			ev := DataOfferOfferEvent{}
 			ev.EventContext = ctx
 			ev.MimeType = event.String()
-			p.mu.RLock()
-			for _, h := range p.offerHandlers {
+			for _, h := range handlers {
 				h.HandleDataOfferOffer(ev)
 			}
-			p.mu.RUnlock()
 		}
 	case 1:
-		if len(p.sourceActionsHandlers) > 0 {
+		p.mu.RLock()
+		handlers := p.sourceActionsHandlers
+		p.mu.RUnlock()
+		if len(handlers) > 0 {
 			ev := DataOfferSourceActionsEvent{}
 			ev.EventContext = ctx
-			ev.SourceActions = event.Uint32()
-			p.mu.RLock()
-			for _, h := range p.sourceActionsHandlers {
+			ev.SourceActions = DataDeviceManagerDndAction(event.Uint32())
+			for _, h := range handlers {
 				h.HandleDataOfferSourceActions(ev)
 			}
-			p.mu.RUnlock()
 		}
 	case 2:
-		if len(p.actionHandlers) > 0 {
+		p.mu.RLock()
+		handlers := p.actionHandlers
+		p.mu.RUnlock()
+		if len(handlers) > 0 {
 			ev := DataOfferActionEvent{}
 			ev.EventContext = ctx
-			ev.DndAction = event.Uint32()
-			p.mu.RLock()
-			for _, h := range p.actionHandlers {
+			ev.DndAction = DataDeviceManagerDndAction(event.Uint32())
+			for _, h := range handlers {
 				h.HandleDataOfferAction(ev)
 			}
-			p.mu.RUnlock()
 		}
 	}
 }
@@ -755,9 +1083,37 @@ func NewDataOffer(ctx *Context) *DataOffer {
 	return ret
 }
 
+var dataOfferInterface = &Interface{
+	Name:    "wl_data_offer",
+	Version: 3,
+	New:     func() Proxy { return new(DataOffer) },
+	Requests: []Message{
+		{Name: "accept", Signature: "u?s"},
+		{Name: "receive", Signature: "sh"},
+		{Name: "destroy"},
+		{Name: "finish", Since: 3},
+		{Name: "set_actions", Signature: "uu", Since: 3},
+	},
+	Events: []Message{
+		{Name: "offer", Signature: "s"},
+		{Name: "source_actions", Signature: "u", Since: 3},
+		{Name: "action", Signature: "u", Since: 3},
+	},
+	Errors: map[uint32]string{
+		DataOfferErrorInvalidFinish:     "invalid_finish",
+		DataOfferErrorInvalidActionMask: "invalid_action_mask",
+		DataOfferErrorInvalidAction:     "invalid_action",
+		DataOfferErrorInvalidOffer:      "invalid_offer",
+	},
+}
+
+// Interface returns the description of the wl_data_offer interface.
+func (p *DataOffer) Interface() *Interface {
+	return dataOfferInterface
+}
+
 // Accept will accept one of the offered mime types.
 //
-//
 // Indicate that the client can accept the given mime type, or
 // NULL for not accepted.
 //
@@ -772,14 +1128,12 @@ func NewDataOffer(ctx *Context) *DataOffer {
 // will be cancelled and the corresponding drag source will receive
 // wl_data_source.cancelled. Clients may still use this event in
 // conjunction with wl_data_source.action for feedback.
-//
-func (p *DataOffer) Accept(serial uint32, mime_type string) error {
-	return p.Context().SendRequest(p, 0, serial, mime_type)
+func (p *DataOffer) Accept(serial uint32, mime_type *string) error {
+	return p.Context().SendRequest(p, 0, serial, NullableString(mime_type))
 }
 
 // Receive will request that the data is transferred.
 //
-//
 // To transfer the offered data, the client issues this request
 // and indicates the mime type it wants to receive.  The transfer
 // happens through the passed file descriptor (typically created
@@ -795,23 +1149,23 @@ func (p *DataOffer) Accept(serial uint32, mime_type string) error {
 // both before and after wl_data_device.drop. Drag-and-drop destination
 // clients may preemptively fetch data or examine it more closely to
 // determine acceptance.
-//
 func (p *DataOffer) Receive(mime_type string, fd uintptr) error {
 	return p.Context().SendRequest(p, 1, mime_type, fd)
 }
 
 // Destroy will destroy data offer.
 //
-//
 // Destroy the data offer.
-//
 func (p *DataOffer) Destroy() error {
-	return p.Context().SendRequest(p, 2)
+	if err := p.Context().SendRequest(p, 2); err != nil {
+		return err
+	}
+	p.Context().Unregister(p)
+	return nil
 }
 
 // Finish will the offer will no longer be used.
 //
-//
 // Notifies the compositor that the drag destination successfully
 // finished the drag-and-drop operation.
 //
@@ -823,14 +1177,12 @@ func (p *DataOffer) Destroy() error {
 // this request after a NULL mime type has been set in
 // wl_data_offer.accept or no action was received through
 // wl_data_offer.action.
-//
 func (p *DataOffer) Finish() error {
 	return p.Context().SendRequest(p, 3)
 }
 
 // SetActions will set the available/preferred drag-and-drop actions.
 //
-//
 // Sets the actions that the destination side client supports for
 // this operation. This request may trigger the emission of
 // wl_data_source.action and wl_data_offer.action events if the compositor
@@ -862,11 +1214,11 @@ func (p *DataOffer) Finish() error {
 //
 // This request can only be made on drag-and-drop offers, a protocol error
 // will be raised otherwise.
-//
 func (p *DataOffer) SetActions(dnd_actions uint32, preferred_action uint32) error {
 	return p.Context().SendRequest(p, 4, dnd_actions, preferred_action)
 }
 
+// Error codes of wl_data_offer, reported with wl_display.error.
 const (
 	DataOfferErrorInvalidFinish     = 0
 	DataOfferErrorInvalidActionMask = 1
@@ -897,12 +1249,28 @@ func (p *DataSource) RemoveTargetHandler(h DataSourceTargetHandler) {
 
 	for i, e := range p.targetHandlers {
 		if e == h {
-			p.targetHandlers = append(p.targetHandlers[:i], p.targetHandlers[i+1:]...)
+			// copied, as Dispatch may be iterating over the old slice
+			p.targetHandlers = append(p.targetHandlers[:i:i], p.targetHandlers[i+1:]...)
 			break
 		}
 	}
 }
 
+type dataSourceTargetFunc struct {
+	f func(DataSourceTargetEvent)
+}
+
+func (h *dataSourceTargetFunc) HandleDataSourceTarget(ev DataSourceTargetEvent) {
+	h.f(ev)
+}
+
+// OnTarget calls f for every target event, until cancel is called.
+func (p *DataSource) OnTarget(f func(DataSourceTargetEvent)) (cancel func()) {
+	h := &dataSourceTargetFunc{f}
+	p.AddTargetHandler(h)
+	return func() { p.RemoveTargetHandler(h) }
+}
+
 type DataSourceSendEvent struct {
 	EventContext context.Context
 	MimeType     string
@@ -927,12 +1295,28 @@ func (p *DataSource) RemoveSendHandler(h DataSourceSendHandler) {
 
 	for i, e := range p.sendHandlers {
 		if e == h {
-			p.sendHandlers = append(p.sendHandlers[:i], p.sendHandlers[i+1:]...)
+			// copied, as Dispatch may be iterating over the old slice
+			p.sendHandlers = append(p.sendHandlers[:i:i], p.sendHandlers[i+1:]...)
 			break
 		}
 	}
 }
 
+type dataSourceSendFunc struct {
+	f func(DataSourceSendEvent)
+}
+
+func (h *dataSourceSendFunc) HandleDataSourceSend(ev DataSourceSendEvent) {
+	h.f(ev)
+}
+
+// OnSend calls f for every send event, until cancel is called.
+func (p *DataSource) OnSend(f func(DataSourceSendEvent)) (cancel func()) {
+	h := &dataSourceSendFunc{f}
+	p.AddSendHandler(h)
+	return func() { p.RemoveSendHandler(h) }
+}
+
 type DataSourceCancelledEvent struct {
 	EventContext context.Context
 }
@@ -955,12 +1339,28 @@ func (p *DataSource) RemoveCancelledHandler(h DataSourceCancelledHandler) {
 
 	for i, e := range p.cancelledHandlers {
 		if e == h {
-			p.cancelledHandlers = append(p.cancelledHandlers[:i], p.cancelledHandlers[i+1:]...)
+			// copied, as Dispatch may be iterating over the old slice
+			p.cancelledHandlers = append(p.cancelledHandlers[:i:i], p.cancelledHandlers[i+1:]...)
 			break
 		}
 	}
 }
 
+type dataSourceCancelledFunc struct {
+	f func(DataSourceCancelledEvent)
+}
+
+func (h *dataSourceCancelledFunc) HandleDataSourceCancelled(ev DataSourceCancelledEvent) {
+	h.f(ev)
+}
+
+// OnCancelled calls f for every cancelled event, until cancel is called.
+func (p *DataSource) OnCancelled(f func(DataSourceCancelledEvent)) (cancel func()) {
+	h := &dataSourceCancelledFunc{f}
+	p.AddCancelledHandler(h)
+	return func() { p.RemoveCancelledHandler(h) }
+}
+
 type DataSourceDndDropPerformedEvent struct {
 	EventContext context.Context
 }
@@ -983,12 +1383,28 @@ func (p *DataSource) RemoveDndDropPerformedHandler(h DataSourceDndDropPerformedH
 
 	for i, e := range p.dndDropPerformedHandlers {
 		if e == h {
-			p.dndDropPerformedHandlers = append(p.dndDropPerformedHandlers[:i], p.dndDropPerformedHandlers[i+1:]...)
+			// copied, as Dispatch may be iterating over the old slice
+			p.dndDropPerformedHandlers = append(p.dndDropPerformedHandlers[:i:i], p.dndDropPerformedHandlers[i+1:]...)
 			break
 		}
 	}
 }
 
+type dataSourceDndDropPerformedFunc struct {
+	f func(DataSourceDndDropPerformedEvent)
+}
+
+func (h *dataSourceDndDropPerformedFunc) HandleDataSourceDndDropPerformed(ev DataSourceDndDropPerformedEvent) {
+	h.f(ev)
+}
+
+// OnDndDropPerformed calls f for every dnd_drop_performed event, until cancel is called.
+func (p *DataSource) OnDndDropPerformed(f func(DataSourceDndDropPerformedEvent)) (cancel func()) {
+	h := &dataSourceDndDropPerformedFunc{f}
+	p.AddDndDropPerformedHandler(h)
+	return func() { p.RemoveDndDropPerformedHandler(h) }
+}
+
 type DataSourceDndFinishedEvent struct {
 	EventContext context.Context
 }
@@ -1011,15 +1427,31 @@ func (p *DataSource) RemoveDndFinishedHandler(h DataSourceDndFinishedHandler) {
 
 	for i, e := range p.dndFinishedHandlers {
 		if e == h {
-			p.dndFinishedHandlers = append(p.dndFinishedHandlers[:i], p.dndFinishedHandlers[i+1:]...)
+			// copied, as Dispatch may be iterating over the old slice
+			p.dndFinishedHandlers = append(p.dndFinishedHandlers[:i:i], p.dndFinishedHandlers[i+1:]...)
 			break
 		}
 	}
 }
 
+type dataSourceDndFinishedFunc struct {
+	f func(DataSourceDndFinishedEvent)
+}
+
+func (h *dataSourceDndFinishedFunc) HandleDataSourceDndFinished(ev DataSourceDndFinishedEvent) {
+	h.f(ev)
+}
+
+// OnDndFinished calls f for every dnd_finished event, until cancel is called.
+func (p *DataSource) OnDndFinished(f func(DataSourceDndFinishedEvent)) (cancel func()) {
+	h := &dataSourceDndFinishedFunc{f}
+	p.AddDndFinishedHandler(h)
+	return func() { p.RemoveDndFinishedHandler(h) }
+}
+
 type DataSourceActionEvent struct {
 	EventContext context.Context
-	DndAction    uint32
+	DndAction    DataDeviceManagerDndAction
 }
 
 type DataSourceActionHandler interface {
@@ -1040,77 +1472,99 @@ func (p *DataSource) RemoveActionHandler(h DataSourceActionHandler) {
 
 	for i, e := range p.actionHandlers {
 		if e == h {
-			p.actionHandlers = append(p.actionHandlers[:i], p.actionHandlers[i+1:]...)
+			// copied, as Dispatch may be iterating over the old slice
+			p.actionHandlers = append(p.actionHandlers[:i:i], p.actionHandlers[i+1:]...)
 			break
 		}
 	}
 }
 
+type dataSourceActionFunc struct {
+	f func(DataSourceActionEvent)
+}
+
+func (h *dataSourceActionFunc) HandleDataSourceAction(ev DataSourceActionEvent) {
+	h.f(ev)
+}
+
+// OnAction calls f for every action event, until cancel is called.
+func (p *DataSource) OnAction(f func(DataSourceActionEvent)) (cancel func()) {
+	h := &dataSourceActionFunc{f}
+	p.AddActionHandler(h)
+	return func() { p.RemoveActionHandler(h) }
+}
+
 func (p *DataSource) Dispatch(ctx context.Context, event *Event) {
 	switch event.Opcode {
 	case 0:
-		if len(p.targetHandlers) > 0 {
+		p.mu.RLock()
+		handlers := p.targetHandlers
+		p.mu.RUnlock()
+		if len(handlers) > 0 {
 			ev := DataSourceTargetEvent{}
 			ev.EventContext = ctx
 			ev.MimeType = event.String()
-			p.mu.RLock()
-			for _, h := range p.targetHandlers {
+			for _, h := range handlers {
 				h.HandleDataSourceTarget(ev)
 			}
-			p.mu.RUnlock()
 		}
 	case 1:
-		if len(p.sendHandlers) > 0 {
+		p.mu.RLock()
+		handlers := p.sendHandlers
+		p.mu.RUnlock()
+		if len(handlers) > 0 {
 			ev := DataSourceSendEvent{}
 			ev.EventContext = ctx
 			ev.MimeType = event.String()
 			ev.Fd = event.FD()
-			p.mu.RLock()
-			for _, h := range p.sendHandlers {
+			for _, h := range handlers {
 				h.HandleDataSourceSend(ev)
 			}
-			p.mu.RUnlock()
 		}
 	case 2:
-		if len(p.cancelledHandlers) > 0 {
+		p.mu.RLock()
+		handlers := p.cancelledHandlers
+		p.mu.RUnlock()
+		if len(handlers) > 0 {
 			ev := DataSourceCancelledEvent{}
 			ev.EventContext = ctx
-			p.mu.RLock()
-			for _, h := range p.cancelledHandlers {
+			for _, h := range handlers {
 				h.HandleDataSourceCancelled(ev)
 			}
-			p.mu.RUnlock()
 		}
 	case 3:
-		if len(p.dndDropPerformedHandlers) > 0 {
+		p.mu.RLock()
+		handlers := p.dndDropPerformedHandlers
+		p.mu.RUnlock()
+		if len(handlers) > 0 {
 			ev := DataSourceDndDropPerformedEvent{}
 			ev.EventContext = ctx
-			p.mu.RLock()
-			for _, h := range p.dndDropPerformedHandlers {
+			for _, h := range handlers {
 				h.HandleDataSourceDndDropPerformed(ev)
 			}
-			p.mu.RUnlock()
 		}
 	case 4:
-		if len(p.dndFinishedHandlers) > 0 {
+		p.mu.RLock()
+		handlers := p.dndFinishedHandlers
+		p.mu.RUnlock()
+		if len(handlers) > 0 {
 			ev := DataSourceDndFinishedEvent{}
 			ev.EventContext = ctx
-			p.mu.RLock()
-			for _, h := range p.dndFinishedHandlers {
+			for _, h := range handlers {
 				h.HandleDataSourceDndFinished(ev)
 			}
-			p.mu.RUnlock()
 		}
 	case 5:
-		if len(p.actionHandlers) > 0 {
+		p.mu.RLock()
+		handlers := p.actionHandlers
+		p.mu.RUnlock()
+		if len(handlers) > 0 {
 			ev := DataSourceActionEvent{}
 			ev.EventContext = ctx
-			ev.DndAction = event.Uint32()
-			p.mu.RLock()
-			for _, h := range p.actionHandlers {
+			ev.DndAction = DataDeviceManagerDndAction(event.Uint32())
+			for _, h := range handlers {
 				h.HandleDataSourceAction(ev)
 			}
-			p.mu.RUnlock()
 		}
 	}
 }
@@ -1132,29 +1586,56 @@ func NewDataSource(ctx *Context) *DataSource {
 	return ret
 }
 
+var dataSourceInterface = &Interface{
+	Name:    "wl_data_source",
+	Version: 3,
+	New:     func() Proxy { return new(DataSource) },
+	Requests: []Message{
+		{Name: "offer", Signature: "s"},
+		{Name: "destroy"},
+		{Name: "set_actions", Signature: "u", Since: 3},
+	},
+	Events: []Message{
+		{Name: "target", Signature: "?s"},
+		{Name: "send", Signature: "sh"},
+		{Name: "cancelled"},
+		{Name: "dnd_drop_performed", Since: 3},
+		{Name: "dnd_finished", Since: 3},
+		{Name: "action", Signature: "u", Since: 3},
+	},
+	Errors: map[uint32]string{
+		DataSourceErrorInvalidActionMask: "invalid_action_mask",
+		DataSourceErrorInvalidSource:     "invalid_source",
+	},
+}
+
+// Interface returns the description of the wl_data_source interface.
+func (p *DataSource) Interface() *Interface {
+	return dataSourceInterface
+}
+
 // Offer will add an offered mime type.
 //
-//
 // This request adds a mime type to the set of mime types
 // advertised to targets.  Can be called several times to offer
 // multiple types.
-//
 func (p *DataSource) Offer(mime_type string) error {
 	return p.Context().SendRequest(p, 0, mime_type)
 }
 
 // Destroy will destroy the data source.
 //
-//
 // Destroy the data source.
-//
 func (p *DataSource) Destroy() error {
-	return p.Context().SendRequest(p, 1)
+	if err := p.Context().SendRequest(p, 1); err != nil {
+		return err
+	}
+	p.Context().Unregister(p)
+	return nil
 }
 
 // SetActions will set the available drag-and-drop actions.
 //
-//
 // Sets the actions that the source side client supports for this
 // operation. This request may trigger wl_data_source.action and
 // wl_data_offer.action events if the compositor needs to change the
@@ -1168,11 +1649,11 @@ func (p *DataSource) Destroy() error {
 // used in drag-and-drop, so it must be performed before
 // wl_data_device.start_drag. Attempting to use the source other than
 // for drag-and-drop will raise a protocol error.
-//
 func (p *DataSource) SetActions(dnd_actions uint32) error {
 	return p.Context().SendRequest(p, 2, dnd_actions)
 }
 
+// Error codes of wl_data_source, reported with wl_display.error.
 const (
 	DataSourceErrorInvalidActionMask = 0
 	DataSourceErrorInvalidSource     = 1
@@ -1201,18 +1682,34 @@ func (p *DataDevice) RemoveDataOfferHandler(h DataDeviceDataOfferHandler) {
 
 	for i, e := range p.dataOfferHandlers {
 		if e == h {
-			p.dataOfferHandlers = append(p.dataOfferHandlers[:i], p.dataOfferHandlers[i+1:]...)
+			// copied, as Dispatch may be iterating over the old slice
+			p.dataOfferHandlers = append(p.dataOfferHandlers[:i:i], p.dataOfferHandlers[i+1:]...)
 			break
 		}
 	}
 }
 
+type dataDeviceDataOfferFunc struct {
+	f func(DataDeviceDataOfferEvent)
+}
+
+func (h *dataDeviceDataOfferFunc) HandleDataDeviceDataOffer(ev DataDeviceDataOfferEvent) {
+	h.f(ev)
+}
+
+// OnDataOffer calls f for every data_offer event, until cancel is called.
+func (p *DataDevice) OnDataOffer(f func(DataDeviceDataOfferEvent)) (cancel func()) {
+	h := &dataDeviceDataOfferFunc{f}
+	p.AddDataOfferHandler(h)
+	return func() { p.RemoveDataOfferHandler(h) }
+}
+
 type DataDeviceEnterEvent struct {
 	EventContext context.Context
 	Serial       uint32
 	Surface      *Surface
-	X            float32
-	Y            float32
+	X            Fixed
+	Y            Fixed
 	Id           *DataOffer
 }
 
@@ -1234,12 +1731,28 @@ func (p *DataDevice) RemoveEnterHandler(h DataDeviceEnterHandler) {
 
 	for i, e := range p.enterHandlers {
 		if e == h {
-			p.enterHandlers = append(p.enterHandlers[:i], p.enterHandlers[i+1:]...)
+			// copied, as Dispatch may be iterating over the old slice
+			p.enterHandlers = append(p.enterHandlers[:i:i], p.enterHandlers[i+1:]...)
 			break
 		}
 	}
 }
 
+type dataDeviceEnterFunc struct {
+	f func(DataDeviceEnterEvent)
+}
+
+func (h *dataDeviceEnterFunc) HandleDataDeviceEnter(ev DataDeviceEnterEvent) {
+	h.f(ev)
+}
+
+// OnEnter calls f for every enter event, until cancel is called.
+func (p *DataDevice) OnEnter(f func(DataDeviceEnterEvent)) (cancel func()) {
+	h := &dataDeviceEnterFunc{f}
+	p.AddEnterHandler(h)
+	return func() { p.RemoveEnterHandler(h) }
+}
+
 type DataDeviceLeaveEvent struct {
 	EventContext context.Context
 }
@@ -1262,17 +1775,33 @@ func (p *DataDevice) RemoveLeaveHandler(h DataDeviceLeaveHandler) {
 
 	for i, e := range p.leaveHandlers {
 		if e == h {
-			p.leaveHandlers = append(p.leaveHandlers[:i], p.leaveHandlers[i+1:]...)
+			// copied, as Dispatch may be iterating over the old slice
+			p.leaveHandlers = append(p.leaveHandlers[:i:i], p.leaveHandlers[i+1:]...)
 			break
 		}
 	}
 }
 
+type dataDeviceLeaveFunc struct {
+	f func(DataDeviceLeaveEvent)
+}
+
+func (h *dataDeviceLeaveFunc) HandleDataDeviceLeave(ev DataDeviceLeaveEvent) {
+	h.f(ev)
+}
+
+// OnLeave calls f for every leave event, until cancel is called.
+func (p *DataDevice) OnLeave(f func(DataDeviceLeaveEvent)) (cancel func()) {
+	h := &dataDeviceLeaveFunc{f}
+	p.AddLeaveHandler(h)
+	return func() { p.RemoveLeaveHandler(h) }
+}
+
 type DataDeviceMotionEvent struct {
 	EventContext context.Context
 	Time         uint32
-	X            float32
-	Y            float32
+	X            Fixed
+	Y            Fixed
 }
 
 type DataDeviceMotionHandler interface {
@@ -1293,12 +1822,28 @@ func (p *DataDevice) RemoveMotionHandler(h DataDeviceMotionHandler) {
 
 	for i, e := range p.motionHandlers {
 		if e == h {
-			p.motionHandlers = append(p.motionHandlers[:i], p.motionHandlers[i+1:]...)
+			// copied, as Dispatch may be iterating over the old slice
+			p.motionHandlers = append(p.motionHandlers[:i:i], p.motionHandlers[i+1:]...)
 			break
 		}
 	}
 }
 
+type dataDeviceMotionFunc struct {
+	f func(DataDeviceMotionEvent)
+}
+
+func (h *dataDeviceMotionFunc) HandleDataDeviceMotion(ev DataDeviceMotionEvent) {
+	h.f(ev)
+}
+
+// OnMotion calls f for every motion event, until cancel is called.
+func (p *DataDevice) OnMotion(f func(DataDeviceMotionEvent)) (cancel func()) {
+	h := &dataDeviceMotionFunc{f}
+	p.AddMotionHandler(h)
+	return func() { p.RemoveMotionHandler(h) }
+}
+
 type DataDeviceDropEvent struct {
 	EventContext context.Context
 }
@@ -1321,12 +1866,28 @@ func (p *DataDevice) RemoveDropHandler(h DataDeviceDropHandler) {
 
 	for i, e := range p.dropHandlers {
 		if e == h {
-			p.dropHandlers = append(p.dropHandlers[:i], p.dropHandlers[i+1:]...)
+			// copied, as Dispatch may be iterating over the old slice
+			p.dropHandlers = append(p.dropHandlers[:i:i], p.dropHandlers[i+1:]...)
 			break
 		}
 	}
 }
 
+type dataDeviceDropFunc struct {
+	f func(DataDeviceDropEvent)
+}
+
+func (h *dataDeviceDropFunc) HandleDataDeviceDrop(ev DataDeviceDropEvent) {
+	h.f(ev)
+}
+
+// OnDrop calls f for every drop event, until cancel is called.
+func (p *DataDevice) OnDrop(f func(DataDeviceDropEvent)) (cancel func()) {
+	h := &dataDeviceDropFunc{f}
+	p.AddDropHandler(h)
+	return func() { p.RemoveDropHandler(h) }
+}
+
 type DataDeviceSelectionEvent struct {
 	EventContext context.Context
 	Id           *DataOffer
@@ -1350,83 +1911,105 @@ func (p *DataDevice) RemoveSelectionHandler(h DataDeviceSelectionHandler) {
 
 	for i, e := range p.selectionHandlers {
 		if e == h {
-			p.selectionHandlers = append(p.selectionHandlers[:i], p.selectionHandlers[i+1:]...)
+			// copied, as Dispatch may be iterating over the old slice
+			p.selectionHandlers = append(p.selectionHandlers[:i:i], p.selectionHandlers[i+1:]...)
 			break
 		}
 	}
 }
 
+type dataDeviceSelectionFunc struct {
+	f func(DataDeviceSelectionEvent)
+}
+
+func (h *dataDeviceSelectionFunc) HandleDataDeviceSelection(ev DataDeviceSelectionEvent) {
+	h.f(ev)
+}
+
+// OnSelection calls f for every selection event, until cancel is called.
+func (p *DataDevice) OnSelection(f func(DataDeviceSelectionEvent)) (cancel func()) {
+	h := &dataDeviceSelectionFunc{f}
+	p.AddSelectionHandler(h)
+	return func() { p.RemoveSelectionHandler(h) }
+}
+
 func (p *DataDevice) Dispatch(ctx context.Context, event *Event) {
 	switch event.Opcode {
 	case 0:
-		if len(p.dataOfferHandlers) > 0 {
+		p.mu.RLock()
+		handlers := p.dataOfferHandlers
+		p.mu.RUnlock()
+		if len(handlers) > 0 {
 			ev := DataDeviceDataOfferEvent{}
 			ev.EventContext = ctx
-			ev.Id = event.Proxy(p.Context()).(*DataOffer)
-			p.mu.RLock()
-			for _, h := range p.dataOfferHandlers {
+			ev.Id, _ = event.Proxy(p.Context()).(*DataOffer)
+			for _, h := range handlers {
 				h.HandleDataDeviceDataOffer(ev)
 			}
-			p.mu.RUnlock()
 		}
 	case 1:
-		if len(p.enterHandlers) > 0 {
+		p.mu.RLock()
+		handlers := p.enterHandlers
+		p.mu.RUnlock()
+		if len(handlers) > 0 {
 			ev := DataDeviceEnterEvent{}
 			ev.EventContext = ctx
 			ev.Serial = event.Uint32()
-			ev.Surface = event.Proxy(p.Context()).(*Surface)
-			ev.X = event.Float32()
-			ev.Y = event.Float32()
-			ev.Id = event.Proxy(p.Context()).(*DataOffer)
-			p.mu.RLock()
-			for _, h := range p.enterHandlers {
+			ev.Surface, _ = event.Proxy(p.Context()).(*Surface)
+			ev.X = event.Fixed()
+			ev.Y = event.Fixed()
+			ev.Id, _ = event.Proxy(p.Context()).(*DataOffer)
+			for _, h := range handlers {
 				h.HandleDataDeviceEnter(ev)
 			}
-			p.mu.RUnlock()
 		}
 	case 2:
-		if len(p.leaveHandlers) > 0 {
+		p.mu.RLock()
+		handlers := p.leaveHandlers
+		p.mu.RUnlock()
+		if len(handlers) > 0 {
 			ev := DataDeviceLeaveEvent{}
 			ev.EventContext = ctx
-			p.mu.RLock()
-			for _, h := range p.leaveHandlers {
+			for _, h := range handlers {
 				h.HandleDataDeviceLeave(ev)
 			}
-			p.mu.RUnlock()
 		}
 	case 3:
-		if len(p.motionHandlers) > 0 {
+		p.mu.RLock()
+		handlers := p.motionHandlers
+		p.mu.RUnlock()
+		if len(handlers) > 0 {
 			ev := DataDeviceMotionEvent{}
 			ev.EventContext = ctx
 			ev.Time = event.Uint32()
-			ev.X = event.Float32()
-			ev.Y = event.Float32()
-			p.mu.RLock()
-			for _, h := range p.motionHandlers {
+			ev.X = event.Fixed()
+			ev.Y = event.Fixed()
+			for _, h := range handlers {
 				h.HandleDataDeviceMotion(ev)
 			}
-			p.mu.RUnlock()
 		}
 	case 4:
-		if len(p.dropHandlers) > 0 {
+		p.mu.RLock()
+		handlers := p.dropHandlers
+		p.mu.RUnlock()
+		if len(handlers) > 0 {
 			ev := DataDeviceDropEvent{}
 			ev.EventContext = ctx
-			p.mu.RLock()
-			for _, h := range p.dropHandlers {
+			for _, h := range handlers {
 				h.HandleDataDeviceDrop(ev)
 			}
-			p.mu.RUnlock()
 		}
 	case 5:
-		if len(p.selectionHandlers) > 0 {
+		p.mu.RLock()
+		handlers := p.selectionHandlers
+		p.mu.RUnlock()
+		if len(handlers) > 0 {
 			ev := DataDeviceSelectionEvent{}
 			ev.EventContext = ctx
-			ev.Id = event.Proxy(p.Context()).(*DataOffer)
-			p.mu.RLock()
-			for _, h := range p.selectionHandlers {
+			ev.Id, _ = event.Proxy(p.Context()).(*DataOffer)
+			for _, h := range handlers {
 				h.HandleDataDeviceSelection(ev)
 			}
-			p.mu.RUnlock()
 		}
 	}
 }
@@ -1448,9 +2031,35 @@ func NewDataDevice(ctx *Context) *DataDevice {
 	return ret
 }
 
+var dataDeviceInterface = &Interface{
+	Name:    "wl_data_device",
+	Version: 3,
+	New:     func() Proxy { return new(DataDevice) },
+	Requests: []Message{
+		{Name: "start_drag", Signature: "?oo?ou", Types: []string{"wl_data_source", "wl_surface", "wl_surface", ""}},
+		{Name: "set_selection", Signature: "?ou", Types: []string{"wl_data_source", ""}},
+		{Name: "release", Since: 2},
+	},
+	Events: []Message{
+		{Name: "data_offer", Signature: "n", Types: []string{"wl_data_offer"}},
+		{Name: "enter", Signature: "uoff?o", Types: []string{"", "wl_surface", "", "", "wl_data_offer"}},
+		{Name: "leave"},
+		{Name: "motion", Signature: "uff"},
+		{Name: "drop"},
+		{Name: "selection", Signature: "?o", Types: []string{"wl_data_offer"}},
+	},
+	Errors: map[uint32]string{
+		DataDeviceErrorRole: "role",
+	},
+}
+
+// Interface returns the description of the wl_data_device interface.
+func (p *DataDevice) Interface() *Interface {
+	return dataDeviceInterface
+}
+
 // StartDrag will start drag-and-drop operation.
 //
-//
 // This request asks the compositor to start a drag-and-drop
 // operation on behalf of the client.
 //
@@ -1478,32 +2087,32 @@ func NewDataDevice(ctx *Context) *DataDevice {
 // wl_surface is no longer used as the icon surface. When the use
 // as an icon ends, the current and pending input regions become
 // undefined, and the wl_surface is unmapped.
-//
 func (p *DataDevice) StartDrag(source *DataSource, origin *Surface, icon *Surface, serial uint32) error {
-	return p.Context().SendRequest(p, 0, source, origin, icon, serial)
+	return p.Context().SendRequest(p, 0, NullableProxy(source), origin, NullableProxy(icon), serial)
 }
 
 // SetSelection will copy data to the selection.
 //
-//
 // This request asks the compositor to set the selection
 // to the data from the source on behalf of the client.
 //
 // To unset the selection, set the source to NULL.
-//
 func (p *DataDevice) SetSelection(source *DataSource, serial uint32) error {
-	return p.Context().SendRequest(p, 1, source, serial)
+	return p.Context().SendRequest(p, 1, NullableProxy(source), serial)
 }
 
 // Release will destroy data device.
 //
-//
 // This request destroys the data device.
-//
 func (p *DataDevice) Release() error {
-	return p.Context().SendRequest(p, 2)
+	if err := p.Context().SendRequest(p, 2); err != nil {
+		return err
+	}
+	p.Context().Unregister(p)
+	return nil
 }
 
+// Error codes of wl_data_device, reported with wl_display.error.
 const (
 	DataDeviceErrorRole = 0
 )
@@ -1518,26 +2127,56 @@ func NewDataDeviceManager(ctx *Context) *DataDeviceManager {
 	return ret
 }
 
+var dataDeviceManagerInterface = &Interface{
+	Name:    "wl_data_device_manager",
+	Version: 3,
+	New:     func() Proxy { return new(DataDeviceManager) },
+	Requests: []Message{
+		{Name: "create_data_source", Signature: "n", Types: []string{"wl_data_source"}},
+		{Name: "get_data_device", Signature: "no", Types: []string{"wl_data_device", "wl_seat"}},
+	},
+}
+
+// Interface returns the description of the wl_data_device_manager interface.
+func (p *DataDeviceManager) Interface() *Interface {
+	return dataDeviceManagerInterface
+}
+
 // CreateDataSource will create a new data source.
 //
-//
 // Create a new data source.
-//
 func (p *DataDeviceManager) CreateDataSource() (*DataSource, error) {
 	ret := NewDataSource(p.Context())
-	return ret, p.Context().SendRequest(p, 0, Proxy(ret))
+	ret.SetQueue(p.Queue())
+	ret.SetVersion(p.Version())
+	if err := p.Context().SendRequest(p, 0, Proxy(ret)); err != nil {
+		p.Context().Discard(ret)
+		return nil, err
+	}
+	return ret, nil
 }
 
 // GetDataDevice will create a new data device.
 //
-//
 // Create a new data device for a given seat.
-//
 func (p *DataDeviceManager) GetDataDevice(seat *Seat) (*DataDevice, error) {
 	ret := NewDataDevice(p.Context())
-	return ret, p.Context().SendRequest(p, 1, Proxy(ret), seat)
+	ret.SetQueue(p.Queue())
+	ret.SetVersion(p.Version())
+	if err := p.Context().SendRequest(p, 1, Proxy(ret), seat); err != nil {
+		p.Context().Discard(ret)
+		return nil, err
+	}
+	return ret, nil
 }
 
+// DataDeviceManagerDndAction is the dnd_action bitfield of
+// wl_data_device_manager.  It is the type of the source_actions argument
+// of wl_data_offer.source_actions, the dnd_action argument of
+// wl_data_offer.action and the dnd_action argument of
+// wl_data_source.action.
+type DataDeviceManagerDndAction uint32
+
 const (
 	DataDeviceManagerDndActionNone = 0
 	DataDeviceManagerDndActionCopy = 1
@@ -1555,20 +2194,42 @@ func NewShell(ctx *Context) *Shell {
 	return ret
 }
 
+var shellInterface = &Interface{
+	Name:    "wl_shell",
+	Version: 1,
+	New:     func() Proxy { return new(Shell) },
+	Requests: []Message{
+		{Name: "get_shell_surface", Signature: "no", Types: []string{"wl_shell_surface", "wl_surface"}},
+	},
+	Errors: map[uint32]string{
+		ShellErrorRole: "role",
+	},
+}
+
+// Interface returns the description of the wl_shell interface.
+func (p *Shell) Interface() *Interface {
+	return shellInterface
+}
+
 // GetShellSurface will create a shell surface from a surface.
 //
-//
 // Create a shell surface for an existing surface. This gives
 // the wl_surface the role of a shell surface. If the wl_surface
 // already has another role, it raises a protocol error.
 //
 // Only one shell surface can be associated with a given surface.
-//
 func (p *Shell) GetShellSurface(surface *Surface) (*ShellSurface, error) {
 	ret := NewShellSurface(p.Context())
-	return ret, p.Context().SendRequest(p, 0, Proxy(ret), surface)
+	ret.SetQueue(p.Queue())
+	ret.SetVersion(p.Version())
+	if err := p.Context().SendRequest(p, 0, Proxy(ret), surface); err != nil {
+		p.Context().Discard(ret)
+		return nil, err
+	}
+	return ret, nil
 }
 
+// Error codes of wl_shell, reported with wl_display.error.
 const (
 	ShellErrorRole = 0
 )
@@ -1596,15 +2257,31 @@ func (p *ShellSurface) RemovePingHandler(h ShellSurfacePingHandler) {
 
 	for i, e := range p.pingHandlers {
 		if e == h {
-			p.pingHandlers = append(p.pingHandlers[:i], p.pingHandlers[i+1:]...)
+			// copied, as Dispatch may be iterating over the old slice
+			p.pingHandlers = append(p.pingHandlers[:i:i], p.pingHandlers[i+1:]...)
 			break
 		}
 	}
 }
 
+type shellSurfacePingFunc struct {
+	f func(ShellSurfacePingEvent)
+}
+
+func (h *shellSurfacePingFunc) HandleShellSurfacePing(ev ShellSurfacePingEvent) {
+	h.f(ev)
+}
+
+// OnPing calls f for every ping event, until cancel is called.
+func (p *ShellSurface) OnPing(f func(ShellSurfacePingEvent)) (cancel func()) {
+	h := &shellSurfacePingFunc{f}
+	p.AddPingHandler(h)
+	return func() { p.RemovePingHandler(h) }
+}
+
 type ShellSurfaceConfigureEvent struct {
 	EventContext context.Context
-	Edges        uint32
+	Edges        ShellSurfaceResize
 	Width        int32
 	Height       int32
 }
@@ -1627,12 +2304,28 @@ func (p *ShellSurface) RemoveConfigureHandler(h ShellSurfaceConfigureHandler) {
 
 	for i, e := range p.configureHandlers {
 		if e == h {
-			p.configureHandlers = append(p.configureHandlers[:i], p.configureHandlers[i+1:]...)
+			// copied, as Dispatch may be iterating over the old slice
+			p.configureHandlers = append(p.configureHandlers[:i:i], p.configureHandlers[i+1:]...)
 			break
 		}
 	}
 }
 
+type shellSurfaceConfigureFunc struct {
+	f func(ShellSurfaceConfigureEvent)
+}
+
+func (h *shellSurfaceConfigureFunc) HandleShellSurfaceConfigure(ev ShellSurfaceConfigureEvent) {
+	h.f(ev)
+}
+
+// OnConfigure calls f for every configure event, until cancel is called.
+func (p *ShellSurface) OnConfigure(f func(ShellSurfaceConfigureEvent)) (cancel func()) {
+	h := &shellSurfaceConfigureFunc{f}
+	p.AddConfigureHandler(h)
+	return func() { p.RemoveConfigureHandler(h) }
+}
+
 type ShellSurfacePopupDoneEvent struct {
 	EventContext context.Context
 }
@@ -1655,47 +2348,66 @@ func (p *ShellSurface) RemovePopupDoneHandler(h ShellSurfacePopupDoneHandler) {
 
 	for i, e := range p.popupDoneHandlers {
 		if e == h {
-			p.popupDoneHandlers = append(p.popupDoneHandlers[:i], p.popupDoneHandlers[i+1:]...)
+			// copied, as Dispatch may be iterating over the old slice
+			p.popupDoneHandlers = append(p.popupDoneHandlers[:i:i], p.popupDoneHandlers[i+1:]...)
 			break
 		}
 	}
 }
 
+type shellSurfacePopupDoneFunc struct {
+	f func(ShellSurfacePopupDoneEvent)
+}
+
+func (h *shellSurfacePopupDoneFunc) HandleShellSurfacePopupDone(ev ShellSurfacePopupDoneEvent) {
+	h.f(ev)
+}
+
+// OnPopupDone calls f for every popup_done event, until cancel is called.
+func (p *ShellSurface) OnPopupDone(f func(ShellSurfacePopupDoneEvent)) (cancel func()) {
+	h := &shellSurfacePopupDoneFunc{f}
+	p.AddPopupDoneHandler(h)
+	return func() { p.RemovePopupDoneHandler(h) }
+}
+
 func (p *ShellSurface) Dispatch(ctx context.Context, event *Event) {
 	switch event.Opcode {
 	case 0:
-		if len(p.pingHandlers) > 0 {
+		p.mu.RLock()
+		handlers := p.pingHandlers
+		p.mu.RUnlock()
+		if len(handlers) > 0 {
 			ev := ShellSurfacePingEvent{}
 			ev.EventContext = ctx
 			ev.Serial = event.Uint32()
-			p.mu.RLock()
-			for _, h := range p.pingHandlers {
+			for _, h := range handlers {
 				h.HandleShellSurfacePing(ev)
 			}
-			p.mu.RUnlock()
 		}
 	case 1:
-		if len(p.configureHandlers) > 0 {
+		p.mu.RLock()
+		handlers := p.configureHandlers
+		p.mu.RUnlock()
+		if len(handlers) > 0 {
 			ev := ShellSurfaceConfigureEvent{}
 			ev.EventContext = ctx
-			ev.Edges = event.Uint32()
+			ev.Edges = ShellSurfaceResize(event.Uint32())
 			ev.Width = event.Int32()
 			ev.Height = event.Int32()
-			p.mu.RLock()
-			for _, h := range p.configureHandlers {
+			for _, h := range handlers {
 				h.HandleShellSurfaceConfigure(ev)
 			}
-			p.mu.RUnlock()
 		}
 	case 2:
-		if len(p.popupDoneHandlers) > 0 {
+		p.mu.RLock()
+		handlers := p.popupDoneHandlers
+		p.mu.RUnlock()
+		if len(handlers) > 0 {
 			ev := ShellSurfacePopupDoneEvent{}
 			ev.EventContext = ctx
-			p.mu.RLock()
-			for _, h := range p.popupDoneHandlers {
+			for _, h := range handlers {
 				h.HandleShellSurfacePopupDone(ev)
 			}
-			p.mu.RUnlock()
 		}
 	}
 }
@@ -1714,56 +2426,75 @@ func NewShellSurface(ctx *Context) *ShellSurface {
 	return ret
 }
 
+var shellSurfaceInterface = &Interface{
+	Name:    "wl_shell_surface",
+	Version: 1,
+	New:     func() Proxy { return new(ShellSurface) },
+	Requests: []Message{
+		{Name: "pong", Signature: "u"},
+		{Name: "move", Signature: "ou", Types: []string{"wl_seat", ""}},
+		{Name: "resize", Signature: "ouu", Types: []string{"wl_seat", "", ""}},
+		{Name: "set_toplevel"},
+		{Name: "set_transient", Signature: "oiiu", Types: []string{"wl_surface", "", "", ""}},
+		{Name: "set_fullscreen", Signature: "uu?o", Types: []string{"", "", "wl_output"}},
+		{Name: "set_popup", Signature: "ouoiiu", Types: []string{"wl_seat", "", "wl_surface", "", "", ""}},
+		{Name: "set_maximized", Signature: "?o", Types: []string{"wl_output"}},
+		{Name: "set_title", Signature: "s"},
+		{Name: "set_class", Signature: "s"},
+	},
+	Events: []Message{
+		{Name: "ping", Signature: "u"},
+		{Name: "configure", Signature: "uii"},
+		{Name: "popup_done"},
+	},
+}
+
+// Interface returns the description of the wl_shell_surface interface.
+func (p *ShellSurface) Interface() *Interface {
+	return shellSurfaceInterface
+}
+
 // Pong will respond to a ping event.
 //
-//
 // A client must respond to a ping event with a pong request or
 // the client may be deemed unresponsive.
-//
 func (p *ShellSurface) Pong(serial uint32) error {
 	return p.Context().SendRequest(p, 0, serial)
 }
 
 // Move will start an interactive move.
 //
-//
 // Start a pointer-driven move of the surface.
 //
 // This request must be used in response to a button press event.
 // The server may ignore move requests depending on the state of
 // the surface (e.g. fullscreen or maximized).
-//
 func (p *ShellSurface) Move(seat *Seat, serial uint32) error {
 	return p.Context().SendRequest(p, 1, seat, serial)
 }
 
 // Resize will start an interactive resize.
 //
-//
 // Start a pointer-driven resizing of the surface.
 //
 // This request must be used in response to a button press event.
 // The server may ignore resize requests depending on the state of
 // the surface (e.g. fullscreen or maximized).
-//
 func (p *ShellSurface) Resize(seat *Seat, serial uint32, edges uint32) error {
 	return p.Context().SendRequest(p, 2, seat, serial, edges)
 }
 
 // SetToplevel will make the surface a toplevel surface.
 //
-//
 // Map the surface as a toplevel surface.
 //
 // A toplevel surface is not fullscreen, maximized or transient.
-//
 func (p *ShellSurface) SetToplevel() error {
 	return p.Context().SendRequest(p, 3)
 }
 
 // SetTransient will make the surface a transient surface.
 //
-//
 // Map the surface relative to an existing surface.
 //
 // The x and y arguments specify the location of the upper left
@@ -1771,14 +2502,12 @@ func (p *ShellSurface) SetToplevel() error {
 // parent surface, in surface-local coordinates.
 //
 // The flags argument controls details of the transient behaviour.
-//
 func (p *ShellSurface) SetTransient(parent *Surface, x int32, y int32, flags uint32) error {
 	return p.Context().SendRequest(p, 4, parent, x, y, flags)
 }
 
 // SetFullscreen will make the surface a fullscreen surface.
 //
-//
 // Map the surface as a fullscreen surface.
 //
 // If an output parameter is given then the surface will be made
@@ -1812,14 +2541,12 @@ func (p *ShellSurface) SetTransient(parent *Surface, x int32, y int32, flags uin
 // The compositor must reply to this request with a configure event
 // with the dimensions for the output on which the surface will
 // be made fullscreen.
-//
 func (p *ShellSurface) SetFullscreen(method uint32, framerate uint32, output *Output) error {
-	return p.Context().SendRequest(p, 5, method, framerate, output)
+	return p.Context().SendRequest(p, 5, method, framerate, NullableProxy(output))
 }
 
 // SetPopup will make the surface a popup surface.
 //
-//
 // Map the surface as a popup.
 //
 // A popup surface is a transient surface with an added pointer
@@ -1839,14 +2566,12 @@ func (p *ShellSurface) SetFullscreen(method uint32, framerate uint32, output *Ou
 // The x and y arguments specify the location of the upper left
 // corner of the surface relative to the upper left corner of the
 // parent surface, in surface-local coordinates.
-//
 func (p *ShellSurface) SetPopup(seat *Seat, serial uint32, parent *Surface, x int32, y int32, flags uint32) error {
 	return p.Context().SendRequest(p, 6, seat, serial, parent, x, y, flags)
 }
 
 // SetMaximized will make the surface a maximized surface.
 //
-//
 // Map the surface as a maximized surface.
 //
 // If an output parameter is given then the surface will be
@@ -1865,14 +2590,12 @@ func (p *ShellSurface) SetPopup(seat *Seat, serial uint32, parent *Surface, x in
 // fullscreen shell surface.
 //
 // The details depend on the compositor implementation.
-//
 func (p *ShellSurface) SetMaximized(output *Output) error {
-	return p.Context().SendRequest(p, 7, output)
+	return p.Context().SendRequest(p, 7, NullableProxy(output))
 }
 
 // SetTitle will set surface title.
 //
-//
 // Set a short title for the surface.
 //
 // This string may be used to identify the surface in a task bar,
@@ -1880,25 +2603,26 @@ func (p *ShellSurface) SetMaximized(output *Output) error {
 // compositor.
 //
 // The string must be encoded in UTF-8.
-//
 func (p *ShellSurface) SetTitle(title string) error {
 	return p.Context().SendRequest(p, 8, title)
 }
 
 // SetClass will set surface class.
 //
-//
 // Set a class for the surface.
 //
 // The surface class identifies the general class of applications
 // to which the surface belongs. A common convention is to use the
 // file name (or the full path if it is a non-standard location) of
 // the application's .desktop file as the class.
-//
 func (p *ShellSurface) SetClass(class_ string) error {
 	return p.Context().SendRequest(p, 9, class_)
 }
 
+// ShellSurfaceResize is the resize bitfield of wl_shell_surface.  It is
+// the type of the edges argument of wl_shell_surface.configure.
+type ShellSurfaceResize uint32
+
 const (
 	ShellSurfaceResizeNone        = 0
 	ShellSurfaceResizeTop         = 1
@@ -1911,10 +2635,15 @@ const (
 	ShellSurfaceResizeBottomRight = 10
 )
 
+// Values of the transient bitfield of wl_shell_surface, for the flags
+// argument of wl_shell_surface.set_transient and the flags argument of
+// wl_shell_surface.set_popup.
 const (
 	ShellSurfaceTransientInactive = 0x1
 )
 
+// Values of the fullscreen_method enum of wl_shell_surface, for the
+// method argument of wl_shell_surface.set_fullscreen.
 const (
 	ShellSurfaceFullscreenMethodDefault = 0
 	ShellSurfaceFullscreenMethodScale   = 1
@@ -1945,12 +2674,28 @@ func (p *Surface) RemoveEnterHandler(h SurfaceEnterHandler) {
 
 	for i, e := range p.enterHandlers {
 		if e == h {
-			p.enterHandlers = append(p.enterHandlers[:i], p.enterHandlers[i+1:]...)
+			// copied, as Dispatch may be iterating over the old slice
+			p.enterHandlers = append(p.enterHandlers[:i:i], p.enterHandlers[i+1:]...)
 			break
 		}
 	}
 }
 
+type surfaceEnterFunc struct {
+	f func(SurfaceEnterEvent)
+}
+
+func (h *surfaceEnterFunc) HandleSurfaceEnter(ev SurfaceEnterEvent) {
+	h.f(ev)
+}
+
+// OnEnter calls f for every enter event, until cancel is called.
+func (p *Surface) OnEnter(f func(SurfaceEnterEvent)) (cancel func()) {
+	h := &surfaceEnterFunc{f}
+	p.AddEnterHandler(h)
+	return func() { p.RemoveEnterHandler(h) }
+}
+
 type SurfaceLeaveEvent struct {
 	EventContext context.Context
 	Output       *Output
@@ -1974,35 +2719,53 @@ func (p *Surface) RemoveLeaveHandler(h SurfaceLeaveHandler) {
 
 	for i, e := range p.leaveHandlers {
 		if e == h {
-			p.leaveHandlers = append(p.leaveHandlers[:i], p.leaveHandlers[i+1:]...)
+			// copied, as Dispatch may be iterating over the old slice
+			p.leaveHandlers = append(p.leaveHandlers[:i:i], p.leaveHandlers[i+1:]...)
 			break
 		}
 	}
 }
 
+type surfaceLeaveFunc struct {
+	f func(SurfaceLeaveEvent)
+}
+
+func (h *surfaceLeaveFunc) HandleSurfaceLeave(ev SurfaceLeaveEvent) {
+	h.f(ev)
+}
+
+// OnLeave calls f for every leave event, until cancel is called.
+func (p *Surface) OnLeave(f func(SurfaceLeaveEvent)) (cancel func()) {
+	h := &surfaceLeaveFunc{f}
+	p.AddLeaveHandler(h)
+	return func() { p.RemoveLeaveHandler(h) }
+}
+
 func (p *Surface) Dispatch(ctx context.Context, event *Event) {
 	switch event.Opcode {
 	case 0:
-		if len(p.enterHandlers) > 0 {
+		p.mu.RLock()
+		handlers := p.enterHandlers
+		p.mu.RUnlock()
+		if len(handlers) > 0 {
 			ev := SurfaceEnterEvent{}
 			ev.EventContext = ctx
-			ev.Output = event.Proxy(p.Context()).(*Output)
-			p.mu.RLock()
-			for _, h := range p.enterHandlers {
+			ev.Output, _ = event.Proxy(p.Context()).(*Output)
+			for _, h := range handlers {
 				h.HandleSurfaceEnter(ev)
 			}
-			p.mu.RUnlock()
 		}
 	case 1:
-		if len(p.leaveHandlers) > 0 {
+		p.mu.RLock()
+		handlers := p.leaveHandlers
+		p.mu.RUnlock()
+		if len(handlers) > 0 {
 			ev := SurfaceLeaveEvent{}
 			ev.EventContext = ctx
-			ev.Output = event.Proxy(p.Context()).(*Output)
-			p.mu.RLock()
-			for _, h := range p.leaveHandlers {
+			ev.Output, _ = event.Proxy(p.Context()).(*Output)
+			for _, h := range handlers {
 				h.HandleSurfaceLeave(ev)
 			}
-			p.mu.RUnlock()
 		}
 	}
 }
@@ -2020,18 +2783,50 @@ func NewSurface(ctx *Context) *Surface {
 	return ret
 }
 
+var surfaceInterface = &Interface{
+	Name:    "wl_surface",
+	Version: 4,
+	New:     func() Proxy { return new(Surface) },
+	Requests: []Message{
+		{Name: "destroy"},
+		{Name: "attach", Signature: "?oii", Types: []string{"wl_buffer", "", ""}},
+		{Name: "damage", Signature: "iiii"},
+		{Name: "frame", Signature: "n", Types: []string{"wl_callback"}},
+		{Name: "set_opaque_region", Signature: "?o", Types: []string{"wl_region"}},
+		{Name: "set_input_region", Signature: "?o", Types: []string{"wl_region"}},
+		{Name: "commit"},
+		{Name: "set_buffer_transform", Signature: "i", Since: 2},
+		{Name: "set_buffer_scale", Signature: "i", Since: 3},
+		{Name: "damage_buffer", Signature: "iiii", Since: 4},
+	},
+	Events: []Message{
+		{Name: "enter", Signature: "o", Types: []string{"wl_output"}},
+		{Name: "leave", Signature: "o", Types: []string{"wl_output"}},
+	},
+	Errors: map[uint32]string{
+		SurfaceErrorInvalidScale:     "invalid_scale",
+		SurfaceErrorInvalidTransform: "invalid_transform",
+	},
+}
+
+// Interface returns the description of the wl_surface interface.
+func (p *Surface) Interface() *Interface {
+	return surfaceInterface
+}
+
 // Destroy will delete surface.
 //
-//
 // Deletes the surface and invalidates its object ID.
-//
 func (p *Surface) Destroy() error {
-	return p.Context().SendRequest(p, 0)
+	if err := p.Context().SendRequest(p, 0); err != nil {
+		return err
+	}
+	p.Context().Unregister(p)
+	return nil
 }
 
 // Attach will set the surface contents.
 //
-//
 // Set a buffer as the content of this surface.
 //
 // The new size of the surface is calculated based on the buffer
@@ -2071,14 +2866,12 @@ func (p *Surface) Destroy() error {
 //
 // If wl_surface.attach is sent with a NULL wl_buffer, the
 // following wl_surface.commit will remove the surface content.
-//
 func (p *Surface) Attach(buffer *Buffer, x int32, y int32) error {
-	return p.Context().SendRequest(p, 1, buffer, x, y)
+	return p.Context().SendRequest(p, 1, NullableProxy(buffer), x, y)
 }
 
 // Damage will mark part of the surface damaged.
 //
-//
 // This request is used to describe the regions where the pending
 // buffer is different from the current surface contents, and where
 // the surface therefore needs to be repainted. The compositor
@@ -2100,14 +2893,12 @@ func (p *Surface) Attach(buffer *Buffer, x int32, y int32) error {
 // Alternatively, damage can be posted with wl_surface.damage_buffer
 // which uses buffer coordinates instead of surface coordinates,
 // and is probably the preferred and intuitive way of doing this.
-//
 func (p *Surface) Damage(x int32, y int32, width int32, height int32) error {
 	return p.Context().SendRequest(p, 2, x, y, width, height)
 }
 
 // Frame will request a frame throttling hint.
 //
-//
 // Request a notification when it is a good time to start drawing a new
 // frame, by creating a frame callback. This is useful for throttling
 // redrawing operations, and driving animations.
@@ -2140,15 +2931,19 @@ func (p *Surface) Damage(x int32, y int32, width int32, height int32) error {
 //
 // The callback_data passed in the callback is the current time, in
 // milliseconds, with an undefined base.
-//
 func (p *Surface) Frame() (*Callback, error) {
 	ret := NewCallback(p.Context())
-	return ret, p.Context().SendRequest(p, 3, Proxy(ret))
+	ret.SetQueue(p.Queue())
+	ret.SetVersion(p.Version())
+	if err := p.Context().SendRequest(p, 3, Proxy(ret)); err != nil {
+		p.Context().Discard(ret)
+		return nil, err
+	}
+	return ret, nil
 }
 
 // SetOpaqueRegion will set opaque region.
 //
-//
 // This request sets the region of the surface that contains
 // opaque content.
 //
@@ -2173,14 +2968,12 @@ func (p *Surface) Frame() (*Callback, error) {
 // opaque region has copy semantics, and the wl_region object can be
 // destroyed immediately. A NULL wl_region causes the pending opaque
 // region to be set to empty.
-//
 func (p *Surface) SetOpaqueRegion(region *Region) error {
-	return p.Context().SendRequest(p, 4, region)
+	return p.Context().SendRequest(p, 4, NullableProxy(region))
 }
 
 // SetInputRegion will set input region.
 //
-//
 // This request sets the region of the surface that can receive
 // pointer and touch events.
 //
@@ -2203,14 +2996,12 @@ func (p *Surface) SetOpaqueRegion(region *Region) error {
 // has copy semantics, and the wl_region object can be destroyed
 // immediately. A NULL wl_region causes the input region to be set
 // to infinite.
-//
 func (p *Surface) SetInputRegion(region *Region) error {
-	return p.Context().SendRequest(p, 5, region)
+	return p.Context().SendRequest(p, 5, NullableProxy(region))
 }
 
 // Commit will commit pending surface state.
 //
-//
 // Surface state (input, opaque, and damage regions, attached buffers,
 // etc.) is double-buffered. Protocol requests modify the pending state,
 // as opposed to the current state in use by the compositor. A commit
@@ -2228,14 +3019,12 @@ func (p *Surface) SetInputRegion(region *Region) error {
 // to affect double-buffered state.
 //
 // Other interfaces may add further double-buffered surface state.
-//
 func (p *Surface) Commit() error {
 	return p.Context().SendRequest(p, 6)
 }
 
 // SetBufferTransform will sets the buffer transformation.
 //
-//
 // This request sets an optional transformation on how the compositor
 // interprets the contents of the buffer attached to the surface. The
 // accepted values for the transform parameter are the values for
@@ -2265,14 +3054,12 @@ func (p *Surface) Commit() error {
 // If transform is not one of the values from the
 // wl_output.transform enum the invalid_transform protocol error
 // is raised.
-//
 func (p *Surface) SetBufferTransform(transform int32) error {
 	return p.Context().SendRequest(p, 7, transform)
 }
 
 // SetBufferScale will sets the buffer scaling factor.
 //
-//
 // This request sets an optional scaling factor on how the compositor
 // interprets the contents of the buffer attached to the window.
 //
@@ -2296,14 +3083,12 @@ func (p *Surface) SetBufferTransform(transform int32) error {
 //
 // If scale is not positive the invalid_scale protocol error is
 // raised.
-//
 func (p *Surface) SetBufferScale(scale int32) error {
 	return p.Context().SendRequest(p, 8, scale)
 }
 
 // DamageBuffer will mark part of the surface damaged using buffer coordinates.
 //
-//
 // This request is used to describe the regions where the pending
 // buffer is different from the current surface contents, and where
 // the surface therefore needs to be repainted. The compositor
@@ -2336,11 +3121,11 @@ func (p *Surface) SetBufferScale(scale int32) error {
 // kinds of damage into account will have to accumulate damage from the
 // two requests separately and only transform from one to the other
 // after receiving the wl_surface.commit.
-//
 func (p *Surface) DamageBuffer(x int32, y int32, width int32, height int32) error {
 	return p.Context().SendRequest(p, 9, x, y, width, height)
 }
 
+// Error codes of wl_surface, reported with wl_display.error.
 const (
 	SurfaceErrorInvalidScale     = 0
 	SurfaceErrorInvalidTransform = 1
@@ -2348,7 +3133,7 @@ const (
 
 type SeatCapabilitiesEvent struct {
 	EventContext context.Context
-	Capabilities uint32
+	Capabilities SeatCapability
 }
 
 type SeatCapabilitiesHandler interface {
@@ -2369,12 +3154,28 @@ func (p *Seat) RemoveCapabilitiesHandler(h SeatCapabilitiesHandler) {
 
 	for i, e := range p.capabilitiesHandlers {
 		if e == h {
-			p.capabilitiesHandlers = append(p.capabilitiesHandlers[:i], p.capabilitiesHandlers[i+1:]...)
+			// copied, as Dispatch may be iterating over the old slice
+			p.capabilitiesHandlers = append(p.capabilitiesHandlers[:i:i], p.capabilitiesHandlers[i+1:]...)
 			break
 		}
 	}
 }
 
+type seatCapabilitiesFunc struct {
+	f func(SeatCapabilitiesEvent)
+}
+
+func (h *seatCapabilitiesFunc) HandleSeatCapabilities(ev SeatCapabilitiesEvent) {
+	h.f(ev)
+}
+
+// OnCapabilities calls f for every capabilities event, until cancel is called.
+func (p *Seat) OnCapabilities(f func(SeatCapabilitiesEvent)) (cancel func()) {
+	h := &seatCapabilitiesFunc{f}
+	p.AddCapabilitiesHandler(h)
+	return func() { p.RemoveCapabilitiesHandler(h) }
+}
+
 type SeatNameEvent struct {
 	EventContext context.Context
 	Name         string
@@ -2398,35 +3199,53 @@ func (p *Seat) RemoveNameHandler(h SeatNameHandler) {
 
 	for i, e := range p.nameHandlers {
 		if e == h {
-			p.nameHandlers = append(p.nameHandlers[:i], p.nameHandlers[i+1:]...)
+			// copied, as Dispatch may be iterating over the old slice
+			p.nameHandlers = append(p.nameHandlers[:i:i], p.nameHandlers[i+1:]...)
 			break
 		}
 	}
 }
 
+type seatNameFunc struct {
+	f func(SeatNameEvent)
+}
+
+func (h *seatNameFunc) HandleSeatName(ev SeatNameEvent) {
+	h.f(ev)
+}
+
+// OnName calls f for every name event, until cancel is called.
+func (p *Seat) OnName(f func(SeatNameEvent)) (cancel func()) {
+	h := &seatNameFunc{f}
+	p.AddNameHandler(h)
+	return func() { p.RemoveNameHandler(h) }
+}
+
 func (p *Seat) Dispatch(ctx context.Context, event *Event) {
 	switch event.Opcode {
 	case 0:
-		if len(p.capabilitiesHandlers) > 0 {
+		p.mu.RLock()
+		handlers := p.capabilitiesHandlers
+		p.mu.RUnlock()
+		if len(handlers) > 0 {
 			ev := SeatCapabilitiesEvent{}
 			ev.EventContext = ctx
-			ev.Capabilities = event.Uint32()
-			p.mu.RLock()
-			for _, h := range p.capabilitiesHandlers {
+			ev.Capabilities = SeatCapability(event.Uint32())
+			for _, h := range handlers {
 				h.HandleSeatCapabilities(ev)
 			}
-			p.mu.RUnlock()
 		}
 	case 1:
-		if len(p.nameHandlers) > 0 {
+		p.mu.RLock()
+		handlers := p.nameHandlers
+		p.mu.RUnlock()
+		if len(handlers) > 0 {
 			ev := SeatNameEvent{}
 			ev.EventContext = ctx
 			ev.Name = event.String()
-			p.mu.RLock()
-			for _, h := range p.nameHandlers {
+			for _, h := range handlers {
 				h.HandleSeatName(ev)
 			}
-			p.mu.RUnlock()
 		}
 	}
 }
@@ -2444,9 +3263,29 @@ func NewSeat(ctx *Context) *Seat {
 	return ret
 }
 
+var seatInterface = &Interface{
+	Name:    "wl_seat",
+	Version: 6,
+	New:     func() Proxy { return new(Seat) },
+	Requests: []Message{
+		{Name: "get_pointer", Signature: "n", Types: []string{"wl_pointer"}},
+		{Name: "get_keyboard", Signature: "n", Types: []string{"wl_keyboard"}},
+		{Name: "get_touch", Signature: "n", Types: []string{"wl_touch"}},
+		{Name: "release", Since: 5},
+	},
+	Events: []Message{
+		{Name: "capabilities", Signature: "u"},
+		{Name: "name", Signature: "s", Since: 2},
+	},
+}
+
+// Interface returns the description of the wl_seat interface.
+func (p *Seat) Interface() *Interface {
+	return seatInterface
+}
+
 // GetPointer will return pointer object.
 //
-//
 // The ID provided will be initialized to the wl_pointer interface
 // for this seat.
 //
@@ -2454,15 +3293,19 @@ func NewSeat(ctx *Context) *Seat {
 // capability, or has had the pointer capability in the past.
 // It is a protocol violation to issue this request on a seat that has
 // never had the pointer capability.
-//
 func (p *Seat) GetPointer() (*Pointer, error) {
 	ret := NewPointer(p.Context())
-	return ret, p.Context().SendRequest(p, 0, Proxy(ret))
+	ret.SetQueue(p.Queue())
+	ret.SetVersion(p.Version())
+	if err := p.Context().SendRequest(p, 0, Proxy(ret)); err != nil {
+		p.Context().Discard(ret)
+		return nil, err
+	}
+	return ret, nil
 }
 
 // GetKeyboard will return keyboard object.
 //
-//
 // The ID provided will be initialized to the wl_keyboard interface
 // for this seat.
 //
@@ -2470,15 +3313,19 @@ func (p *Seat) GetPointer() (*Pointer, error) {
 // capability, or has had the keyboard capability in the past.
 // It is a protocol violation to issue this request on a seat that has
 // never had the keyboard capability.
-//
 func (p *Seat) GetKeyboard() (*Keyboard, error) {
 	ret := NewKeyboard(p.Context())
-	return ret, p.Context().SendRequest(p, 1, Proxy(ret))
+	ret.SetQueue(p.Queue())
+	ret.SetVersion(p.Version())
+	if err := p.Context().SendRequest(p, 1, Proxy(ret)); err != nil {
+		p.Context().Discard(ret)
+		return nil, err
+	}
+	return ret, nil
 }
 
 // GetTouch will return touch object.
 //
-//
 // The ID provided will be initialized to the wl_touch interface
 // for this seat.
 //
@@ -2486,22 +3333,33 @@ func (p *Seat) GetKeyboard() (*Keyboard, error) {
 // capability, or has had the touch capability in the past.
 // It is a protocol violation to issue this request on a seat that has
 // never had the touch capability.
-//
 func (p *Seat) GetTouch() (*Touch, error) {
 	ret := NewTouch(p.Context())
-	return ret, p.Context().SendRequest(p, 2, Proxy(ret))
+	ret.SetQueue(p.Queue())
+	ret.SetVersion(p.Version())
+	if err := p.Context().SendRequest(p, 2, Proxy(ret)); err != nil {
+		p.Context().Discard(ret)
+		return nil, err
+	}
+	return ret, nil
 }
 
 // Release will release the seat object.
 //
-//
 // Using this request a client can tell the server that it is not going to
 // use the seat object anymore.
-//
 func (p *Seat) Release() error {
-	return p.Context().SendRequest(p, 3)
+	if err := p.Context().SendRequest(p, 3); err != nil {
+		return err
+	}
+	p.Context().Unregister(p)
+	return nil
 }
 
+// SeatCapability is the capability bitfield of wl_seat.  It is the type
+// of the capabilities argument of wl_seat.capabilities.
+type SeatCapability uint32
+
 const (
 	SeatCapabilityPointer  = 1
 	SeatCapabilityKeyboard = 2
@@ -2512,8 +3370,8 @@ type PointerEnterEvent struct {
 	EventContext context.Context
 	Serial       uint32
 	Surface      *Surface
-	SurfaceX     float32
-	SurfaceY     float32
+	SurfaceX     Fixed
+	SurfaceY     Fixed
 }
 
 type PointerEnterHandler interface {
@@ -2534,12 +3392,28 @@ func (p *Pointer) RemoveEnterHandler(h PointerEnterHandler) {
 
 	for i, e := range p.enterHandlers {
 		if e == h {
-			p.enterHandlers = append(p.enterHandlers[:i], p.enterHandlers[i+1:]...)
+			// copied, as Dispatch may be iterating over the old slice
+			p.enterHandlers = append(p.enterHandlers[:i:i], p.enterHandlers[i+1:]...)
 			break
 		}
 	}
 }
 
+type pointerEnterFunc struct {
+	f func(PointerEnterEvent)
+}
+
+func (h *pointerEnterFunc) HandlePointerEnter(ev PointerEnterEvent) {
+	h.f(ev)
+}
+
+// OnEnter calls f for every enter event, until cancel is called.
+func (p *Pointer) OnEnter(f func(PointerEnterEvent)) (cancel func()) {
+	h := &pointerEnterFunc{f}
+	p.AddEnterHandler(h)
+	return func() { p.RemoveEnterHandler(h) }
+}
+
 type PointerLeaveEvent struct {
 	EventContext context.Context
 	Serial       uint32
@@ -2564,17 +3438,33 @@ func (p *Pointer) RemoveLeaveHandler(h PointerLeaveHandler) {
 
 	for i, e := range p.leaveHandlers {
 		if e == h {
-			p.leaveHandlers = append(p.leaveHandlers[:i], p.leaveHandlers[i+1:]...)
+			// copied, as Dispatch may be iterating over the old slice
+			p.leaveHandlers = append(p.leaveHandlers[:i:i], p.leaveHandlers[i+1:]...)
 			break
 		}
 	}
 }
 
+type pointerLeaveFunc struct {
+	f func(PointerLeaveEvent)
+}
+
+func (h *pointerLeaveFunc) HandlePointerLeave(ev PointerLeaveEvent) {
+	h.f(ev)
+}
+
+// OnLeave calls f for every leave event, until cancel is called.
+func (p *Pointer) OnLeave(f func(PointerLeaveEvent)) (cancel func()) {
+	h := &pointerLeaveFunc{f}
+	p.AddLeaveHandler(h)
+	return func() { p.RemoveLeaveHandler(h) }
+}
+
 type PointerMotionEvent struct {
 	EventContext context.Context
 	Time         uint32
-	SurfaceX     float32
-	SurfaceY     float32
+	SurfaceX     Fixed
+	SurfaceY     Fixed
 }
 
 type PointerMotionHandler interface {
@@ -2595,18 +3485,34 @@ func (p *Pointer) RemoveMotionHandler(h PointerMotionHandler) {
 
 	for i, e := range p.motionHandlers {
 		if e == h {
-			p.motionHandlers = append(p.motionHandlers[:i], p.motionHandlers[i+1:]...)
+			// copied, as Dispatch may be iterating over the old slice
+			p.motionHandlers = append(p.motionHandlers[:i:i], p.motionHandlers[i+1:]...)
 			break
 		}
 	}
 }
 
+type pointerMotionFunc struct {
+	f func(PointerMotionEvent)
+}
+
+func (h *pointerMotionFunc) HandlePointerMotion(ev PointerMotionEvent) {
+	h.f(ev)
+}
+
+// OnMotion calls f for every motion event, until cancel is called.
+func (p *Pointer) OnMotion(f func(PointerMotionEvent)) (cancel func()) {
+	h := &pointerMotionFunc{f}
+	p.AddMotionHandler(h)
+	return func() { p.RemoveMotionHandler(h) }
+}
+
 type PointerButtonEvent struct {
 	EventContext context.Context
 	Serial       uint32
 	Time         uint32
 	Button       uint32
-	State        uint32
+	State        PointerButtonState
 }
 
 type PointerButtonHandler interface {
@@ -2627,17 +3533,33 @@ func (p *Pointer) RemoveButtonHandler(h PointerButtonHandler) {
 
 	for i, e := range p.buttonHandlers {
 		if e == h {
-			p.buttonHandlers = append(p.buttonHandlers[:i], p.buttonHandlers[i+1:]...)
+			// copied, as Dispatch may be iterating over the old slice
+			p.buttonHandlers = append(p.buttonHandlers[:i:i], p.buttonHandlers[i+1:]...)
 			break
 		}
 	}
 }
 
+type pointerButtonFunc struct {
+	f func(PointerButtonEvent)
+}
+
+func (h *pointerButtonFunc) HandlePointerButton(ev PointerButtonEvent) {
+	h.f(ev)
+}
+
+// OnButton calls f for every button event, until cancel is called.
+func (p *Pointer) OnButton(f func(PointerButtonEvent)) (cancel func()) {
+	h := &pointerButtonFunc{f}
+	p.AddButtonHandler(h)
+	return func() { p.RemoveButtonHandler(h) }
+}
+
 type PointerAxisEvent struct {
 	EventContext context.Context
 	Time         uint32
-	Axis         uint32
-	Value        float32
+	Axis         PointerAxis
+	Value        Fixed
 }
 
 type PointerAxisHandler interface {
@@ -2658,12 +3580,28 @@ func (p *Pointer) RemoveAxisHandler(h PointerAxisHandler) {
 
 	for i, e := range p.axisHandlers {
 		if e == h {
-			p.axisHandlers = append(p.axisHandlers[:i], p.axisHandlers[i+1:]...)
+			// copied, as Dispatch may be iterating over the old slice
+			p.axisHandlers = append(p.axisHandlers[:i:i], p.axisHandlers[i+1:]...)
 			break
 		}
 	}
 }
 
+type pointerAxisFunc struct {
+	f func(PointerAxisEvent)
+}
+
+func (h *pointerAxisFunc) HandlePointerAxis(ev PointerAxisEvent) {
+	h.f(ev)
+}
+
+// OnAxis calls f for every axis event, until cancel is called.
+func (p *Pointer) OnAxis(f func(PointerAxisEvent)) (cancel func()) {
+	h := &pointerAxisFunc{f}
+	p.AddAxisHandler(h)
+	return func() { p.RemoveAxisHandler(h) }
+}
+
 type PointerFrameEvent struct {
 	EventContext context.Context
 }
@@ -2686,15 +3624,31 @@ func (p *Pointer) RemoveFrameHandler(h PointerFrameHandler) {
 
 	for i, e := range p.frameHandlers {
 		if e == h {
-			p.frameHandlers = append(p.frameHandlers[:i], p.frameHandlers[i+1:]...)
+			// copied, as Dispatch may be iterating over the old slice
+			p.frameHandlers = append(p.frameHandlers[:i:i], p.frameHandlers[i+1:]...)
 			break
 		}
 	}
 }
 
+type pointerFrameFunc struct {
+	f func(PointerFrameEvent)
+}
+
+func (h *pointerFrameFunc) HandlePointerFrame(ev PointerFrameEvent) {
+	h.f(ev)
+}
+
+// OnFrame calls f for every frame event, until cancel is called.
+func (p *Pointer) OnFrame(f func(PointerFrameEvent)) (cancel func()) {
+	h := &pointerFrameFunc{f}
+	p.AddFrameHandler(h)
+	return func() { p.RemoveFrameHandler(h) }
+}
+
 type PointerAxisSourceEvent struct {
 	EventContext context.Context
-	AxisSource   uint32
+	AxisSource   PointerAxisSource
 }
 
 type PointerAxisSourceHandler interface {
@@ -2715,16 +3669,32 @@ func (p *Pointer) RemoveAxisSourceHandler(h PointerAxisSourceHandler) {
 
 	for i, e := range p.axisSourceHandlers {
 		if e == h {
-			p.axisSourceHandlers = append(p.axisSourceHandlers[:i], p.axisSourceHandlers[i+1:]...)
+			// copied, as Dispatch may be iterating over the old slice
+			p.axisSourceHandlers = append(p.axisSourceHandlers[:i:i], p.axisSourceHandlers[i+1:]...)
 			break
 		}
 	}
 }
 
+type pointerAxisSourceFunc struct {
+	f func(PointerAxisSourceEvent)
+}
+
+func (h *pointerAxisSourceFunc) HandlePointerAxisSource(ev PointerAxisSourceEvent) {
+	h.f(ev)
+}
+
+// OnAxisSource calls f for every axis_source event, until cancel is called.
+func (p *Pointer) OnAxisSource(f func(PointerAxisSourceEvent)) (cancel func()) {
+	h := &pointerAxisSourceFunc{f}
+	p.AddAxisSourceHandler(h)
+	return func() { p.RemoveAxisSourceHandler(h) }
+}
+
 type PointerAxisStopEvent struct {
 	EventContext context.Context
 	Time         uint32
-	Axis         uint32
+	Axis         PointerAxis
 }
 
 type PointerAxisStopHandler interface {
@@ -2745,15 +3715,31 @@ func (p *Pointer) RemoveAxisStopHandler(h PointerAxisStopHandler) {
 
 	for i, e := range p.axisStopHandlers {
 		if e == h {
-			p.axisStopHandlers = append(p.axisStopHandlers[:i], p.axisStopHandlers[i+1:]...)
+			// copied, as Dispatch may be iterating over the old slice
+			p.axisStopHandlers = append(p.axisStopHandlers[:i:i], p.axisStopHandlers[i+1:]...)
 			break
 		}
 	}
 }
 
+type pointerAxisStopFunc struct {
+	f func(PointerAxisStopEvent)
+}
+
+func (h *pointerAxisStopFunc) HandlePointerAxisStop(ev PointerAxisStopEvent) {
+	h.f(ev)
+}
+
+// OnAxisStop calls f for every axis_stop event, until cancel is called.
+func (p *Pointer) OnAxisStop(f func(PointerAxisStopEvent)) (cancel func()) {
+	h := &pointerAxisStopFunc{f}
+	p.AddAxisStopHandler(h)
+	return func() { p.RemoveAxisStopHandler(h) }
+}
+
 type PointerAxisDiscreteEvent struct {
 	EventContext context.Context
-	Axis         uint32
+	Axis         PointerAxis
 	Discrete     int32
 }
 
@@ -2775,124 +3761,149 @@ func (p *Pointer) RemoveAxisDiscreteHandler(h PointerAxisDiscreteHandler) {
 
 	for i, e := range p.axisDiscreteHandlers {
 		if e == h {
-			p.axisDiscreteHandlers = append(p.axisDiscreteHandlers[:i], p.axisDiscreteHandlers[i+1:]...)
+			// copied, as Dispatch may be iterating over the old slice
+			p.axisDiscreteHandlers = append(p.axisDiscreteHandlers[:i:i], p.axisDiscreteHandlers[i+1:]...)
 			break
 		}
 	}
 }
 
+type pointerAxisDiscreteFunc struct {
+	f func(PointerAxisDiscreteEvent)
+}
+
+func (h *pointerAxisDiscreteFunc) HandlePointerAxisDiscrete(ev PointerAxisDiscreteEvent) {
+	h.f(ev)
+}
+
+// OnAxisDiscrete calls f for every axis_discrete event, until cancel is called.
+func (p *Pointer) OnAxisDiscrete(f func(PointerAxisDiscreteEvent)) (cancel func()) {
+	h := &pointerAxisDiscreteFunc{f}
+	p.AddAxisDiscreteHandler(h)
+	return func() { p.RemoveAxisDiscreteHandler(h) }
+}
+
 func (p *Pointer) Dispatch(ctx context.Context, event *Event) {
 	switch event.Opcode {
 	case 0:
-		if len(p.enterHandlers) > 0 {
+		p.mu.RLock()
+		handlers := p.enterHandlers
+		p.mu.RUnlock()
+		if len(handlers) > 0 {
 			ev := PointerEnterEvent{}
 			ev.EventContext = ctx
 			ev.Serial = event.Uint32()
-			ev.Surface = event.Proxy(p.Context()).(*Surface)
-			ev.SurfaceX = event.Float32()
-			ev.SurfaceY = event.Float32()
-			p.mu.RLock()
-			for _, h := range p.enterHandlers {
+			ev.Surface, _ = event.Proxy(p.Context()).(*Surface)
+			ev.SurfaceX = event.Fixed()
+			ev.SurfaceY = event.Fixed()
+			for _, h := range handlers {
 				h.HandlePointerEnter(ev)
 			}
-			p.mu.RUnlock()
 		}
 	case 1:
-		if len(p.leaveHandlers) > 0 {
+		p.mu.RLock()
+		handlers := p.leaveHandlers
+		p.mu.RUnlock()
+		if len(handlers) > 0 {
 			ev := PointerLeaveEvent{}
 			ev.EventContext = ctx
 			ev.Serial = event.Uint32()
-			ev.Surface = event.Proxy(p.Context()).(*Surface)
-			p.mu.RLock()
-			for _, h := range p.leaveHandlers {
+			ev.Surface, _ = event.Proxy(p.Context()).(*Surface)
+			for _, h := range handlers {
 				h.HandlePointerLeave(ev)
 			}
-			p.mu.RUnlock()
 		}
 	case 2:
-		if len(p.motionHandlers) > 0 {
+		p.mu.RLock()
+		handlers := p.motionHandlers
+		p.mu.RUnlock()
+		if len(handlers) > 0 {
 			ev := PointerMotionEvent{}
 			ev.EventContext = ctx
 			ev.Time = event.Uint32()
-			ev.SurfaceX = event.Float32()
-			ev.SurfaceY = event.Float32()
-			p.mu.RLock()
-			for _, h := range p.motionHandlers {
+			ev.SurfaceX = event.Fixed()
+			ev.SurfaceY = event.Fixed()
+			for _, h := range handlers {
 				h.HandlePointerMotion(ev)
 			}
-			p.mu.RUnlock()
 		}
 	case 3:
-		if len(p.buttonHandlers) > 0 {
+		p.mu.RLock()
+		handlers := p.buttonHandlers
+		p.mu.RUnlock()
+		if len(handlers) > 0 {
 			ev := PointerButtonEvent{}
 			ev.EventContext = ctx
 			ev.Serial = event.Uint32()
 			ev.Time = event.Uint32()
 			ev.Button = event.Uint32()
-			ev.State = event.Uint32()
-			p.mu.RLock()
-			for _, h := range p.buttonHandlers {
+			ev.State = PointerButtonState(event.Uint32())
+			for _, h := range handlers {
 				h.HandlePointerButton(ev)
 			}
-			p.mu.RUnlock()
 		}
 	case 4:
-		if len(p.axisHandlers) > 0 {
+		p.mu.RLock()
+		handlers := p.axisHandlers
+		p.mu.RUnlock()
+		if len(handlers) > 0 {
 			ev := PointerAxisEvent{}
 			ev.EventContext = ctx
 			ev.Time = event.Uint32()
-			ev.Axis = event.Uint32()
-			ev.Value = event.Float32()
-			p.mu.RLock()
-			for _, h := range p.axisHandlers {
+			ev.Axis = PointerAxis(event.Uint32())
+			ev.Value = event.Fixed()
+			for _, h := range handlers {
 				h.HandlePointerAxis(ev)
 			}
-			p.mu.RUnlock()
 		}
 	case 5:
-		if len(p.frameHandlers) > 0 {
+		p.mu.RLock()
+		handlers := p.frameHandlers
+		p.mu.RUnlock()
+		if len(handlers) > 0 {
 			ev := PointerFrameEvent{}
 			ev.EventContext = ctx
-			p.mu.RLock()
-			for _, h := range p.frameHandlers {
+			for _, h := range handlers {
 				h.HandlePointerFrame(ev)
 			}
-			p.mu.RUnlock()
 		}
 	case 6:
-		if len(p.axisSourceHandlers) > 0 {
+		p.mu.RLock()
+		handlers := p.axisSourceHandlers
+		p.mu.RUnlock()
+		if len(handlers) > 0 {
 			ev := PointerAxisSourceEvent{}
 			ev.EventContext = ctx
-			ev.AxisSource = event.Uint32()
-			p.mu.RLock()
-			for _, h := range p.axisSourceHandlers {
+			ev.AxisSource = PointerAxisSource(event.Uint32())
+			for _, h := range handlers {
 				h.HandlePointerAxisSource(ev)
 			}
-			p.mu.RUnlock()
 		}
 	case 7:
-		if len(p.axisStopHandlers) > 0 {
+		p.mu.RLock()
+		handlers := p.axisStopHandlers
+		p.mu.RUnlock()
+		if len(handlers) > 0 {
 			ev := PointerAxisStopEvent{}
 			ev.EventContext = ctx
 			ev.Time = event.Uint32()
-			ev.Axis = event.Uint32()
-			p.mu.RLock()
-			for _, h := range p.axisStopHandlers {
+			ev.Axis = PointerAxis(event.Uint32())
+			for _, h := range handlers {
 				h.HandlePointerAxisStop(ev)
 			}
-			p.mu.RUnlock()
 		}
 	case 8:
-		if len(p.axisDiscreteHandlers) > 0 {
+		p.mu.RLock()
+		handlers := p.axisDiscreteHandlers
+		p.mu.RUnlock()
+		if len(handlers) > 0 {
 			ev := PointerAxisDiscreteEvent{}
 			ev.EventContext = ctx
-			ev.Axis = event.Uint32()
+			ev.Axis = PointerAxis(event.Uint32())
 			ev.Discrete = event.Int32()
-			p.mu.RLock()
-			for _, h := range p.axisDiscreteHandlers {
+			for _, h := range handlers {
 				h.HandlePointerAxisDiscrete(ev)
 			}
-			p.mu.RUnlock()
 		}
 	}
 }
@@ -2917,9 +3928,37 @@ func NewPointer(ctx *Context) *Pointer {
 	return ret
 }
 
+var pointerInterface = &Interface{
+	Name:    "wl_pointer",
+	Version: 6,
+	New:     func() Proxy { return new(Pointer) },
+	Requests: []Message{
+		{Name: "set_cursor", Signature: "u?oii", Types: []string{"", "wl_surface", "", ""}},
+		{Name: "release", Since: 3},
+	},
+	Events: []Message{
+		{Name: "enter", Signature: "uoff", Types: []string{"", "wl_surface", "", ""}},
+		{Name: "leave", Signature: "uo", Types: []string{"", "wl_surface"}},
+		{Name: "motion", Signature: "uff"},
+		{Name: "button", Signature: "uuuu"},
+		{Name: "axis", Signature: "uuf"},
+		{Name: "frame", Since: 5},
+		{Name: "axis_source", Signature: "u", Since: 5},
+		{Name: "axis_stop", Signature: "uu", Since: 5},
+		{Name: "axis_discrete", Signature: "ui", Since: 5},
+	},
+	Errors: map[uint32]string{
+		PointerErrorRole: "role",
+	},
+}
+
+// Interface returns the description of the wl_pointer interface.
+func (p *Pointer) Interface() *Interface {
+	return pointerInterface
+}
+
 // SetCursor will set the pointer surface.
 //
-//
 // Set the pointer surface, i.e., the surface that contains the
 // pointer image (cursor). This request gives the surface the role
 // of a cursor. If the surface already has another role, it raises
@@ -2951,38 +3990,54 @@ func NewPointer(ctx *Context) *Pointer {
 // wl_surface is no longer used as the cursor. When the use as a
 // cursor ends, the current and pending input regions become
 // undefined, and the wl_surface is unmapped.
-//
 func (p *Pointer) SetCursor(serial uint32, surface *Surface, hotspot_x int32, hotspot_y int32) error {
-	return p.Context().SendRequest(p, 0, serial, surface, hotspot_x, hotspot_y)
+	return p.Context().SendRequest(p, 0, serial, NullableProxy(surface), hotspot_x, hotspot_y)
 }
 
 // Release will release the pointer object.
 //
-//
 // Using this request a client can tell the server that it is not going to
 // use the pointer object anymore.
 //
 // This request destroys the pointer proxy object, so clients must not call
 // wl_pointer_destroy() after using this request.
-//
 func (p *Pointer) Release() error {
-	return p.Context().SendRequest(p, 1)
+	if err := p.Context().SendRequest(p, 1); err != nil {
+		return err
+	}
+	p.Context().Unregister(p)
+	return nil
 }
 
+// Error codes of wl_pointer, reported with wl_display.error.
 const (
 	PointerErrorRole = 0
 )
 
+// PointerButtonState is the button_state enum of wl_pointer.  It is the
+// type of the state argument of wl_pointer.button.
+type PointerButtonState uint32
+
 const (
 	PointerButtonStateReleased = 0
 	PointerButtonStatePressed  = 1
 )
 
+// PointerAxis is the axis enum of wl_pointer.  It is the type of the
+// axis argument of wl_pointer.axis, the axis argument of
+// wl_pointer.axis_stop and the axis argument of
+// wl_pointer.axis_discrete.
+type PointerAxis uint32
+
 const (
 	PointerAxisVerticalScroll   = 0
 	PointerAxisHorizontalScroll = 1
 )
 
+// PointerAxisSource is the axis_source enum of wl_pointer.  It is the
+// type of the axis_source argument of wl_pointer.axis_source.
+type PointerAxisSource uint32
+
 const (
 	PointerAxisSourceWheel      = 0
 	PointerAxisSourceFinger     = 1
@@ -2992,7 +4047,7 @@ const (
 
 type KeyboardKeymapEvent struct {
 	EventContext context.Context
-	Format       uint32
+	Format       KeyboardKeymapFormat
 	Fd           uintptr
 	Size         uint32
 }
@@ -3015,17 +4070,33 @@ func (p *Keyboard) RemoveKeymapHandler(h KeyboardKeymapHandler) {
 
 	for i, e := range p.keymapHandlers {
 		if e == h {
-			p.keymapHandlers = append(p.keymapHandlers[:i], p.keymapHandlers[i+1:]...)
+			// copied, as Dispatch may be iterating over the old slice
+			p.keymapHandlers = append(p.keymapHandlers[:i:i], p.keymapHandlers[i+1:]...)
 			break
 		}
 	}
 }
 
+type keyboardKeymapFunc struct {
+	f func(KeyboardKeymapEvent)
+}
+
+func (h *keyboardKeymapFunc) HandleKeyboardKeymap(ev KeyboardKeymapEvent) {
+	h.f(ev)
+}
+
+// OnKeymap calls f for every keymap event, until cancel is called.
+func (p *Keyboard) OnKeymap(f func(KeyboardKeymapEvent)) (cancel func()) {
+	h := &keyboardKeymapFunc{f}
+	p.AddKeymapHandler(h)
+	return func() { p.RemoveKeymapHandler(h) }
+}
+
 type KeyboardEnterEvent struct {
 	EventContext context.Context
 	Serial       uint32
 	Surface      *Surface
-	Keys         []int32
+	Keys         Array
 }
 
 type KeyboardEnterHandler interface {
@@ -3046,12 +4117,28 @@ func (p *Keyboard) RemoveEnterHandler(h KeyboardEnterHandler) {
 
 	for i, e := range p.enterHandlers {
 		if e == h {
-			p.enterHandlers = append(p.enterHandlers[:i], p.enterHandlers[i+1:]...)
+			// copied, as Dispatch may be iterating over the old slice
+			p.enterHandlers = append(p.enterHandlers[:i:i], p.enterHandlers[i+1:]...)
 			break
 		}
 	}
 }
 
+type keyboardEnterFunc struct {
+	f func(KeyboardEnterEvent)
+}
+
+func (h *keyboardEnterFunc) HandleKeyboardEnter(ev KeyboardEnterEvent) {
+	h.f(ev)
+}
+
+// OnEnter calls f for every enter event, until cancel is called.
+func (p *Keyboard) OnEnter(f func(KeyboardEnterEvent)) (cancel func()) {
+	h := &keyboardEnterFunc{f}
+	p.AddEnterHandler(h)
+	return func() { p.RemoveEnterHandler(h) }
+}
+
 type KeyboardLeaveEvent struct {
 	EventContext context.Context
 	Serial       uint32
@@ -3076,18 +4163,34 @@ func (p *Keyboard) RemoveLeaveHandler(h KeyboardLeaveHandler) {
 
 	for i, e := range p.leaveHandlers {
 		if e == h {
-			p.leaveHandlers = append(p.leaveHandlers[:i], p.leaveHandlers[i+1:]...)
+			// copied, as Dispatch may be iterating over the old slice
+			p.leaveHandlers = append(p.leaveHandlers[:i:i], p.leaveHandlers[i+1:]...)
 			break
 		}
 	}
 }
 
+type keyboardLeaveFunc struct {
+	f func(KeyboardLeaveEvent)
+}
+
+func (h *keyboardLeaveFunc) HandleKeyboardLeave(ev KeyboardLeaveEvent) {
+	h.f(ev)
+}
+
+// OnLeave calls f for every leave event, until cancel is called.
+func (p *Keyboard) OnLeave(f func(KeyboardLeaveEvent)) (cancel func()) {
+	h := &keyboardLeaveFunc{f}
+	p.AddLeaveHandler(h)
+	return func() { p.RemoveLeaveHandler(h) }
+}
+
 type KeyboardKeyEvent struct {
 	EventContext context.Context
 	Serial       uint32
 	Time         uint32
 	Key          uint32
-	State        uint32
+	State        KeyboardKeyState
 }
 
 type KeyboardKeyHandler interface {
@@ -3108,12 +4211,28 @@ func (p *Keyboard) RemoveKeyHandler(h KeyboardKeyHandler) {
 
 	for i, e := range p.keyHandlers {
 		if e == h {
-			p.keyHandlers = append(p.keyHandlers[:i], p.keyHandlers[i+1:]...)
+			// copied, as Dispatch may be iterating over the old slice
+			p.keyHandlers = append(p.keyHandlers[:i:i], p.keyHandlers[i+1:]...)
 			break
 		}
 	}
 }
 
+type keyboardKeyFunc struct {
+	f func(KeyboardKeyEvent)
+}
+
+func (h *keyboardKeyFunc) HandleKeyboardKey(ev KeyboardKeyEvent) {
+	h.f(ev)
+}
+
+// OnKey calls f for every key event, until cancel is called.
+func (p *Keyboard) OnKey(f func(KeyboardKeyEvent)) (cancel func()) {
+	h := &keyboardKeyFunc{f}
+	p.AddKeyHandler(h)
+	return func() { p.RemoveKeyHandler(h) }
+}
+
 type KeyboardModifiersEvent struct {
 	EventContext  context.Context
 	Serial        uint32
@@ -3141,12 +4260,28 @@ func (p *Keyboard) RemoveModifiersHandler(h KeyboardModifiersHandler) {
 
 	for i, e := range p.modifiersHandlers {
 		if e == h {
-			p.modifiersHandlers = append(p.modifiersHandlers[:i], p.modifiersHandlers[i+1:]...)
+			// copied, as Dispatch may be iterating over the old slice
+			p.modifiersHandlers = append(p.modifiersHandlers[:i:i], p.modifiersHandlers[i+1:]...)
 			break
 		}
 	}
 }
 
+type keyboardModifiersFunc struct {
+	f func(KeyboardModifiersEvent)
+}
+
+func (h *keyboardModifiersFunc) HandleKeyboardModifiers(ev KeyboardModifiersEvent) {
+	h.f(ev)
+}
+
+// OnModifiers calls f for every modifiers event, until cancel is called.
+func (p *Keyboard) OnModifiers(f func(KeyboardModifiersEvent)) (cancel func()) {
+	h := &keyboardModifiersFunc{f}
+	p.AddModifiersHandler(h)
+	return func() { p.RemoveModifiersHandler(h) }
+}
+
 type KeyboardRepeatInfoEvent struct {
 	EventContext context.Context
 	Rate         int32
@@ -3171,68 +4306,91 @@ func (p *Keyboard) RemoveRepeatInfoHandler(h KeyboardRepeatInfoHandler) {
 
 	for i, e := range p.repeatInfoHandlers {
 		if e == h {
-			p.repeatInfoHandlers = append(p.repeatInfoHandlers[:i], p.repeatInfoHandlers[i+1:]...)
+			// copied, as Dispatch may be iterating over the old slice
+			p.repeatInfoHandlers = append(p.repeatInfoHandlers[:i:i], p.repeatInfoHandlers[i+1:]...)
 			break
 		}
 	}
 }
 
+type keyboardRepeatInfoFunc struct {
+	f func(KeyboardRepeatInfoEvent)
+}
+
+func (h *keyboardRepeatInfoFunc) HandleKeyboardRepeatInfo(ev KeyboardRepeatInfoEvent) {
+	h.f(ev)
+}
+
+// OnRepeatInfo calls f for every repeat_info event, until cancel is called.
+func (p *Keyboard) OnRepeatInfo(f func(KeyboardRepeatInfoEvent)) (cancel func()) {
+	h := &keyboardRepeatInfoFunc{f}
+	p.AddRepeatInfoHandler(h)
+	return func() { p.RemoveRepeatInfoHandler(h) }
+}
+
 func (p *Keyboard) Dispatch(ctx context.Context, event *Event) {
 	switch event.Opcode {
 	case 0:
-		if len(p.keymapHandlers) > 0 {
+		p.mu.RLock()
+		handlers := p.keymapHandlers
+		p.mu.RUnlock()
+		if len(handlers) > 0 {
 			ev := KeyboardKeymapEvent{}
 			ev.EventContext = ctx
-			ev.Format = event.Uint32()
+			ev.Format = KeyboardKeymapFormat(event.Uint32())
 			ev.Fd = event.FD()
 			ev.Size = event.Uint32()
-			p.mu.RLock()
-			for _, h := range p.keymapHandlers {
+			for _, h := range handlers {
 				h.HandleKeyboardKeymap(ev)
 			}
-			p.mu.RUnlock()
 		}
 	case 1:
-		if len(p.enterHandlers) > 0 {
+		p.mu.RLock()
+		handlers := p.enterHandlers
+		p.mu.RUnlock()
+		if len(handlers) > 0 {
 			ev := KeyboardEnterEvent{}
 			ev.EventContext = ctx
 			ev.Serial = event.Uint32()
-			ev.Surface = event.Proxy(p.Context()).(*Surface)
+			ev.Surface, _ = event.Proxy(p.Context()).(*Surface)
 			ev.Keys = event.Array()
-			p.mu.RLock()
-			for _, h := range p.enterHandlers {
+			for _, h := range handlers {
 				h.HandleKeyboardEnter(ev)
 			}
-			p.mu.RUnlock()
 		}
 	case 2:
-		if len(p.leaveHandlers) > 0 {
+		p.mu.RLock()
+		handlers := p.leaveHandlers
+		p.mu.RUnlock()
+		if len(handlers) > 0 {
 			ev := KeyboardLeaveEvent{}
 			ev.EventContext = ctx
 			ev.Serial = event.Uint32()
-			ev.Surface = event.Proxy(p.Context()).(*Surface)
-			p.mu.RLock()
-			for _, h := range p.leaveHandlers {
+			ev.Surface, _ = event.Proxy(p.Context()).(*Surface)
+			for _, h := range handlers {
 				h.HandleKeyboardLeave(ev)
 			}
-			p.mu.RUnlock()
 		}
 	case 3:
-		if len(p.keyHandlers) > 0 {
+		p.mu.RLock()
+		handlers := p.keyHandlers
+		p.mu.RUnlock()
+		if len(handlers) > 0 {
 			ev := KeyboardKeyEvent{}
 			ev.EventContext = ctx
 			ev.Serial = event.Uint32()
 			ev.Time = event.Uint32()
 			ev.Key = event.Uint32()
-			ev.State = event.Uint32()
-			p.mu.RLock()
-			for _, h := range p.keyHandlers {
+			ev.State = KeyboardKeyState(event.Uint32())
+			for _, h := range handlers {
 				h.HandleKeyboardKey(ev)
 			}
-			p.mu.RUnlock()
 		}
 	case 4:
-		if len(p.modifiersHandlers) > 0 {
+		p.mu.RLock()
+		handlers := p.modifiersHandlers
+		p.mu.RUnlock()
+		if len(handlers) > 0 {
 			ev := KeyboardModifiersEvent{}
 			ev.EventContext = ctx
 			ev.Serial = event.Uint32()
@@ -3240,23 +4398,22 @@ func (p *Keyboard) Dispatch(ctx context.Context, event *Event) {
 			ev.ModsLatched = event.Uint32()
 			ev.ModsLocked = event.Uint32()
 			ev.Group = event.Uint32()
-			p.mu.RLock()
-			for _, h := range p.modifiersHandlers {
+			for _, h := range handlers {
 				h.HandleKeyboardModifiers(ev)
 			}
-			p.mu.RUnlock()
 		}
 	case 5:
-		if len(p.repeatInfoHandlers) > 0 {
+		p.mu.RLock()
+		handlers := p.repeatInfoHandlers
+		p.mu.RUnlock()
+		if len(handlers) > 0 {
 			ev := KeyboardRepeatInfoEvent{}
 			ev.EventContext = ctx
 			ev.Rate = event.Int32()
 			ev.Delay = event.Int32()
-			p.mu.RLock()
-			for _, h := range p.repeatInfoHandlers {
+			for _, h := range handlers {
 				h.HandleKeyboardRepeatInfo(ev)
 			}
-			p.mu.RUnlock()
 		}
 	}
 }
@@ -3278,18 +4435,50 @@ func NewKeyboard(ctx *Context) *Keyboard {
 	return ret
 }
 
+var keyboardInterface = &Interface{
+	Name:    "wl_keyboard",
+	Version: 6,
+	New:     func() Proxy { return new(Keyboard) },
+	Requests: []Message{
+		{Name: "release", Since: 3},
+	},
+	Events: []Message{
+		{Name: "keymap", Signature: "uhu"},
+		{Name: "enter", Signature: "uoa", Types: []string{"", "wl_surface", ""}},
+		{Name: "leave", Signature: "uo", Types: []string{"", "wl_surface"}},
+		{Name: "key", Signature: "uuuu"},
+		{Name: "modifiers", Signature: "uuuuu"},
+		{Name: "repeat_info", Signature: "ii", Since: 4},
+	},
+}
+
+// Interface returns the description of the wl_keyboard interface.
+func (p *Keyboard) Interface() *Interface {
+	return keyboardInterface
+}
+
 // Release will release the keyboard object.
-//
-//
 func (p *Keyboard) Release() error {
-	return p.Context().SendRequest(p, 0)
+	if err := p.Context().SendRequest(p, 0); err != nil {
+		return err
+	}
+	p.Context().Unregister(p)
+	return nil
 }
 
+// KeyboardKeymapFormat is the keymap_format enum of wl_keyboard.  It is
+// the type of the format argument of wl_keyboard.keymap.
+type KeyboardKeymapFormat uint32
+
 const (
 	KeyboardKeymapFormatNoKeymap = 0
 	KeyboardKeymapFormatXkbV1    = 1
 )
 
+// KeyboardKeyState is the key_state enum of wl_keyboard.  It is the type
+// of the state argument of wl_keyboard.key.
+type KeyboardKeyState uint32
+
 const (
 	KeyboardKeyStateReleased = 0
 	KeyboardKeyStatePressed  = 1
@@ -3301,8 +4490,8 @@ type TouchDownEvent struct {
 	Time         uint32
 	Surface      *Surface
 	Id           int32
-	X            float32
-	Y            float32
+	X            Fixed
+	Y            Fixed
 }
 
 type TouchDownHandler interface {
@@ -3323,12 +4512,28 @@ func (p *Touch) RemoveDownHandler(h TouchDownHandler) {
 
 	for i, e := range p.downHandlers {
 		if e == h {
-			p.downHandlers = append(p.downHandlers[:i], p.downHandlers[i+1:]...)
+			// copied, as Dispatch may be iterating over the old slice
+			p.downHandlers = append(p.downHandlers[:i:i], p.downHandlers[i+1:]...)
 			break
 		}
 	}
 }
 
+type touchDownFunc struct {
+	f func(TouchDownEvent)
+}
+
+func (h *touchDownFunc) HandleTouchDown(ev TouchDownEvent) {
+	h.f(ev)
+}
+
+// OnDown calls f for every down event, until cancel is called.
+func (p *Touch) OnDown(f func(TouchDownEvent)) (cancel func()) {
+	h := &touchDownFunc{f}
+	p.AddDownHandler(h)
+	return func() { p.RemoveDownHandler(h) }
+}
+
 type TouchUpEvent struct {
 	EventContext context.Context
 	Serial       uint32
@@ -3354,18 +4559,34 @@ func (p *Touch) RemoveUpHandler(h TouchUpHandler) {
 
 	for i, e := range p.upHandlers {
 		if e == h {
-			p.upHandlers = append(p.upHandlers[:i], p.upHandlers[i+1:]...)
+			// copied, as Dispatch may be iterating over the old slice
+			p.upHandlers = append(p.upHandlers[:i:i], p.upHandlers[i+1:]...)
 			break
 		}
 	}
 }
 
+type touchUpFunc struct {
+	f func(TouchUpEvent)
+}
+
+func (h *touchUpFunc) HandleTouchUp(ev TouchUpEvent) {
+	h.f(ev)
+}
+
+// OnUp calls f for every up event, until cancel is called.
+func (p *Touch) OnUp(f func(TouchUpEvent)) (cancel func()) {
+	h := &touchUpFunc{f}
+	p.AddUpHandler(h)
+	return func() { p.RemoveUpHandler(h) }
+}
+
 type TouchMotionEvent struct {
 	EventContext context.Context
 	Time         uint32
 	Id           int32
-	X            float32
-	Y            float32
+	X            Fixed
+	Y            Fixed
 }
 
 type TouchMotionHandler interface {
@@ -3386,12 +4607,28 @@ func (p *Touch) RemoveMotionHandler(h TouchMotionHandler) {
 
 	for i, e := range p.motionHandlers {
 		if e == h {
-			p.motionHandlers = append(p.motionHandlers[:i], p.motionHandlers[i+1:]...)
+			// copied, as Dispatch may be iterating over the old slice
+			p.motionHandlers = append(p.motionHandlers[:i:i], p.motionHandlers[i+1:]...)
 			break
 		}
 	}
 }
 
+type touchMotionFunc struct {
+	f func(TouchMotionEvent)
+}
+
+func (h *touchMotionFunc) HandleTouchMotion(ev TouchMotionEvent) {
+	h.f(ev)
+}
+
+// OnMotion calls f for every motion event, until cancel is called.
+func (p *Touch) OnMotion(f func(TouchMotionEvent)) (cancel func()) {
+	h := &touchMotionFunc{f}
+	p.AddMotionHandler(h)
+	return func() { p.RemoveMotionHandler(h) }
+}
+
 type TouchFrameEvent struct {
 	EventContext context.Context
 }
@@ -3414,12 +4651,28 @@ func (p *Touch) RemoveFrameHandler(h TouchFrameHandler) {
 
 	for i, e := range p.frameHandlers {
 		if e == h {
-			p.frameHandlers = append(p.frameHandlers[:i], p.frameHandlers[i+1:]...)
+			// copied, as Dispatch may be iterating over the old slice
+			p.frameHandlers = append(p.frameHandlers[:i:i], p.frameHandlers[i+1:]...)
 			break
 		}
 	}
 }
 
+type touchFrameFunc struct {
+	f func(TouchFrameEvent)
+}
+
+func (h *touchFrameFunc) HandleTouchFrame(ev TouchFrameEvent) {
+	h.f(ev)
+}
+
+// OnFrame calls f for every frame event, until cancel is called.
+func (p *Touch) OnFrame(f func(TouchFrameEvent)) (cancel func()) {
+	h := &touchFrameFunc{f}
+	p.AddFrameHandler(h)
+	return func() { p.RemoveFrameHandler(h) }
+}
+
 type TouchCancelEvent struct {
 	EventContext context.Context
 }
@@ -3442,17 +4695,33 @@ func (p *Touch) RemoveCancelHandler(h TouchCancelHandler) {
 
 	for i, e := range p.cancelHandlers {
 		if e == h {
-			p.cancelHandlers = append(p.cancelHandlers[:i], p.cancelHandlers[i+1:]...)
+			// copied, as Dispatch may be iterating over the old slice
+			p.cancelHandlers = append(p.cancelHandlers[:i:i], p.cancelHandlers[i+1:]...)
 			break
 		}
 	}
 }
 
+type touchCancelFunc struct {
+	f func(TouchCancelEvent)
+}
+
+func (h *touchCancelFunc) HandleTouchCancel(ev TouchCancelEvent) {
+	h.f(ev)
+}
+
+// OnCancel calls f for every cancel event, until cancel is called.
+func (p *Touch) OnCancel(f func(TouchCancelEvent)) (cancel func()) {
+	h := &touchCancelFunc{f}
+	p.AddCancelHandler(h)
+	return func() { p.RemoveCancelHandler(h) }
+}
+
 type TouchShapeEvent struct {
 	EventContext context.Context
 	Id           int32
-	Major        float32
-	Minor        float32
+	Major        Fixed
+	Minor        Fixed
 }
 
 type TouchShapeHandler interface {
@@ -3473,16 +4742,32 @@ func (p *Touch) RemoveShapeHandler(h TouchShapeHandler) {
 
 	for i, e := range p.shapeHandlers {
 		if e == h {
-			p.shapeHandlers = append(p.shapeHandlers[:i], p.shapeHandlers[i+1:]...)
+			// copied, as Dispatch may be iterating over the old slice
+			p.shapeHandlers = append(p.shapeHandlers[:i:i], p.shapeHandlers[i+1:]...)
 			break
 		}
 	}
 }
 
+type touchShapeFunc struct {
+	f func(TouchShapeEvent)
+}
+
+func (h *touchShapeFunc) HandleTouchShape(ev TouchShapeEvent) {
+	h.f(ev)
+}
+
+// OnShape calls f for every shape event, until cancel is called.
+func (p *Touch) OnShape(f func(TouchShapeEvent)) (cancel func()) {
+	h := &touchShapeFunc{f}
+	p.AddShapeHandler(h)
+	return func() { p.RemoveShapeHandler(h) }
+}
+
 type TouchOrientationEvent struct {
 	EventContext context.Context
 	Id           int32
-	Orientation  float32
+	Orientation  Fixed
 }
 
 type TouchOrientationHandler interface {
@@ -3503,101 +4788,124 @@ func (p *Touch) RemoveOrientationHandler(h TouchOrientationHandler) {
 
 	for i, e := range p.orientationHandlers {
 		if e == h {
-			p.orientationHandlers = append(p.orientationHandlers[:i], p.orientationHandlers[i+1:]...)
+			// copied, as Dispatch may be iterating over the old slice
+			p.orientationHandlers = append(p.orientationHandlers[:i:i], p.orientationHandlers[i+1:]...)
 			break
 		}
 	}
 }
 
+type touchOrientationFunc struct {
+	f func(TouchOrientationEvent)
+}
+
+func (h *touchOrientationFunc) HandleTouchOrientation(ev TouchOrientationEvent) {
+	h.f(ev)
+}
+
+// OnOrientation calls f for every orientation event, until cancel is called.
+func (p *Touch) OnOrientation(f func(TouchOrientationEvent)) (cancel func()) {
+	h := &touchOrientationFunc{f}
+	p.AddOrientationHandler(h)
+	return func() { p.RemoveOrientationHandler(h) }
+}
+
 func (p *Touch) Dispatch(ctx context.Context, event *Event) {
 	switch event.Opcode {
 	case 0:
-		if len(p.downHandlers) > 0 {
+		p.mu.RLock()
+		handlers := p.downHandlers
+		p.mu.RUnlock()
+		if len(handlers) > 0 {
 			ev := TouchDownEvent{}
 			ev.EventContext = ctx
 			ev.Serial = event.Uint32()
 			ev.Time = event.Uint32()
-			ev.Surface = event.Proxy(p.Context()).(*Surface)
+			ev.Surface, _ = event.Proxy(p.Context()).(*Surface)
 			ev.Id = event.Int32()
-			ev.X = event.Float32()
-			ev.Y = event.Float32()
-			p.mu.RLock()
-			for _, h := range p.downHandlers {
+			ev.X = event.Fixed()
+			ev.Y = event.Fixed()
+			for _, h := range handlers {
 				h.HandleTouchDown(ev)
 			}
-			p.mu.RUnlock()
 		}
 	case 1:
-		if len(p.upHandlers) > 0 {
+		p.mu.RLock()
+		handlers := p.upHandlers
+		p.mu.RUnlock()
+		if len(handlers) > 0 {
 			ev := TouchUpEvent{}
 			ev.EventContext = ctx
 			ev.Serial = event.Uint32()
 			ev.Time = event.Uint32()
 			ev.Id = event.Int32()
-			p.mu.RLock()
-			for _, h := range p.upHandlers {
+			for _, h := range handlers {
 				h.HandleTouchUp(ev)
 			}
-			p.mu.RUnlock()
 		}
 	case 2:
-		if len(p.motionHandlers) > 0 {
+		p.mu.RLock()
+		handlers := p.motionHandlers
+		p.mu.RUnlock()
+		if len(handlers) > 0 {
 			ev := TouchMotionEvent{}
 			ev.EventContext = ctx
 			ev.Time = event.Uint32()
 			ev.Id = event.Int32()
-			ev.X = event.Float32()
-			ev.Y = event.Float32()
-			p.mu.RLock()
-			for _, h := range p.motionHandlers {
+			ev.X = event.Fixed()
+			ev.Y = event.Fixed()
+			for _, h := range handlers {
 				h.HandleTouchMotion(ev)
 			}
-			p.mu.RUnlock()
 		}
 	case 3:
-		if len(p.frameHandlers) > 0 {
+		p.mu.RLock()
+		handlers := p.frameHandlers
+		p.mu.RUnlock()
+		if len(handlers) > 0 {
 			ev := TouchFrameEvent{}
 			ev.EventContext = ctx
-			p.mu.RLock()
-			for _, h := range p.frameHandlers {
+			for _, h := range handlers {
 				h.HandleTouchFrame(ev)
 			}
-			p.mu.RUnlock()
 		}
 	case 4:
-		if len(p.cancelHandlers) > 0 {
+		p.mu.RLock()
+		handlers := p.cancelHandlers
+		p.mu.RUnlock()
+		if len(handlers) > 0 {
 			ev := TouchCancelEvent{}
 			ev.EventContext = ctx
-			p.mu.RLock()
-			for _, h := range p.cancelHandlers {
+			for _, h := range handlers {
 				h.HandleTouchCancel(ev)
 			}
-			p.mu.RUnlock()
 		}
 	case 5:
-		if len(p.shapeHandlers) > 0 {
+		p.mu.RLock()
+		handlers := p.shapeHandlers
+		p.mu.RUnlock()
+		if len(handlers) > 0 {
 			ev := TouchShapeEvent{}
 			ev.EventContext = ctx
 			ev.Id = event.Int32()
-			ev.Major = event.Float32()
-			ev.Minor = event.Float32()
-			p.mu.RLock()
-			for _, h := range p.shapeHandlers {
+			ev.Major = event.Fixed()
+			ev.Minor = event.Fixed()
+			for _, h := range handlers {
 				h.HandleTouchShape(ev)
 			}
-			p.mu.RUnlock()
 		}
 	case 6:
-		if len(p.orientationHandlers) > 0 {
+		p.mu.RLock()
+		handlers := p.orientationHandlers
+		p.mu.RUnlock()
+		if len(handlers) > 0 {
 			ev := TouchOrientationEvent{}
 			ev.EventContext = ctx
 			ev.Id = event.Int32()
-			ev.Orientation = event.Float32()
-			p.mu.RLock()
-			for _, h := range p.orientationHandlers {
+			ev.Orientation = event.Fixed()
+			for _, h := range handlers {
 				h.HandleTouchOrientation(ev)
 			}
-			p.mu.RUnlock()
 		}
 	}
 }
@@ -3620,11 +4928,36 @@ func NewTouch(ctx *Context) *Touch {
 	return ret
 }
 
+var touchInterface = &Interface{
+	Name:    "wl_touch",
+	Version: 6,
+	New:     func() Proxy { return new(Touch) },
+	Requests: []Message{
+		{Name: "release", Since: 3},
+	},
+	Events: []Message{
+		{Name: "down", Signature: "uuoiff", Types: []string{"", "", "wl_surface", "", "", ""}},
+		{Name: "up", Signature: "uui"},
+		{Name: "motion", Signature: "uiff"},
+		{Name: "frame"},
+		{Name: "cancel"},
+		{Name: "shape", Signature: "iff", Since: 6},
+		{Name: "orientation", Signature: "if", Since: 6},
+	},
+}
+
+// Interface returns the description of the wl_touch interface.
+func (p *Touch) Interface() *Interface {
+	return touchInterface
+}
+
 // Release will release the touch object.
-//
-//
 func (p *Touch) Release() error {
-	return p.Context().SendRequest(p, 0)
+	if err := p.Context().SendRequest(p, 0); err != nil {
+		return err
+	}
+	p.Context().Unregister(p)
+	return nil
 }
 
 type OutputGeometryEvent struct {
@@ -3633,10 +4966,10 @@ type OutputGeometryEvent struct {
 	Y              int32
 	PhysicalWidth  int32
 	PhysicalHeight int32
-	Subpixel       int32
+	Subpixel       OutputSubpixel
 	Make           string
 	Model          string
-	Transform      int32
+	Transform      OutputTransform
 }
 
 type OutputGeometryHandler interface {
@@ -3657,15 +4990,31 @@ func (p *Output) RemoveGeometryHandler(h OutputGeometryHandler) {
 
 	for i, e := range p.geometryHandlers {
 		if e == h {
-			p.geometryHandlers = append(p.geometryHandlers[:i], p.geometryHandlers[i+1:]...)
+			// copied, as Dispatch may be iterating over the old slice
+			p.geometryHandlers = append(p.geometryHandlers[:i:i], p.geometryHandlers[i+1:]...)
 			break
 		}
 	}
 }
 
+type outputGeometryFunc struct {
+	f func(OutputGeometryEvent)
+}
+
+func (h *outputGeometryFunc) HandleOutputGeometry(ev OutputGeometryEvent) {
+	h.f(ev)
+}
+
+// OnGeometry calls f for every geometry event, until cancel is called.
+func (p *Output) OnGeometry(f func(OutputGeometryEvent)) (cancel func()) {
+	h := &outputGeometryFunc{f}
+	p.AddGeometryHandler(h)
+	return func() { p.RemoveGeometryHandler(h) }
+}
+
 type OutputModeEvent struct {
 	EventContext context.Context
-	Flags        uint32
+	Flags        OutputMode
 	Width        int32
 	Height       int32
 	Refresh      int32
@@ -3689,12 +5038,28 @@ func (p *Output) RemoveModeHandler(h OutputModeHandler) {
 
 	for i, e := range p.modeHandlers {
 		if e == h {
-			p.modeHandlers = append(p.modeHandlers[:i], p.modeHandlers[i+1:]...)
+			// copied, as Dispatch may be iterating over the old slice
+			p.modeHandlers = append(p.modeHandlers[:i:i], p.modeHandlers[i+1:]...)
 			break
 		}
 	}
 }
 
+type outputModeFunc struct {
+	f func(OutputModeEvent)
+}
+
+func (h *outputModeFunc) HandleOutputMode(ev OutputModeEvent) {
+	h.f(ev)
+}
+
+// OnMode calls f for every mode event, until cancel is called.
+func (p *Output) OnMode(f func(OutputModeEvent)) (cancel func()) {
+	h := &outputModeFunc{f}
+	p.AddModeHandler(h)
+	return func() { p.RemoveModeHandler(h) }
+}
+
 type OutputDoneEvent struct {
 	EventContext context.Context
 }
@@ -3717,12 +5082,28 @@ func (p *Output) RemoveDoneHandler(h OutputDoneHandler) {
 
 	for i, e := range p.doneHandlers {
 		if e == h {
-			p.doneHandlers = append(p.doneHandlers[:i], p.doneHandlers[i+1:]...)
+			// copied, as Dispatch may be iterating over the old slice
+			p.doneHandlers = append(p.doneHandlers[:i:i], p.doneHandlers[i+1:]...)
 			break
 		}
 	}
 }
 
+type outputDoneFunc struct {
+	f func(OutputDoneEvent)
+}
+
+func (h *outputDoneFunc) HandleOutputDone(ev OutputDoneEvent) {
+	h.f(ev)
+}
+
+// OnDone calls f for every done event, until cancel is called.
+func (p *Output) OnDone(f func(OutputDoneEvent)) (cancel func()) {
+	h := &outputDoneFunc{f}
+	p.AddDoneHandler(h)
+	return func() { p.RemoveDoneHandler(h) }
+}
+
 type OutputScaleEvent struct {
 	EventContext context.Context
 	Factor       int32
@@ -3746,66 +5127,86 @@ func (p *Output) RemoveScaleHandler(h OutputScaleHandler) {
 
 	for i, e := range p.scaleHandlers {
 		if e == h {
-			p.scaleHandlers = append(p.scaleHandlers[:i], p.scaleHandlers[i+1:]...)
+			// copied, as Dispatch may be iterating over the old slice
+			p.scaleHandlers = append(p.scaleHandlers[:i:i], p.scaleHandlers[i+1:]...)
 			break
 		}
 	}
 }
 
+type outputScaleFunc struct {
+	f func(OutputScaleEvent)
+}
+
+func (h *outputScaleFunc) HandleOutputScale(ev OutputScaleEvent) {
+	h.f(ev)
+}
+
+// OnScale calls f for every scale event, until cancel is called.
+func (p *Output) OnScale(f func(OutputScaleEvent)) (cancel func()) {
+	h := &outputScaleFunc{f}
+	p.AddScaleHandler(h)
+	return func() { p.RemoveScaleHandler(h) }
+}
+
 func (p *Output) Dispatch(ctx context.Context, event *Event) {
 	switch event.Opcode {
 	case 0:
-		if len(p.geometryHandlers) > 0 {
+		p.mu.RLock()
+		handlers := p.geometryHandlers
+		p.mu.RUnlock()
+		if len(handlers) > 0 {
 			ev := OutputGeometryEvent{}
 			ev.EventContext = ctx
 			ev.X = event.Int32()
 			ev.Y = event.Int32()
 			ev.PhysicalWidth = event.Int32()
 			ev.PhysicalHeight = event.Int32()
-			ev.Subpixel = event.Int32()
+			ev.Subpixel = OutputSubpixel(event.Int32())
 			ev.Make = event.String()
 			ev.Model = event.String()
-			ev.Transform = event.Int32()
-			p.mu.RLock()
-			for _, h := range p.geometryHandlers {
+			ev.Transform = OutputTransform(event.Int32())
+			for _, h := range handlers {
 				h.HandleOutputGeometry(ev)
 			}
-			p.mu.RUnlock()
 		}
 	case 1:
-		if len(p.modeHandlers) > 0 {
+		p.mu.RLock()
+		handlers := p.modeHandlers
+		p.mu.RUnlock()
+		if len(handlers) > 0 {
 			ev := OutputModeEvent{}
 			ev.EventContext = ctx
-			ev.Flags = event.Uint32()
+			ev.Flags = OutputMode(event.Uint32())
 			ev.Width = event.Int32()
 			ev.Height = event.Int32()
 			ev.Refresh = event.Int32()
-			p.mu.RLock()
-			for _, h := range p.modeHandlers {
+			for _, h := range handlers {
 				h.HandleOutputMode(ev)
 			}
-			p.mu.RUnlock()
 		}
 	case 2:
-		if len(p.doneHandlers) > 0 {
+		p.mu.RLock()
+		handlers := p.doneHandlers
+		p.mu.RUnlock()
+		if len(handlers) > 0 {
 			ev := OutputDoneEvent{}
 			ev.EventContext = ctx
-			p.mu.RLock()
-			for _, h := range p.doneHandlers {
+			for _, h := range handlers {
 				h.HandleOutputDone(ev)
 			}
-			p.mu.RUnlock()
 		}
 	case 3:
-		if len(p.scaleHandlers) > 0 {
+		p.mu.RLock()
+		handlers := p.scaleHandlers
+		p.mu.RUnlock()
+		if len(handlers) > 0 {
 			ev := OutputScaleEvent{}
 			ev.EventContext = ctx
 			ev.Factor = event.Int32()
-			p.mu.RLock()
-			for _, h := range p.scaleHandlers {
+			for _, h := range handlers {
 				h.HandleOutputScale(ev)
 			}
-			p.mu.RUnlock()
 		}
 	}
 }
@@ -3825,16 +5226,42 @@ func NewOutput(ctx *Context) *Output {
 	return ret
 }
 
+var outputInterface = &Interface{
+	Name:    "wl_output",
+	Version: 3,
+	New:     func() Proxy { return new(Output) },
+	Requests: []Message{
+		{Name: "release", Since: 3},
+	},
+	Events: []Message{
+		{Name: "geometry", Signature: "iiiiissi"},
+		{Name: "mode", Signature: "uiii"},
+		{Name: "done", Since: 2},
+		{Name: "scale", Signature: "i", Since: 2},
+	},
+}
+
+// Interface returns the description of the wl_output interface.
+func (p *Output) Interface() *Interface {
+	return outputInterface
+}
+
 // Release will release the output object.
 //
-//
 // Using this request a client can tell the server that it is not going to
 // use the output object anymore.
-//
 func (p *Output) Release() error {
-	return p.Context().SendRequest(p, 0)
+	if err := p.Context().SendRequest(p, 0); err != nil {
+		return err
+	}
+	p.Context().Unregister(p)
+	return nil
 }
 
+// OutputSubpixel is the subpixel enum of wl_output.  It is the type of
+// the subpixel argument of wl_output.geometry.
+type OutputSubpixel uint32
+
 const (
 	OutputSubpixelUnknown       = 0
 	OutputSubpixelNone          = 1
@@ -3844,6 +5271,10 @@ const (
 	OutputSubpixelVerticalBgr   = 5
 )
 
+// OutputTransform is the transform enum of wl_output.  It is the type of
+// the transform argument of wl_output.geometry.
+type OutputTransform uint32
+
 const (
 	OutputTransformNormal     = 0
 	OutputTransform90         = 1
@@ -3855,6 +5286,10 @@ const (
 	OutputTransformFlipped270 = 7
 )
 
+// OutputMode is the mode bitfield of wl_output.  It is the type of the
+// flags argument of wl_output.mode.
+type OutputMode uint32
+
 const (
 	OutputModeCurrent   = 0x1
 	OutputModePreferred = 0x2
@@ -3870,29 +5305,43 @@ func NewRegion(ctx *Context) *Region {
 	return ret
 }
 
+var regionInterface = &Interface{
+	Name:    "wl_region",
+	Version: 1,
+	New:     func() Proxy { return new(Region) },
+	Requests: []Message{
+		{Name: "destroy"},
+		{Name: "add", Signature: "iiii"},
+		{Name: "subtract", Signature: "iiii"},
+	},
+}
+
+// Interface returns the description of the wl_region interface.
+func (p *Region) Interface() *Interface {
+	return regionInterface
+}
+
 // Destroy will destroy region.
 //
-//
 // Destroy the region.  This will invalidate the object ID.
-//
 func (p *Region) Destroy() error {
-	return p.Context().SendRequest(p, 0)
+	if err := p.Context().SendRequest(p, 0); err != nil {
+		return err
+	}
+	p.Context().Unregister(p)
+	return nil
 }
 
 // Add will add rectangle to region.
 //
-//
 // Add the specified rectangle to the region.
-//
 func (p *Region) Add(x int32, y int32, width int32, height int32) error {
 	return p.Context().SendRequest(p, 1, x, y, width, height)
 }
 
 // Subtract will subtract rectangle from region.
 //
-//
 // Subtract the specified rectangle from the region.
-//
 func (p *Region) Subtract(x int32, y int32, width int32, height int32) error {
 	return p.Context().SendRequest(p, 2, x, y, width, height)
 }
@@ -3907,20 +5356,39 @@ func NewSubcompositor(ctx *Context) *Subcompositor {
 	return ret
 }
 
+var subcompositorInterface = &Interface{
+	Name:    "wl_subcompositor",
+	Version: 1,
+	New:     func() Proxy { return new(Subcompositor) },
+	Requests: []Message{
+		{Name: "destroy"},
+		{Name: "get_subsurface", Signature: "noo", Types: []string{"wl_subsurface", "wl_surface", "wl_surface"}},
+	},
+	Errors: map[uint32]string{
+		SubcompositorErrorBadSurface: "bad_surface",
+	},
+}
+
+// Interface returns the description of the wl_subcompositor interface.
+func (p *Subcompositor) Interface() *Interface {
+	return subcompositorInterface
+}
+
 // Destroy will unbind from the subcompositor interface.
 //
-//
 // Informs the server that the client will not be using this
 // protocol object anymore. This does not affect any other
 // objects, wl_subsurface objects included.
-//
 func (p *Subcompositor) Destroy() error {
-	return p.Context().SendRequest(p, 0)
+	if err := p.Context().SendRequest(p, 0); err != nil {
+		return err
+	}
+	p.Context().Unregister(p)
+	return nil
 }
 
 // GetSubsurface will give a surface the role sub-surface.
 //
-//
 // Create a sub-surface interface for the given surface, and
 // associate it with the given parent surface. This turns a
 // plain wl_surface into a sub-surface.
@@ -3936,12 +5404,18 @@ func (p *Subcompositor) Destroy() error {
 //
 // This request modifies the behaviour of wl_surface.commit request on
 // the sub-surface, see the documentation on wl_subsurface interface.
-//
 func (p *Subcompositor) GetSubsurface(surface *Surface, parent *Surface) (*Subsurface, error) {
 	ret := NewSubsurface(p.Context())
-	return ret, p.Context().SendRequest(p, 1, Proxy(ret), surface, parent)
+	ret.SetQueue(p.Queue())
+	ret.SetVersion(p.Version())
+	if err := p.Context().SendRequest(p, 1, Proxy(ret), surface, parent); err != nil {
+		p.Context().Discard(ret)
+		return nil, err
+	}
+	return ret, nil
 }
 
+// Error codes of wl_subcompositor, reported with wl_display.error.
 const (
 	SubcompositorErrorBadSurface = 0
 )
@@ -3956,22 +5430,45 @@ func NewSubsurface(ctx *Context) *Subsurface {
 	return ret
 }
 
+var subsurfaceInterface = &Interface{
+	Name:    "wl_subsurface",
+	Version: 1,
+	New:     func() Proxy { return new(Subsurface) },
+	Requests: []Message{
+		{Name: "destroy"},
+		{Name: "set_position", Signature: "ii"},
+		{Name: "place_above", Signature: "o", Types: []string{"wl_surface"}},
+		{Name: "place_below", Signature: "o", Types: []string{"wl_surface"}},
+		{Name: "set_sync"},
+		{Name: "set_desync"},
+	},
+	Errors: map[uint32]string{
+		SubsurfaceErrorBadSurface: "bad_surface",
+	},
+}
+
+// Interface returns the description of the wl_subsurface interface.
+func (p *Subsurface) Interface() *Interface {
+	return subsurfaceInterface
+}
+
 // Destroy will remove sub-surface interface.
 //
-//
 // The sub-surface interface is removed from the wl_surface object
 // that was turned into a sub-surface with a
 // wl_subcompositor.get_subsurface request. The wl_surface's association
 // to the parent is deleted, and the wl_surface loses its role as
 // a sub-surface. The wl_surface is unmapped immediately.
-//
 func (p *Subsurface) Destroy() error {
-	return p.Context().SendRequest(p, 0)
+	if err := p.Context().SendRequest(p, 0); err != nil {
+		return err
+	}
+	p.Context().Unregister(p)
+	return nil
 }
 
 // SetPosition will reposition the sub-surface.
 //
-//
 // This schedules a sub-surface position change.
 // The sub-surface will be moved so that its origin (top left
 // corner pixel) will be at the location x, y of the parent surface
@@ -3988,14 +5485,12 @@ func (p *Subsurface) Destroy() error {
 // replaces the scheduled position from any previous request.
 //
 // The initial position is 0, 0.
-//
 func (p *Subsurface) SetPosition(x int32, y int32) error {
 	return p.Context().SendRequest(p, 1, x, y)
 }
 
 // PlaceAbove will restack the sub-surface.
 //
-//
 // This sub-surface is taken from the stack, and put back just
 // above the reference surface, changing the z-order of the sub-surfaces.
 // The reference surface must be one of the sibling surfaces, or the
@@ -4011,24 +5506,20 @@ func (p *Subsurface) SetPosition(x int32, y int32) error {
 //
 // A new sub-surface is initially added as the top-most in the stack
 // of its siblings and parent.
-//
 func (p *Subsurface) PlaceAbove(sibling *Surface) error {
 	return p.Context().SendRequest(p, 2, sibling)
 }
 
 // PlaceBelow will restack the sub-surface.
 //
-//
 // The sub-surface is placed just below the reference surface.
 // See wl_subsurface.place_above.
-//
 func (p *Subsurface) PlaceBelow(sibling *Surface) error {
 	return p.Context().SendRequest(p, 3, sibling)
 }
 
 // SetSync will set sub-surface to synchronized mode.
 //
-//
 // Change the commit behaviour of the sub-surface to synchronized
 // mode, also described as the parent dependent mode.
 //
@@ -4042,14 +5533,12 @@ func (p *Subsurface) PlaceBelow(sibling *Surface) error {
 // parent surface commits do not (re-)apply old state.
 //
 // See wl_subsurface for the recursive effect of this mode.
-//
 func (p *Subsurface) SetSync() error {
 	return p.Context().SendRequest(p, 4)
 }
 
 // SetDesync will set sub-surface to desynchronized mode.
 //
-//
 // Change the commit behaviour of the sub-surface to desynchronized
 // mode, also described as independent or freely running mode.
 //
@@ -4069,11 +5558,36 @@ func (p *Subsurface) SetSync() error {
 //
 // If a surface's parent surface behaves as desynchronized, then
 // the cached state is applied on set_desync.
-//
 func (p *Subsurface) SetDesync() error {
 	return p.Context().SendRequest(p, 5)
 }
 
+// Error codes of wl_subsurface, reported with wl_display.error.
 const (
 	SubsurfaceErrorBadSurface = 0
 )
+
+func init() {
+	RegisterInterface(displayInterface)
+	RegisterInterface(registryInterface)
+	RegisterInterface(callbackInterface)
+	RegisterInterface(compositorInterface)
+	RegisterInterface(shmPoolInterface)
+	RegisterInterface(shmInterface)
+	RegisterInterface(bufferInterface)
+	RegisterInterface(dataOfferInterface)
+	RegisterInterface(dataSourceInterface)
+	RegisterInterface(dataDeviceInterface)
+	RegisterInterface(dataDeviceManagerInterface)
+	RegisterInterface(shellInterface)
+	RegisterInterface(shellSurfaceInterface)
+	RegisterInterface(surfaceInterface)
+	RegisterInterface(seatInterface)
+	RegisterInterface(pointerInterface)
+	RegisterInterface(keyboardInterface)
+	RegisterInterface(touchInterface)
+	RegisterInterface(outputInterface)
+	RegisterInterface(regionInterface)
+	RegisterInterface(subcompositorInterface)
+	RegisterInterface(subsurfaceInterface)
+}
@@ -0,0 +1,250 @@
+// Command wl-info prints the globals advertised by a Wayland server,
+// with the details of outputs, seats and shared memory formats.
+//
+// Usage:
+//
+//	wl-info [-json] [-display name]
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/dkolbly/wl"
+)
+
+type global struct {
+	Name      uint32  `json:"name"`
+	Interface string  `json:"interface"`
+	Version   uint32  `json:"version"`
+	Output    *output `json:"output,omitempty"`
+	Seat      *seat   `json:"seat,omitempty"`
+	Shm       *shm    `json:"shm,omitempty"`
+}
+
+type output struct {
+	X              int32  `json:"x"`
+	Y              int32  `json:"y"`
+	PhysicalWidth  int32  `json:"physical_width"`
+	PhysicalHeight int32  `json:"physical_height"`
+	Subpixel       string `json:"subpixel"`
+	Make           string `json:"make"`
+	Model          string `json:"model"`
+	Transform      string `json:"transform"`
+	Scale          int32  `json:"scale"`
+	Modes          []mode `json:"modes"`
+}
+
+type mode struct {
+	Width     int32 `json:"width"`
+	Height    int32 `json:"height"`
+	Refresh   int32 `json:"refresh"` // in mHz
+	Current   bool  `json:"current"`
+	Preferred bool  `json:"preferred"`
+}
+
+type seat struct {
+	Name         string   `json:"name"`
+	Capabilities []string `json:"capabilities"`
+}
+
+type shm struct {
+	Formats []string `json:"formats"`
+}
+
+func main() {
+	asJSON := flag.Bool("json", false, "print JSON")
+	display := flag.String("display", "", "display to connect to (default $WAYLAND_DISPLAY)")
+	timeout := flag.Duration("timeout", 5*time.Second, "how long to wait for the server")
+	flag.Parse()
+	log.SetFlags(0)
+	log.SetPrefix("wl-info: ")
+
+	d, err := wl.Connect(*display)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer d.Context().Close()
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+
+	globals, err := query(ctx, d)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(globals)
+	} else {
+		err = printText(os.Stdout, globals)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// query lists the globals of the server, and binds outputs, seats and
+// wl_shm to collect their details.
+func query(ctx context.Context, d *wl.Display) ([]*global, error) {
+	registry, err := d.GetRegistry()
+	if err != nil {
+		return nil, err
+	}
+	var globals []*global
+	cancel := registry.OnGlobal(func(ev wl.RegistryGlobalEvent) {
+		g := &global{Name: ev.Name, Interface: ev.Interface, Version: ev.Version}
+		globals = append(globals, g)
+		if err := bind(registry, ev, g); err != nil {
+			log.Printf("binding %s: %s", ev.Interface, err)
+		}
+	})
+	defer cancel()
+	// the first roundtrip brings the globals, the second the events
+	// of the objects bound
+	for i := 0; i < 2; i++ {
+		if err := d.Roundtrip(ctx); err != nil {
+			return nil, err
+		}
+	}
+	return globals, nil
+}
+
+func bind(registry *wl.Registry, ev wl.RegistryGlobalEvent, g *global) error {
+	c := registry.Context()
+	switch ev.Interface {
+	case "wl_output":
+		o := wl.NewOutput(c)
+		g.Output = &output{Scale: 1, Modes: []mode{}}
+		o.OnGeometry(func(ev wl.OutputGeometryEvent) {
+			g.Output.X, g.Output.Y = ev.X, ev.Y
+			g.Output.PhysicalWidth, g.Output.PhysicalHeight = ev.PhysicalWidth, ev.PhysicalHeight
+			g.Output.Subpixel = subpixels[ev.Subpixel]
+			g.Output.Make, g.Output.Model = ev.Make, ev.Model
+			g.Output.Transform = transforms[ev.Transform]
+		})
+		o.OnMode(func(ev wl.OutputModeEvent) {
+			g.Output.Modes = append(g.Output.Modes, mode{
+				Width:     ev.Width,
+				Height:    ev.Height,
+				Refresh:   ev.Refresh,
+				Current:   ev.Flags&wl.OutputModeCurrent != 0,
+				Preferred: ev.Flags&wl.OutputModePreferred != 0,
+			})
+		})
+		o.OnScale(func(ev wl.OutputScaleEvent) {
+			g.Output.Scale = ev.Factor
+		})
+		_, err := registry.BindGlobal(ev, o)
+		return err
+	case "wl_seat":
+		s := wl.NewSeat(c)
+		g.Seat = &seat{Capabilities: []string{}}
+		s.OnCapabilities(func(ev wl.SeatCapabilitiesEvent) {
+			g.Seat.Capabilities = []string{}
+			for _, capability := range capabilities {
+				if ev.Capabilities&capability.bit != 0 {
+					g.Seat.Capabilities = append(g.Seat.Capabilities, capability.name)
+				}
+			}
+		})
+		s.OnName(func(ev wl.SeatNameEvent) {
+			g.Seat.Name = ev.Name
+		})
+		_, err := registry.BindGlobal(ev, s)
+		return err
+	case "wl_shm":
+		s := wl.NewShm(c)
+		g.Shm = &shm{Formats: []string{}}
+		s.OnFormat(func(ev wl.ShmFormatEvent) {
+			g.Shm.Formats = append(g.Shm.Formats, formatName(ev.Format))
+		})
+		_, err := registry.BindGlobal(ev, s)
+		return err
+	}
+	return nil
+}
+
+func printText(w io.Writer, globals []*global) error {
+	var b strings.Builder
+	for _, g := range globals {
+		fmt.Fprintf(&b, "%s (name %d, version %d)\n", g.Interface, g.Name, g.Version)
+		if o := g.Output; o != nil {
+			fmt.Fprintf(&b, "\tmake: %q, model: %q\n", o.Make, o.Model)
+			fmt.Fprintf(&b, "\tposition: %d,%d, physical size: %dx%d mm\n", o.X, o.Y, o.PhysicalWidth, o.PhysicalHeight)
+			fmt.Fprintf(&b, "\tsubpixel: %s, transform: %s, scale: %d\n", o.Subpixel, o.Transform, o.Scale)
+			for _, m := range o.Modes {
+				var flags []string
+				if m.Current {
+					flags = append(flags, "current")
+				}
+				if m.Preferred {
+					flags = append(flags, "preferred")
+				}
+				fmt.Fprintf(&b, "\tmode: %dx%d @ %.3f Hz", m.Width, m.Height, float64(m.Refresh)/1000)
+				if len(flags) > 0 {
+					fmt.Fprintf(&b, " (%s)", strings.Join(flags, ", "))
+				}
+				b.WriteString("\n")
+			}
+		}
+		if s := g.Seat; s != nil {
+			fmt.Fprintf(&b, "\tname: %q\n", s.Name)
+			fmt.Fprintf(&b, "\tcapabilities: %s\n", strings.Join(s.Capabilities, " "))
+		}
+		if s := g.Shm; s != nil {
+			fmt.Fprintf(&b, "\tformats: %s\n", strings.Join(s.Formats, " "))
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+var subpixels = map[wl.OutputSubpixel]string{
+	wl.OutputSubpixelUnknown:       "unknown",
+	wl.OutputSubpixelNone:          "none",
+	wl.OutputSubpixelHorizontalRgb: "horizontal_rgb",
+	wl.OutputSubpixelHorizontalBgr: "horizontal_bgr",
+	wl.OutputSubpixelVerticalRgb:   "vertical_rgb",
+	wl.OutputSubpixelVerticalBgr:   "vertical_bgr",
+}
+
+var transforms = map[wl.OutputTransform]string{
+	wl.OutputTransformNormal:     "normal",
+	wl.OutputTransform90:         "90",
+	wl.OutputTransform180:        "180",
+	wl.OutputTransform270:        "270",
+	wl.OutputTransformFlipped:    "flipped",
+	wl.OutputTransformFlipped90:  "flipped_90",
+	wl.OutputTransformFlipped180: "flipped_180",
+	wl.OutputTransformFlipped270: "flipped_270",
+}
+
+var capabilities = []struct {
+	bit  wl.SeatCapability
+	name string
+}{
+	{wl.SeatCapabilityPointer, "pointer"},
+	{wl.SeatCapabilityKeyboard, "keyboard"},
+	{wl.SeatCapabilityTouch, "touch"},
+}
+
+// formatName returns the name of a wl_shm format.  Apart from the two
+// formats every compositor supports, the values are DRM fourcc codes.
+func formatName(format wl.ShmFormat) string {
+	switch format {
+	case wl.ShmFormatArgb8888:
+		return "ARGB8888"
+	case wl.ShmFormatXrgb8888:
+		return "XRGB8888"
+	}
+	fourcc := []byte{byte(format), byte(format >> 8), byte(format >> 16), byte(format >> 24)}
+	return strings.TrimRight(string(fourcc), " ")
+}
@@ -0,0 +1,289 @@
+// Command wl-proxy sits between Wayland clients and the compositor,
+// and prints every message they exchange, decoded with the protocol
+// descriptions of package wl, in the format of WAYLAND_DEBUG.
+//
+// It listens on its own socket and connects every client that joins
+// to the real compositor.  Unlike WAYLAND_DEBUG, it works with any
+// client, without recompiling or restarting it with special settings:
+//
+//	wl-proxy -socket wayland-debug &
+//	WAYLAND_DISPLAY=wayland-debug some-client
+//
+// Usage:
+//
+//	wl-proxy [-socket name] [-display name] [-o file]
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/dkolbly/wl"
+	_ "github.com/dkolbly/wl/xdg"
+	_ "github.com/dkolbly/wl/xdg-unstable-v6"
+)
+
+func main() {
+	socket := flag.String("socket", "wayland-proxy", "socket to listen on for clients")
+	display := flag.String("display", "", "compositor to connect clients to (default $WAYLAND_DISPLAY)")
+	out := flag.String("o", "", "file to print the messages to (default stdout)")
+	flag.Parse()
+	log.SetFlags(0)
+	log.SetPrefix("wl-proxy: ")
+
+	if err := run(*socket, *display, *out); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// run listens on socket and forwards every client to display, until
+// interrupted.
+func run(socket, display, out string) error {
+	upstream, err := socketPath(display)
+	if err != nil {
+		return err
+	}
+	path, err := socketPath(socket)
+	if err != nil {
+		return err
+	}
+	if path == upstream {
+		return fmt.Errorf("%s is the compositor's socket", path)
+	}
+	w := io.Writer(os.Stdout)
+	if out != "" {
+		f, err := os.Create(out)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+	p := &printer{w: w}
+
+	removeStale(path)
+	l, err := net.ListenUnix("unix", &net.UnixAddr{Name: path, Net: "unix"})
+	if err != nil {
+		return err
+	}
+	defer l.Close()
+	log.Printf("listening on %s, forwarding to %s", path, upstream)
+
+	// closing the listener removes the socket
+	var interrupted int32
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-sig
+		atomic.StoreInt32(&interrupted, 1)
+		l.Close()
+	}()
+
+	var clients int32
+	for {
+		conn, err := l.AcceptUnix()
+		if err != nil {
+			if atomic.LoadInt32(&interrupted) != 0 {
+				return nil
+			}
+			return err
+		}
+		server, err := net.DialUnix("unix", nil, &net.UnixAddr{Name: upstream, Net: "unix"})
+		if err != nil {
+			log.Print(err)
+			conn.Close()
+			continue
+		}
+		s := newSession(atomic.AddInt32(&clients, 1), conn, server, p)
+		go s.run()
+	}
+}
+
+// removeStale removes the socket at path if nobody listens on it, as
+// left behind by a proxy that was killed.
+func removeStale(path string) {
+	conn, err := net.Dial("unix", path)
+	if err == nil {
+		conn.Close()
+		return
+	}
+	if errors.Is(err, syscall.ECONNREFUSED) {
+		os.Remove(path)
+	}
+}
+
+// socketPath returns the path of the named socket, which defaults to
+// WAYLAND_DISPLAY and then wayland-0, like wl.Connect.
+func socketPath(name string) (string, error) {
+	if name == "" {
+		name = os.Getenv("WAYLAND_DISPLAY")
+	}
+	if name == "" {
+		name = "wayland-0"
+	}
+	if filepath.IsAbs(name) {
+		return name, nil
+	}
+	dir := os.Getenv("XDG_RUNTIME_DIR")
+	if dir == "" {
+		return "", fmt.Errorf("XDG_RUNTIME_DIR not set in the environment.")
+	}
+	return filepath.Join(dir, name), nil
+}
+
+// printer prints the messages of all clients, one line each.
+type printer struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (p *printer) print(client int32, m wl.TraceMessage) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	fmt.Fprintf(p.w, "client %d: %s\n", client, m)
+}
+
+// session forwards the messages between a client and the compositor,
+// tracking the objects either side creates, so as to know how to
+// decode the messages addressed to them.
+type session struct {
+	id      int32
+	client  *wl.Conn
+	server  *wl.Conn
+	printer *printer
+
+	mu      sync.Mutex
+	objects map[wl.ProxyId]*wl.Interface
+}
+
+func newSession(id int32, client, server *net.UnixConn, p *printer) *session {
+	return &session{
+		id:      id,
+		client:  wl.NewConn(client),
+		server:  wl.NewConn(server),
+		printer: p,
+		objects: map[wl.ProxyId]*wl.Interface{1: wl.LookupInterface("wl_display")},
+	}
+}
+
+func (s *session) run() {
+	done := make(chan struct{}, 2)
+	go func() {
+		s.forward(s.client, s.server, true)
+		done <- struct{}{}
+	}()
+	go func() {
+		s.forward(s.server, s.client, false)
+		done <- struct{}{}
+	}()
+	// when either side hangs up, so does the other
+	<-done
+	s.client.Close()
+	s.server.Close()
+	<-done
+	log.Printf("client %d disconnected", s.id)
+}
+
+// forward copies the messages read from src to dst, requests if sent
+// is set, events otherwise.  File descriptors are forwarded with the
+// message they arrived with, whatever its signature, so that messages
+// of protocols package wl does not know pass through intact.
+func (s *session) forward(src, dst *wl.Conn, sent bool) {
+	for {
+		msg, err := src.ReadMessage()
+		if err != nil {
+			return
+		}
+		src.ClaimPendingFds(msg)
+		s.mu.Lock()
+		iface := s.objects[msg.Id()]
+		s.mu.Unlock()
+		desc := describe(iface, sent, msg.Opcode)
+		fwd := msg.Request()
+		s.print(msg, iface, desc, sent)
+		err = dst.WriteMessage(fwd)
+		msg.CloseFds()
+		if err != nil {
+			return
+		}
+	}
+}
+
+// describe returns the description of message opcode of iface, or nil.
+func describe(iface *wl.Interface, sent bool, opcode uint32) *wl.Message {
+	if iface == nil {
+		return nil
+	}
+	msgs := iface.Events
+	if sent {
+		msgs = iface.Requests
+	}
+	if int(opcode) >= len(msgs) {
+		return nil
+	}
+	return &msgs[opcode]
+}
+
+// print decodes msg and prints it, recording the objects it creates
+// or deletes.
+func (s *session) print(msg *wl.Event, iface *wl.Interface, desc *wl.Message, sent bool) {
+	m := wl.TraceMessage{
+		Time:    time.Now(),
+		Sent:    sent,
+		Object:  msg.Id(),
+		Message: fmt.Sprint(msg.Opcode),
+	}
+	if iface != nil {
+		m.Interface = iface.Name
+	}
+	if desc != nil {
+		m.Message = desc.Name
+		m.Args = msg.FormatArgs(desc, s.lookup)
+		s.record(m.Args)
+		if iface.Name == "wl_display" && desc.Name == "delete_id" && len(m.Args) == 1 {
+			if id, err := strconv.ParseUint(m.Args[0], 10, 32); err == nil {
+				s.mu.Lock()
+				delete(s.objects, wl.ProxyId(id))
+				s.mu.Unlock()
+			}
+		}
+	}
+	s.printer.print(s.id, m)
+}
+
+// record records the objects created by a message, from its
+// arguments as formatted by FormatArgs.
+func (s *session) record(args []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, arg := range args {
+		obj, ok := strings.CutPrefix(arg, "new id ")
+		if !ok {
+			continue
+		}
+		at := strings.LastIndexByte(obj, '@')
+		if id, err := strconv.ParseUint(obj[at+1:], 10, 32); err == nil {
+			s.objects[wl.ProxyId(id)] = wl.LookupInterface(obj[:at])
+		}
+	}
+}
+
+// lookup returns the interface of the object with the given id, or nil.
+func (s *session) lookup(id wl.ProxyId) *wl.Interface {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.objects[id]
+}
@@ -0,0 +1,297 @@
+// Command wl-replay plays the server side of a recorded Wayland session
+// to a client under test, to reproduce input handling bugs
+// deterministically.
+//
+// Recordings are made with wl.Recorder, or by running a client with
+// WL_RECORD set to the name of the file to record to.  Every recorded
+// event is sent once the client has sent as many requests as it had
+// when the event was recorded, so that a client behaving the same way
+// sees the same conversation.  Requests differing in object or opcode
+// from the recorded ones are reported.  File descriptors are replaced
+// with files holding the recorded contents, or with /dev/null.
+//
+// Usage:
+//
+//	wl-replay [flags] recording [command [args...]]
+//
+// With a command, wl-replay runs it connected through WAYLAND_SOCKET.
+// Otherwise it waits for one client on $XDG_RUNTIME_DIR/wayland-replay,
+// or the socket named by -socket.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/dkolbly/wl"
+)
+
+func main() {
+	socket := flag.String("socket", "wayland-replay", "socket to wait for the client on, without a command")
+	timing := flag.Bool("timing", false, "keep the recorded delays between events")
+	timeout := flag.Duration("timeout", 5*time.Second, "how long to wait for the requests an event follows")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: %s [flags] recording [command [args...]]\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() < 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	log.SetFlags(0)
+	log.SetPrefix("wl-replay: ")
+
+	msgs, err := load(flag.Arg(0))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var conn *net.UnixConn
+	var cmd *exec.Cmd
+	if flag.NArg() > 1 {
+		conn, cmd, err = start(flag.Args()[1:])
+	} else {
+		conn, err = accept(*socket)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	r := &replayer{
+		conn:    wl.NewConn(conn),
+		msgs:    msgs,
+		timing:  *timing,
+		timeout: *timeout,
+	}
+	r.cond = sync.NewCond(&r.mu)
+	go r.read()
+	err = r.replay()
+	if cmd != nil {
+		if err != nil {
+			cmd.Process.Kill()
+		}
+		if werr := cmd.Wait(); err == nil {
+			err = werr
+		}
+	} else if err == nil {
+		r.wait()
+	}
+	r.conn.Close()
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+func load(name string) ([]*wl.RecordedMessage, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	rr, err := wl.NewRecordReader(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", name, err)
+	}
+	var msgs []*wl.RecordedMessage
+	for {
+		m, err := rr.Next()
+		if err == io.EOF {
+			return msgs, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", name, err)
+		}
+		msgs = append(msgs, m)
+	}
+}
+
+// start runs the client, connected to the returned socket through
+// WAYLAND_SOCKET.
+func start(args []string) (*net.UnixConn, *exec.Cmd, error) {
+	c, end, err := wl.SocketPair()
+	if err != nil {
+		return nil, nil, err
+	}
+	// the child gets a copy of the client's end
+	client, err := end.File()
+	end.Close()
+	if err != nil {
+		c.Close()
+		return nil, nil, err
+	}
+	defer client.Close()
+
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	cmd.ExtraFiles = []*os.File{client}
+	// the first of ExtraFiles is fd 3 in the child
+	cmd.Env = append(os.Environ(), "WAYLAND_SOCKET=3")
+	if err := cmd.Start(); err != nil {
+		c.Close()
+		return nil, nil, err
+	}
+	return c, cmd, nil
+}
+
+// accept waits for one client on the named socket.
+func accept(name string) (*net.UnixConn, error) {
+	if !filepath.IsAbs(name) {
+		dir := os.Getenv("XDG_RUNTIME_DIR")
+		if dir == "" {
+			return nil, fmt.Errorf("XDG_RUNTIME_DIR not set in the environment.")
+		}
+		name = filepath.Join(dir, name)
+	}
+	l, err := net.ListenUnix("unix", &net.UnixAddr{Name: name, Net: "unix"})
+	if err != nil {
+		return nil, err
+	}
+	defer l.Close()
+	log.Printf("waiting for a client on %s", name)
+	return l.AcceptUnix()
+}
+
+type replayer struct {
+	conn    *wl.Conn
+	msgs    []*wl.RecordedMessage
+	timing  bool
+	timeout time.Duration
+
+	mu       sync.Mutex
+	cond     *sync.Cond
+	requests int   // the number of requests read
+	err      error // the error that ended reading
+}
+
+// read reads the requests of the client, and checks them against the
+// recorded ones.
+func (r *replayer) read() {
+	var recorded []*wl.RecordedMessage
+	for _, m := range r.msgs {
+		if m.Sent {
+			recorded = append(recorded, m)
+		}
+	}
+	for {
+		req, err := r.conn.ReadMessage()
+		if err != nil {
+			r.mu.Lock()
+			r.err = err
+			r.cond.Broadcast()
+			r.mu.Unlock()
+			return
+		}
+		r.mu.Lock()
+		n := r.requests
+		r.mu.Unlock()
+		if n < len(recorded) {
+			m := recorded[n]
+			if req.Id() != m.Object || req.Opcode != m.Opcode {
+				log.Printf("request %d: got %d of object %d, recorded %d of object %d",
+					n, req.Opcode, req.Id(), m.Opcode, m.Object)
+			}
+			// the recording tells how many fds go with the request
+			r.conn.ClaimFds(req, len(m.Files))
+			req.CloseFds()
+		}
+		r.mu.Lock()
+		r.requests++
+		r.cond.Broadcast()
+		r.mu.Unlock()
+	}
+}
+
+// waitRequests waits until the client sent n requests.
+func (r *replayer) waitRequests(n int) error {
+	timer := time.AfterFunc(r.timeout, func() {
+		r.mu.Lock()
+		r.cond.Broadcast()
+		r.mu.Unlock()
+	})
+	defer timer.Stop()
+	deadline := time.Now().Add(r.timeout)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for r.requests < n {
+		if r.err != nil {
+			return fmt.Errorf("client gone after %d of %d requests: %v", r.requests, n, r.err)
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("client stopped after %d of %d requests", r.requests, n)
+		}
+		r.cond.Wait()
+	}
+	return nil
+}
+
+// wait waits until the client disconnects.
+func (r *replayer) wait() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for r.err == nil {
+		r.cond.Wait()
+	}
+}
+
+// replay sends the recorded events in order.
+func (r *replayer) replay() error {
+	requests := 0
+	start := time.Now()
+	for _, m := range r.msgs {
+		if m.Sent {
+			requests++
+			continue
+		}
+		if err := r.waitRequests(requests); err != nil {
+			return err
+		}
+		if r.timing {
+			time.Sleep(time.Until(start.Add(m.Time)))
+		}
+		if err := r.send(m); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *replayer) send(m *wl.RecordedMessage) error {
+	var fds []uintptr
+	for _, contents := range m.Files {
+		f, err := standIn(contents)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		fds = append(fds, f.Fd())
+	}
+	return r.conn.WriteMessage(m.Request(fds...))
+}
+
+// standIn returns a file holding contents, or /dev/null for a file
+// descriptor whose contents were not recorded.
+func standIn(contents []byte) (*os.File, error) {
+	if contents == nil {
+		return os.Open(os.DevNull)
+	}
+	f, err := os.CreateTemp("", "wl-replay")
+	if err != nil {
+		return nil, err
+	}
+	os.Remove(f.Name())
+	if _, err := f.Write(contents); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return f, nil
+}
@@ -0,0 +1,613 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"go/token"
+	"strings"
+)
+
+type generator struct {
+	proto  *Protocol
+	pkg    string
+	prefix string
+	suffix string
+	source string
+	local  map[string]bool
+	// eventUses and requestUses list the arguments of events and
+	// requests referencing each enum, by qualified enum name
+	eventUses   map[string][]string
+	requestUses map[string][]string
+	buf         bytes.Buffer
+}
+
+func newGenerator(proto *Protocol, pkg, prefix, suffix, source string) *generator {
+	g := &generator{
+		proto:       proto,
+		pkg:         pkg,
+		prefix:      prefix,
+		suffix:      suffix,
+		source:      source,
+		local:       make(map[string]bool),
+		eventUses:   make(map[string][]string),
+		requestUses: make(map[string][]string),
+	}
+	for _, iface := range proto.Interfaces {
+		g.local[iface.Name] = true
+		addUses(g.requestUses, iface.Name, iface.Requests)
+		addUses(g.eventUses, iface.Name, iface.Events)
+	}
+	return g
+}
+
+func addUses(uses map[string][]string, iface string, msgs []Message) {
+	for _, m := range msgs {
+		for _, arg := range m.Args {
+			if arg.Enum != "" {
+				use := fmt.Sprintf("the %s argument of %s.%s", arg.Name, iface, m.Name)
+				uses[arg.Enum] = append(uses[arg.Enum], use)
+			}
+		}
+	}
+}
+
+func (g *generator) printf(format string, args ...interface{}) {
+	fmt.Fprintf(&g.buf, format, args...)
+}
+
+// wl qualifies identifiers of the core package when generating code
+// for another package.
+func (g *generator) wl(ident string) string {
+	if g.pkg == "wl" {
+		return ident
+	}
+	return "wl." + ident
+}
+
+// camel turns a protocol name like get_xdg_surface into GetXdgSurface.
+func camel(name string) string {
+	var b strings.Builder
+	for _, w := range strings.Split(name, "_") {
+		if w == "" {
+			continue
+		}
+		b.WriteString(strings.ToUpper(w[:1]))
+		b.WriteString(w[1:])
+	}
+	return b.String()
+}
+
+// wrap breaks text into lines of at most width bytes, unless a single
+// word is longer.
+func wrap(text string, width int) []string {
+	var lines []string
+	line := ""
+	for _, word := range strings.Split(text, " ") {
+		switch {
+		case line == "":
+			line = word
+		case len(line)+1+len(word) > width:
+			lines = append(lines, strings.TrimRight(line, " "))
+			line = word
+		default:
+			line += " " + word
+		}
+	}
+	return append(lines, line)
+}
+
+func lowerFirst(s string) string {
+	if s == "" {
+		return s
+	}
+	return strings.ToLower(s[:1]) + s[1:]
+}
+
+// typeName returns the unqualified Go type of a protocol interface.
+func (g *generator) typeName(iface string) string {
+	if g.local[iface] {
+		return camel(strings.TrimSuffix(strings.TrimPrefix(iface, g.prefix), g.suffix))
+	}
+	return camel(strings.TrimPrefix(iface, "wl_"))
+}
+
+// goType returns the Go type of a protocol interface as seen from the
+// generated package.  Interfaces not declared in the protocol being
+// generated are taken from the core wayland protocol in package wl.
+func (g *generator) goType(iface string) string {
+	if g.local[iface] || g.pkg == "wl" {
+		return g.typeName(iface)
+	}
+	return "wl." + g.typeName(iface)
+}
+
+// constructor returns the New function of a protocol interface.
+func (g *generator) constructor(iface string) string {
+	if g.local[iface] || g.pkg == "wl" {
+		return "New" + g.typeName(iface)
+	}
+	return "wl.New" + g.typeName(iface)
+}
+
+func paramName(name string) string {
+	if token.Lookup(name).IsKeyword() {
+		return name + "_"
+	}
+	return name
+}
+
+// enumType returns the Go type of the enum referenced by arg, an
+// argument of an event, or the empty string.  Requests take enum
+// values as plain integers.
+func (g *generator) enumType(arg Arg) string {
+	if arg.Enum == "" {
+		return ""
+	}
+	dot := strings.IndexByte(arg.Enum, '.')
+	return g.goType(arg.Enum[:dot]) + camel(arg.Enum[dot+1:])
+}
+
+// eventArgType returns the Go type of an argument of an event.
+func (g *generator) eventArgType(arg Arg) string {
+	if t := g.enumType(arg); t != "" {
+		return t
+	}
+	return g.argType(arg)
+}
+
+func (g *generator) argType(arg Arg) string {
+	switch arg.Type {
+	case "int":
+		return "int32"
+	case "uint":
+		return "uint32"
+	case "fixed":
+		return g.wl("Fixed")
+	case "string":
+		return "string"
+	case "array":
+		return g.wl("Array")
+	case "fd":
+		return "uintptr"
+	case "object", "new_id":
+		if arg.Interface == "" {
+			return g.wl("Proxy")
+		}
+		return "*" + g.goType(arg.Interface)
+	}
+	panic(fmt.Sprintf("unknown argument type %q", arg.Type))
+}
+
+func signature(args []Arg) string {
+	var b strings.Builder
+	for _, arg := range args {
+		if arg.AllowNull {
+			b.WriteByte('?')
+		}
+		switch arg.Type {
+		case "int":
+			b.WriteByte('i')
+		case "uint":
+			b.WriteByte('u')
+		case "fixed":
+			b.WriteByte('f')
+		case "string":
+			b.WriteByte('s')
+		case "object":
+			b.WriteByte('o')
+		case "new_id":
+			if arg.Interface == "" {
+				// sent as interface name, version and id
+				b.WriteString("su")
+			}
+			b.WriteByte('n')
+		case "array":
+			b.WriteByte('a')
+		case "fd":
+			b.WriteByte('h')
+		}
+	}
+	return b.String()
+}
+
+func (g *generator) generate() ([]byte, error) {
+	g.printf("// Code generated by wl-scanner from %s. DO NOT EDIT.\n\n", g.source)
+	g.printf("// Package %s acts as a client for the %s protocol.\n", g.pkg, g.proto.Name)
+	g.printf("package %s\n\n", g.pkg)
+	g.printf("import (\n\t\"context\"\n\t\"sync\"\n")
+	if g.pkg != "wl" {
+		g.printf("\n\t\"github.com/dkolbly/wl\"\n")
+	}
+	g.printf(")\n\n")
+
+	for i := range g.proto.Interfaces {
+		g.genInterface(&g.proto.Interfaces[i])
+	}
+
+	g.printf("func init() {\n")
+	for _, iface := range g.proto.Interfaces {
+		g.printf("%s(%sInterface)\n", g.wl("RegisterInterface"), lowerFirst(g.typeName(iface.Name)))
+	}
+	g.printf("}\n")
+
+	src, err := format.Source(g.buf.Bytes())
+	if err != nil {
+		return g.buf.Bytes(), err
+	}
+	return src, nil
+}
+
+func (g *generator) genInterface(iface *Interface) {
+	typ := g.typeName(iface.Name)
+
+	for i := range iface.Events {
+		g.genEventHandling(typ, &iface.Events[i])
+	}
+	if len(iface.Events) > 0 {
+		g.genDispatch(typ, iface)
+	}
+
+	g.printf("type %s struct {\n", typ)
+	g.printf("%s\n", g.wl("BaseProxy"))
+	if len(iface.Events) > 0 {
+		g.printf("mu sync.RWMutex\n")
+		for _, ev := range iface.Events {
+			name := camel(ev.Name)
+			g.printf("%sHandlers []%s%sHandler\n", lowerFirst(name), typ, name)
+		}
+	}
+	g.printf("}\n\n")
+
+	g.printf("func New%s(ctx *%s) *%s {\n", typ, g.wl("Context"), typ)
+	g.printf("ret := new(%s)\n", typ)
+	g.printf("ctx.Register(ret)\n")
+	g.printf("return ret\n")
+	g.printf("}\n\n")
+
+	g.genDescription(typ, iface)
+
+	for i := range iface.Requests {
+		g.genRequest(typ, i, &iface.Requests[i])
+	}
+
+	for i := range iface.Enums {
+		g.genEnum(typ, iface, &iface.Enums[i])
+	}
+}
+
+func (g *generator) genEnum(typ string, iface *Interface, enum *Enum) {
+	name := typ + camel(enum.Name)
+	kind := "enum"
+	if enum.Bitfield {
+		kind = "bitfield"
+	}
+	summary := ""
+	if s := enum.Description.Summary; s != "" {
+		summary = ": " + s
+	}
+	// enums get a type when events carry their values, or when no
+	// argument does, as their values may come in arrays
+	qualified := iface.Name + "." + enum.Name
+	events, requests := g.eventUses[qualified], g.requestUses[qualified]
+	typed := !enum.IsError() && (len(events) > 0 || len(requests) == 0)
+	var doc string
+	switch {
+	case enum.IsError():
+		doc = fmt.Sprintf("Error codes of %s, reported with wl_display.error%s.", iface.Name, summary)
+	case typed:
+		doc = fmt.Sprintf("%s is the %s %s of %s%s.", name, enum.Name, kind, iface.Name, summary)
+		if len(events) > 0 {
+			doc += "  It is the type of " + list(events) + "."
+		}
+	default:
+		doc = fmt.Sprintf("Values of the %s %s of %s%s, for %s.", enum.Name, kind, iface.Name, summary, list(requests))
+	}
+	for _, l := range wrap(doc, 70) {
+		g.printf("// %s\n", l)
+	}
+	if lines := enum.Description.Lines(); len(lines) > 0 {
+		g.printf("//\n")
+		for _, l := range lines {
+			if l == "" {
+				g.printf("//\n")
+			} else {
+				g.printf("// %s\n", l)
+			}
+		}
+	}
+	if typed {
+		// the constants stay untyped, so that they can be passed
+		// to requests as well
+		g.printf("type %s uint32\n\n", name)
+	}
+	g.printf("const (\n")
+	for _, e := range enum.Entries {
+		g.printf("%s%s = %s", name, camel(e.Name), e.Value)
+		if e.Summary != "" {
+			g.printf(" // %s", e.Summary)
+		}
+		g.printf("\n")
+	}
+	g.printf(")\n\n")
+}
+
+// list joins items into an English list.
+func list(items []string) string {
+	if n := len(items); n > 1 {
+		return strings.Join(items[:n-1], ", ") + " and " + items[n-1]
+	}
+	return strings.Join(items, "")
+}
+
+func (g *generator) genEventHandling(typ string, ev *Message) {
+	name := typ + camel(ev.Name)
+	field := lowerFirst(camel(ev.Name)) + "Handlers"
+
+	g.printf("type %sEvent struct {\n", name)
+	g.printf("EventContext context.Context\n")
+	for _, arg := range ev.Args {
+		g.printf("%s %s\n", camel(arg.Name), g.eventArgType(arg))
+	}
+	g.printf("}\n\n")
+
+	g.printf("type %sHandler interface {\n", name)
+	g.printf("Handle%s(%sEvent)\n", name, name)
+	g.printf("}\n\n")
+
+	g.printf("func (p *%s) Add%sHandler(h %sHandler) {\n", typ, camel(ev.Name), name)
+	g.printf("if h != nil {\n")
+	g.printf("p.mu.Lock()\n")
+	g.printf("p.%s = append(p.%s, h)\n", field, field)
+	g.printf("p.mu.Unlock()\n")
+	g.printf("}\n")
+	g.printf("}\n\n")
+
+	g.printf("func (p *%s) Remove%sHandler(h %sHandler) {\n", typ, camel(ev.Name), name)
+	g.printf("p.mu.Lock()\n")
+	g.printf("defer p.mu.Unlock()\n\n")
+	g.printf("for i, e := range p.%s {\n", field)
+	g.printf("if e == h {\n")
+	g.printf("// copied, as Dispatch may be iterating over the old slice\n")
+	g.printf("p.%s = append(p.%s[:i:i], p.%s[i+1:]...)\n", field, field, field)
+	g.printf("break\n")
+	g.printf("}\n")
+	g.printf("}\n")
+	g.printf("}\n\n")
+
+	// handlers made from funcs are pointers, as funcs are not
+	// comparable
+	fn := lowerFirst(name) + "Func"
+	g.printf("type %s struct {\n", fn)
+	g.printf("f func(%sEvent)\n", name)
+	g.printf("}\n\n")
+	g.printf("func (h *%s) Handle%s(ev %sEvent) {\n", fn, name, name)
+	g.printf("h.f(ev)\n")
+	g.printf("}\n\n")
+
+	g.printf("// On%s calls f for every %s event, until cancel is called.\n", camel(ev.Name), ev.Name)
+	g.printf("func (p *%s) On%s(f func(%sEvent)) (cancel func()) {\n", typ, camel(ev.Name), name)
+	g.printf("h := &%s{f}\n", fn)
+	g.printf("p.Add%sHandler(h)\n", camel(ev.Name))
+	g.printf("return func() { p.Remove%sHandler(h) }\n", camel(ev.Name))
+	g.printf("}\n\n")
+}
+
+func (g *generator) genDispatch(typ string, iface *Interface) {
+	g.printf("func (p *%s) Dispatch(ctx context.Context, event *%s) {\n", typ, g.wl("Event"))
+	g.printf("switch event.Opcode {\n")
+	for i, ev := range iface.Events {
+		name := typ + camel(ev.Name)
+		field := lowerFirst(camel(ev.Name)) + "Handlers"
+		g.printf("case %d:\n", i)
+		// handlers run without the lock, so that they can add and
+		// remove handlers
+		g.printf("p.mu.RLock()\n")
+		g.printf("handlers := p.%s\n", field)
+		g.printf("p.mu.RUnlock()\n")
+		g.printf("if len(handlers) > 0 {\n")
+		g.printf("ev := %sEvent{}\n", name)
+		g.printf("ev.EventContext = ctx\n")
+		for _, arg := range ev.Args {
+			g.genDecode(camel(arg.Name), arg)
+		}
+		g.printf("for _, h := range handlers {\n")
+		g.printf("h.Handle%s(ev)\n", name)
+		g.printf("}\n")
+		g.printf("}\n")
+	}
+	g.printf("}\n")
+	g.printf("}\n\n")
+}
+
+func (g *generator) genDecode(field string, arg Arg) {
+	switch arg.Type {
+	case "int", "uint":
+		decode := "event.Int32()"
+		if arg.Type == "uint" {
+			decode = "event.Uint32()"
+		}
+		if t := g.enumType(arg); t != "" {
+			decode = t + "(" + decode + ")"
+		}
+		g.printf("ev.%s = %s\n", field, decode)
+	case "fixed":
+		g.printf("ev.%s = event.Fixed()\n", field)
+	case "string":
+		g.printf("ev.%s = event.String()\n", field)
+	case "array":
+		g.printf("ev.%s = event.Array()\n", field)
+	case "fd":
+		g.printf("ev.%s = event.FD()\n", field)
+	case "object", "new_id":
+		if arg.Interface == "" {
+			g.printf("ev.%s = event.Proxy(p.Context())\n", field)
+		} else {
+			g.printf("ev.%s, _ = event.Proxy(p.Context()).(*%s)\n", field, g.goType(arg.Interface))
+		}
+	default:
+		panic(fmt.Sprintf("unknown argument type %q", arg.Type))
+	}
+}
+
+// genDescription emits the protocol metadata the Context and the
+// server use to decode messages without going through Dispatch.
+func (g *generator) genDescription(typ string, iface *Interface) {
+	v := lowerFirst(typ) + "Interface"
+	g.printf("var %s = &%s{\n", v, g.wl("Interface"))
+	g.printf("Name: %q,\n", iface.Name)
+	g.printf("Version: %d,\n", iface.Version)
+	g.printf("New: func() %s { return new(%s) },\n", g.wl("Proxy"), typ)
+	if len(iface.Requests) > 0 {
+		g.printf("Requests: []%s{\n", g.wl("Message"))
+		for _, req := range iface.Requests {
+			g.genMessage(req)
+		}
+		g.printf("},\n")
+	}
+	if len(iface.Events) > 0 {
+		g.printf("Events: []%s{\n", g.wl("Message"))
+		for _, ev := range iface.Events {
+			g.genMessage(ev)
+		}
+		g.printf("},\n")
+	}
+	for _, enum := range iface.Enums {
+		if enum.Name != "error" {
+			continue
+		}
+		g.printf("Errors: map[uint32]string{\n")
+		for _, e := range enum.Entries {
+			g.printf("%s%s%s: %q,\n", typ, camel(enum.Name), camel(e.Name), e.Name)
+		}
+		g.printf("},\n")
+	}
+	g.printf("}\n\n")
+
+	g.printf("// Interface returns the description of the %s interface.\n", iface.Name)
+	g.printf("func (p *%s) Interface() *%s {\n", typ, g.wl("Interface"))
+	g.printf("return %s\n", v)
+	g.printf("}\n\n")
+}
+
+func (g *generator) genMessage(m Message) {
+	g.printf("{Name: %q", m.Name)
+	if sig := signature(m.Args); sig != "" {
+		g.printf(", Signature: %q", sig)
+	}
+	if m.Since > 1 {
+		g.printf(", Since: %d", m.Since)
+	}
+	objects := false
+	for _, arg := range m.Args {
+		if arg.Interface != "" {
+			objects = true
+		}
+	}
+	if objects {
+		var types []string
+		for _, arg := range m.Args {
+			if arg.Type == "new_id" && arg.Interface == "" {
+				types = append(types, `""`, `""`)
+			}
+			types = append(types, fmt.Sprintf("%q", arg.Interface))
+		}
+		g.printf(", Types: []string{%s}", strings.Join(types, ", "))
+	}
+	g.printf("},\n")
+}
+
+func (g *generator) genRequest(typ string, opcode int, req *Message) {
+	method := camel(req.Name)
+
+	g.printf("// %s will %s.\n", method, req.Description.Summary)
+	if lines := req.Description.Lines(); len(lines) > 0 {
+		g.printf("//\n")
+		for _, l := range lines {
+			if l == "" {
+				g.printf("//\n")
+			} else {
+				g.printf("// %s\n", l)
+			}
+		}
+	}
+
+	var params, args []string
+	var ret *Arg
+	var bound string // the proxy of a new_id without interface
+	for i := range req.Args {
+		arg := req.Args[i]
+		switch {
+		case arg.Type == "new_id" && arg.Interface != "":
+			ret = &req.Args[i]
+			args = append(args, g.wl("Proxy")+"(ret)")
+		case arg.Type == "new_id":
+			// a new_id without an interface, as in wl_registry.bind,
+			// is sent as the interface name and version, then the id
+			params = append(params, "iface string", "version uint32", paramName(arg.Name)+" "+g.wl("Proxy"))
+			args = append(args, "iface", "version", paramName(arg.Name))
+			bound = paramName(arg.Name)
+		case arg.AllowNull && arg.Type == "object" && arg.Interface != "":
+			// a nil pointer is sent as the null object
+			params = append(params, paramName(arg.Name)+" "+g.argType(arg))
+			args = append(args, g.wl("NullableProxy")+"("+paramName(arg.Name)+")")
+		case arg.AllowNull && arg.Type == "string":
+			// a nil pointer is sent as the null string, which
+			// differs from the empty one
+			params = append(params, paramName(arg.Name)+" *string")
+			args = append(args, g.wl("NullableString")+"("+paramName(arg.Name)+")")
+		default:
+			params = append(params, paramName(arg.Name)+" "+g.argType(arg))
+			args = append(args, paramName(arg.Name))
+		}
+	}
+
+	send := fmt.Sprintf("p.Context().SendRequest(p, %d", opcode)
+	if len(args) > 0 {
+		send += ", " + strings.Join(args, ", ")
+	}
+	send += ")"
+
+	switch {
+	case ret != nil:
+		g.printf("func (p *%s) %s(%s) (*%s, error) {\n", typ, method, strings.Join(params, ", "), g.goType(ret.Interface))
+		if req.Since > 1 {
+			// refused before the id is allocated, or the ids
+			// would no longer be consecutive
+			g.printf("if err := %s(p, %d); err != nil {\n", g.wl("CheckVersion"), opcode)
+			g.printf("return nil, err\n")
+			g.printf("}\n")
+		}
+		g.printf("ret := %s(p.Context())\n", g.constructor(ret.Interface))
+		g.printf("ret.SetQueue(p.Queue())\n")
+		g.printf("ret.SetVersion(p.Version())\n")
+		// the id of an object the server never heard of is
+		// reused right away
+		g.printf("if err := %s; err != nil {\n", send)
+		g.printf("p.Context().Discard(ret)\n")
+		g.printf("return nil, err\n")
+		g.printf("}\n")
+		g.printf("return ret, nil\n")
+	case req.IsDestructor():
+		g.printf("func (p *%s) %s(%s) error {\n", typ, method, strings.Join(params, ", "))
+		// the proxy lives on if the request was not sent
+		g.printf("if err := %s; err != nil {\n", send)
+		g.printf("return err\n")
+		g.printf("}\n")
+		g.printf("p.Context().Unregister(p)\n")
+		g.printf("return nil\n")
+	default:
+		g.printf("func (p *%s) %s(%s) error {\n", typ, method, strings.Join(params, ", "))
+		if bound == "" {
+			g.printf("return %s\n", send)
+			break
+		}
+		g.printf("%s.SetVersion(version)\n", bound)
+		g.printf("if err := %s; err != nil {\n", send)
+		g.printf("p.Context().Discard(%s)\n", bound)
+		g.printf("return err\n")
+		g.printf("}\n")
+		g.printf("return nil\n")
+	}
+	g.printf("}\n\n")
+}
@@ -0,0 +1,59 @@
+// Command wl-scanner generates Go bindings from Wayland protocol XML
+// files.
+//
+// For every interface of the protocol it emits a proxy type built on
+// wl.BaseProxy, event types with handler interfaces, a Dispatch method
+// decoding events, one method per request and typed constants for the
+// enums, which are also the types of the arguments referencing them.
+// Interfaces referenced by the protocol but not declared in it are
+// taken from the core protocol in package wl.
+//
+// Usage:
+//
+//	wl-scanner -pkg xdg -prefix xdg_ -o shell.go xdg-shell.xml
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+func main() {
+	pkg := flag.String("pkg", "wl", "name of the generated package")
+	prefix := flag.String("prefix", "wl_", "interface name prefix stripped from Go type names")
+	suffix := flag.String("suffix", "", "interface name suffix stripped from Go type names")
+	out := flag.String("o", "", "output file (default stdout)")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: %s [flags] protocol.xml\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	proto, err := readProtocol(flag.Arg(0))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "wl-scanner: %s\n", err)
+		os.Exit(1)
+	}
+
+	g := newGenerator(proto, *pkg, *prefix, *suffix, filepath.Base(flag.Arg(0)))
+	src, err := g.generate()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "wl-scanner: formatting generated code: %s\n", err)
+		os.Exit(1)
+	}
+
+	if *out == "" {
+		os.Stdout.Write(src)
+		return
+	}
+	if err := os.WriteFile(*out, src, 0644); err != nil {
+		fmt.Fprintf(os.Stderr, "wl-scanner: %s\n", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"encoding/xml"
+	"os"
+	"strings"
+)
+
+// The XML schema of protocol files, see wayland.dtd.
+
+type Protocol struct {
+	Name       string      `xml:"name,attr"`
+	Copyright  string      `xml:"copyright"`
+	Interfaces []Interface `xml:"interface"`
+}
+
+type Interface struct {
+	Name        string      `xml:"name,attr"`
+	Version     int         `xml:"version,attr"`
+	Description Description `xml:"description"`
+	Requests    []Message   `xml:"request"`
+	Events      []Message   `xml:"event"`
+	Enums       []Enum      `xml:"enum"`
+}
+
+type Message struct {
+	Name        string      `xml:"name,attr"`
+	Type        string      `xml:"type,attr"`
+	Since       int         `xml:"since,attr"`
+	Description Description `xml:"description"`
+	Args        []Arg       `xml:"arg"`
+}
+
+type Arg struct {
+	Name      string `xml:"name,attr"`
+	Type      string `xml:"type,attr"`
+	Interface string `xml:"interface,attr"`
+	AllowNull bool   `xml:"allow-null,attr"`
+	Enum      string `xml:"enum,attr"`
+	Summary   string `xml:"summary,attr"`
+}
+
+type Enum struct {
+	Name        string      `xml:"name,attr"`
+	Since       int         `xml:"since,attr"`
+	Bitfield    bool        `xml:"bitfield,attr"`
+	Description Description `xml:"description"`
+	Entries     []Entry     `xml:"entry"`
+}
+
+type Entry struct {
+	Name    string `xml:"name,attr"`
+	Value   string `xml:"value,attr"`
+	Since   int    `xml:"since,attr"`
+	Summary string `xml:"summary,attr"`
+}
+
+type Description struct {
+	Summary string `xml:"summary,attr"`
+	Text    string `xml:",chardata"`
+}
+
+func (m *Message) IsDestructor() bool {
+	return m.Type == "destructor"
+}
+
+// Lines returns the description text with the XML indentation
+// removed and runs of blank lines collapsed.
+func (d Description) Lines() []string {
+	var ret []string
+	for _, l := range strings.Split(d.Text, "\n") {
+		l = strings.TrimSpace(l)
+		if l == "" && (len(ret) == 0 || ret[len(ret)-1] == "") {
+			continue
+		}
+		ret = append(ret, l)
+	}
+	for len(ret) > 0 && ret[len(ret)-1] == "" {
+		ret = ret[:len(ret)-1]
+	}
+	return ret
+}
+
+func readProtocol(path string) (*Protocol, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	p := new(Protocol)
+	if err := xml.NewDecoder(f).Decode(p); err != nil {
+		return nil, err
+	}
+	// qualify enum references with the interface, as in
+	// enum="wl_output.transform"
+	for i := range p.Interfaces {
+		iface := &p.Interfaces[i]
+		for _, msgs := range [][]Message{iface.Requests, iface.Events} {
+			for j := range msgs {
+				for k := range msgs[j].Args {
+					arg := &msgs[j].Args[k]
+					if arg.Enum != "" && !strings.Contains(arg.Enum, ".") {
+						arg.Enum = iface.Name + "." + arg.Enum
+					}
+				}
+			}
+		}
+	}
+	return p, nil
+}
+
+// IsError reports whether the enum holds the error codes of its
+// interface.  They are sent in the uint code of wl_display.error,
+// whatever the interface, so they are not typed.
+func (e *Enum) IsError() bool {
+	return e.Name == "error"
+}
@@ -2,6 +2,8 @@ package wl
 
 import (
 	"context"
+	"sync"
+	"sync/atomic"
 )
 
 type ProxyId uint32
@@ -15,11 +17,26 @@ type Proxy interface {
 	SetContext(c *Context)
 	Id() ProxyId
 	SetId(id ProxyId)
+	Queue() *EventQueue
+	SetQueue(q *EventQueue)
+	Version() uint32
+	SetVersion(v uint32)
 }
 
 type BaseProxy struct {
-	id  ProxyId
-	ctx *Context
+	id      ProxyId
+	ctx     *Context
+	queue   atomic.Pointer[EventQueue]
+	version atomic.Uint32
+	// dataMu guards the user data and the destroy hooks
+	dataMu       sync.Mutex
+	userData     interface{}
+	destroyHooks []*destroyHook
+	destroyed    bool
+}
+
+type destroyHook struct {
+	f func()
 }
 
 func (p *BaseProxy) Id() ProxyId {
@@ -38,6 +55,102 @@ func (p *BaseProxy) SetContext(c *Context) {
 	p.ctx = c
 }
 
+// Version returns the version of the interface the object was bound
+// at.  Objects created by requests or events share the version of the
+// object that created them.  Zero means unknown, for proxies not
+// created that way, and disables the version checks.
+func (p *BaseProxy) Version() uint32 {
+	return p.version.Load()
+}
+
+// SetVersion records the version the object was bound at.  Bind sets
+// it, and it is not meant to be changed later.
+func (p *BaseProxy) SetVersion(v uint32) {
+	p.version.Store(v)
+}
+
+// Queue returns the queue the events of the proxy are put on, or nil
+// for the default queue of the Context.
+func (p *BaseProxy) Queue() *EventQueue {
+	return p.queue.Load()
+}
+
+// SetQueue makes the events of the proxy go to q, to be dispatched by
+// its owner.  A nil q restores the default queue.  Objects created by
+// requests and events of the proxy start out on the same queue.
+func (p *BaseProxy) SetQueue(q *EventQueue) {
+	p.queue.Store(q)
+}
+
+// SetUserData attaches application data to the proxy, such as the
+// toolkit object a wl_surface belongs to, to be retrieved with UserData
+// when the proxy shows up in an event.
+func (p *BaseProxy) SetUserData(v interface{}) {
+	p.dataMu.Lock()
+	defer p.dataMu.Unlock()
+	p.userData = v
+}
+
+// UserData returns the data set with SetUserData, or nil.
+func (p *BaseProxy) UserData() interface{} {
+	p.dataMu.Lock()
+	defer p.dataMu.Unlock()
+	return p.userData
+}
+
+// OnDestroy calls f once the proxy is destroyed: when a destructor
+// request is sent for it, when the server deletes its id, or when the
+// Context is closed, whichever comes first.  f runs on the goroutine
+// that destroyed the proxy, and is not called if cancel is called
+// first.
+func (p *BaseProxy) OnDestroy(f func()) (cancel func()) {
+	h := &destroyHook{f}
+	p.dataMu.Lock()
+	defer p.dataMu.Unlock()
+	p.destroyHooks = append(p.destroyHooks, h)
+	return func() {
+		p.dataMu.Lock()
+		defer p.dataMu.Unlock()
+		for i, e := range p.destroyHooks {
+			if e == h {
+				p.destroyHooks = append(p.destroyHooks[:i], p.destroyHooks[i+1:]...)
+				break
+			}
+		}
+	}
+}
+
+// destroy runs the destroy hooks, once.
+func (p *BaseProxy) destroy() {
+	p.dataMu.Lock()
+	if p.destroyed {
+		p.dataMu.Unlock()
+		return
+	}
+	p.destroyed = true
+	hooks := p.destroyHooks
+	p.destroyHooks = nil
+	p.dataMu.Unlock()
+	for _, h := range hooks {
+		h.f()
+	}
+}
+
+// destroyer is implemented by proxies embedding BaseProxy.
+type destroyer interface {
+	destroy()
+}
+
+// destroyed runs the destroy hooks of proxies that were removed from
+// the Context.
+func destroyed(proxies ...Proxy) {
+	for _, p := range proxies {
+		if d, ok := p.(destroyer); ok {
+			d.destroy()
+		}
+	}
+}
+
 type Handler interface {
 	Handle(ev interface{})
 }
@@ -0,0 +1,301 @@
+package wl
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sync"
+	"syscall"
+)
+
+// Conn is one end of a Wayland connection.  Requests and events share
+// the same wire format, so a client reads events and writes requests
+// over a Conn, while a server reads requests and writes events.
+type Conn struct {
+	conn *net.UnixConn
+	// in buffers the bytes read but not yet returned by ReadMessage.
+	// Only the reading goroutine touches it.
+	in ring
+	// fds holds the file descriptors received with SCM_RIGHTS that no
+	// message has claimed yet.  Senders may batch the descriptors of
+	// several messages, so they are queued and handed out in order.
+	fdsMu sync.Mutex
+	fds   []int
+	// out and outFds hold the messages queued for writing and their
+	// file descriptors.
+	outMu  sync.Mutex
+	out    []byte
+	outFds []int
+}
+
+// maxFds is the number of file descriptors a single sendmsg may carry,
+// the same limit libwayland uses.
+const maxFds = 28
+
+// maxMessageSize is the size of the largest message that can be
+// written, header included, and of the output buffer.
+const maxMessageSize = 4096
+
+// NewConn wraps a connected unix socket.
+func NewConn(conn *net.UnixConn) *Conn {
+	return &Conn{conn: conn}
+}
+
+// SocketPair returns the two ends of a new pair of connected unix
+// sockets, for connecting a client and a server in the same process,
+// or a child process through WAYLAND_SOCKET.
+func SocketPair() (*net.UnixConn, *net.UnixConn, error) {
+	fds, err := syscall.Socketpair(syscall.AF_UNIX, syscall.SOCK_STREAM|syscall.SOCK_CLOEXEC, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+	var conns [2]*net.UnixConn
+	for i, fd := range fds {
+		f := os.NewFile(uintptr(fd), "socketpair")
+		c, err := net.FileConn(f)
+		f.Close()
+		if err != nil {
+			if i > 0 {
+				conns[0].Close()
+			} else {
+				syscall.Close(fds[1])
+			}
+			return nil, nil, err
+		}
+		conns[i] = c.(*net.UnixConn)
+	}
+	return conns[0], conns[1], nil
+}
+
+// UnixConn returns the underlying socket.
+func (c *Conn) UnixConn() *net.UnixConn {
+	return c.conn
+}
+
+// Close closes the connection and any received file descriptors that
+// were not claimed by a message.
+func (c *Conn) Close() error {
+	// closing the socket first makes a Flush blocked on a peer that
+	// stopped reading fail, releasing outMu
+	err := c.conn.Close()
+	c.fdsMu.Lock()
+	closeFds(c.fds)
+	c.fds = nil
+	c.fdsMu.Unlock()
+	c.outMu.Lock()
+	closeFds(c.outFds)
+	c.out, c.outFds = nil, nil
+	c.outMu.Unlock()
+	return err
+}
+
+// ClaimFds attaches the next n received file descriptors to ev, to be
+// returned by its FD method.  The caller knows n from the signature of
+// the message.  As the descriptors of a message arrive no later than
+// its first byte, ClaimFds may be called any time after ReadMessage
+// returned the message, as long as messages claim in the order they
+// were read.
+func (c *Conn) ClaimFds(ev *Event, n int) error {
+	if n == 0 {
+		return nil
+	}
+	c.fdsMu.Lock()
+	defer c.fdsMu.Unlock()
+	if len(c.fds) < n {
+		return fmt.Errorf("message needs %d file descriptors, only %d received", n, len(c.fds))
+	}
+	ev.fds = append(ev.fds, c.fds[:n]...)
+	c.fds = c.fds[n:]
+	return nil
+}
+
+// ClaimPendingFds attaches all received file descriptors that no
+// message has claimed yet to ev.  It is meant for forwarding messages
+// without knowing their signatures: the descriptors of a message
+// arrive no later than its first byte, so those sent along with the
+// message stay ahead of it, which is all the receiver needs.
+func (c *Conn) ClaimPendingFds(ev *Event) {
+	c.fdsMu.Lock()
+	defer c.fdsMu.Unlock()
+	ev.fds = append(ev.fds, c.fds...)
+	c.fds = nil
+}
+
+func closeFds(fds []int) {
+	for _, fd := range fds {
+		syscall.Close(fd)
+	}
+}
+
+// ReadMessage reads the next message from the connection.
+func (c *Conn) ReadMessage() (*Event, error) {
+	for {
+		ev, err := c.nextMessage()
+		if ev != nil || err != nil {
+			return ev, err
+		}
+		if err := c.fill(); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// nextMessage takes the first message out of the input buffer, or
+// returns nil if it is not complete yet.
+func (c *Conn) nextMessage() (*Event, error) {
+	var header [8]byte
+	if c.in.len() < len(header) {
+		return nil, nil
+	}
+	c.in.peek(header[:])
+	size := int(order.Uint16(header[6:8]))
+	if size < len(header) || size > ringSize {
+		return nil, fmt.Errorf("Invalid message size %d.", size)
+	}
+	if c.in.len() < size {
+		return nil, nil
+	}
+	c.in.read(header[:])
+
+	ev := new(Event)
+	ev.pid = ProxyId(order.Uint32(header[0:4]))
+	ev.Opcode = uint32(order.Uint16(header[4:6]))
+	ev.data = bytePool.Take(size - len(header))
+	c.in.read(ev.data)
+	return ev, nil
+}
+
+// fill reads as many bytes and file descriptors as are available, with
+// a single recvmsg, blocking until there is at least one byte.
+func (c *Conn) fill() error {
+	control := bytePool.Take(syscall.CmsgSpace(maxFds * 4))
+	defer bytePool.Give(control)
+
+	n, oobn, flags, _, err := c.conn.ReadMsgUnix(c.in.free(), control)
+	c.in.fill(n)
+	if oobn > 0 {
+		if err := c.queueFds(control[:oobn], flags); err != nil {
+			return err
+		}
+	}
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return io.EOF
+	}
+	return nil
+}
+
+// queueFds appends the file descriptors of all SCM_RIGHTS messages in
+// control to the queue.
+func (c *Conn) queueFds(control []byte, flags int) error {
+	scms, err := syscall.ParseSocketControlMessage(control)
+	if err != nil {
+		return fmt.Errorf("Control message parse error: %s", err)
+	}
+	for i := range scms {
+		fds, err := syscall.ParseUnixRights(&scms[i])
+		if err != nil {
+			continue
+		}
+		c.fdsMu.Lock()
+		c.fds = append(c.fds, fds...)
+		c.fdsMu.Unlock()
+	}
+	if flags&syscall.MSG_CTRUNC != 0 {
+		return errors.New("file descriptors lost: control message truncated")
+	}
+	return nil
+}
+
+// WriteMessage writes r to the connection, together with any messages
+// queued before it.
+func (c *Conn) WriteMessage(r *Request) error {
+	if err := c.QueueMessage(r); err != nil {
+		return err
+	}
+	return c.Flush()
+}
+
+// QueueMessage appends r to the output buffer, to be written by the
+// next Flush.  The contents and file descriptors of r are copied, so
+// the caller may reuse r and close its own descriptors right away.
+// The buffer is flushed first if r does not fit into it.
+func (c *Conn) QueueMessage(r *Request) error {
+	size := len(r.data) + 8
+	if size > maxMessageSize {
+		return fmt.Errorf("message of %d bytes exceeds the limit of %d", size, maxMessageSize)
+	}
+	if len(r.fds) > maxFds {
+		return fmt.Errorf("message with %d file descriptors exceeds the limit of %d", len(r.fds), maxFds)
+	}
+	fds := make([]int, 0, len(r.fds))
+	for _, fd := range r.fds {
+		dup, err := dupCloexec(fd)
+		if err != nil {
+			closeFds(fds)
+			return err
+		}
+		fds = append(fds, dup)
+	}
+
+	c.outMu.Lock()
+	defer c.outMu.Unlock()
+	if len(c.out)+size > maxMessageSize || len(c.outFds)+len(fds) > maxFds {
+		if err := c.flush(); err != nil {
+			closeFds(fds)
+			return err
+		}
+	}
+	var header [8]byte
+	order.PutUint32(header[0:4], uint32(r.pid))
+	order.PutUint32(header[4:8], uint32(size)<<16|r.opcode&0x0000ffff)
+	c.out = append(c.out, header[:]...)
+	c.out = append(c.out, r.data...)
+	c.outFds = append(c.outFds, fds...)
+	return nil
+}
+
+// Flush writes the output buffer to the connection.
+func (c *Conn) Flush() error {
+	c.outMu.Lock()
+	defer c.outMu.Unlock()
+	return c.flush()
+}
+
+func (c *Conn) flush() error {
+	for len(c.out) > 0 {
+		var oob []byte
+		if len(c.outFds) > 0 {
+			oob = syscall.UnixRights(c.outFds...)
+		}
+		n, _, err := c.conn.WriteMsgUnix(c.out, oob, nil)
+		if n > 0 && len(c.outFds) > 0 {
+			// the fds went along with the first byte
+			closeFds(c.outFds)
+			c.outFds = c.outFds[:0]
+		}
+		// the runtime waits for the socket to be writable, but
+		// may write only part of the buffer
+		c.out = c.out[:copy(c.out, c.out[n:])]
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func dupCloexec(fd int) (int, error) {
+	return fcntl(fd, syscall.F_DUPFD_CLOEXEC, 0)
+}
+
+func fcntl(fd int, cmd int, arg int) (int, error) {
+	r, _, e := syscall.Syscall(syscall.SYS_FCNTL, uintptr(fd), uintptr(cmd), uintptr(arg))
+	if e != 0 {
+		return 0, e
+	}
+	return int(r), nil
+}
@@ -0,0 +1,262 @@
+package wl
+
+import (
+	"syscall"
+	"testing"
+	"time"
+)
+
+func socketPair(t *testing.T) (*Conn, *Conn) {
+	a, b, err := SocketPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewConn(a), NewConn(b)
+}
+
+func TestBatchedFds(t *testing.T) {
+	a, b := socketPair(t)
+	defer a.Close()
+	defer b.Close()
+
+	var pipes [3][2]int
+	for i := range pipes {
+		if err := syscall.Pipe(pipes[i][:]); err != nil {
+			t.Fatal(err)
+		}
+		defer syscall.Close(pipes[i][0])
+		defer syscall.Close(pipes[i][1])
+	}
+
+	// two messages with two and one fds, sent with a single sendmsg
+	// as a batching sender would
+	r1 := NewRequest(3, 0, uintptr(pipes[0][1]), uintptr(pipes[1][1]))
+	r2 := NewRequest(3, 1, uintptr(pipes[2][1]))
+	var data []byte
+	for _, r := range []*Request{r1, r2} {
+		header := make([]byte, 8)
+		order.PutUint32(header, uint32(r.pid))
+		order.PutUint32(header[4:], uint32(len(r.data)+8)<<16|r.opcode)
+		data = append(append(data, header...), r.data...)
+	}
+	oob := syscall.UnixRights(append(r1.fds, r2.fds...)...)
+	if _, _, err := a.UnixConn().WriteMsgUnix(data, oob, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	for i, want := range [][]int{{0, 1}, {2}} {
+		ev, err := b.ReadMessage()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := b.ClaimFds(ev, len(want)); err != nil {
+			t.Fatal(err)
+		}
+		for _, p := range want {
+			fd := int(ev.FD())
+			// the received fd must be the write end of pipe p
+			if _, err := syscall.Write(fd, []byte{byte(p)}); err != nil {
+				t.Fatal(err)
+			}
+			syscall.Close(fd)
+			buf := make([]byte, 1)
+			if _, err := syscall.Read(pipes[p][0], buf); err != nil || buf[0] != byte(p) {
+				t.Errorf("message %d: wrong fd for pipe %d", i, p)
+			}
+		}
+	}
+
+	ev := &Event{}
+	if err := b.ClaimFds(ev, 1); err == nil {
+		t.Error("claimed an fd that was never sent")
+	}
+}
+
+func TestClaimPendingFds(t *testing.T) {
+	a, b := socketPair(t)
+	defer a.Close()
+	defer b.Close()
+
+	var p [2]int
+	if err := syscall.Pipe(p[:]); err != nil {
+		t.Fatal(err)
+	}
+	defer syscall.Close(p[0])
+	defer syscall.Close(p[1])
+	// messages of an unknown protocol, the first with two fds
+	a.QueueMessage(NewRequest(7, 0, uintptr(p[0]), uintptr(p[1])))
+	a.QueueMessage(NewRequest(7, 1, uint32(1)))
+	if err := a.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, want := range []int{2, 0} {
+		ev, err := b.ReadMessage()
+		if err != nil {
+			t.Fatal(err)
+		}
+		b.ClaimPendingFds(ev)
+		if len(ev.fds) != want {
+			t.Errorf("message %d claimed %d fds, want %d", ev.Opcode, len(ev.fds), want)
+		}
+		ev.CloseFds()
+	}
+}
+
+func TestCloseBlockedFlush(t *testing.T) {
+	a, b := socketPair(t)
+	defer b.Close()
+
+	// b never reads, so writing blocks once the socket buffer is full
+	flushed := make(chan error, 1)
+	go func() {
+		for {
+			if err := a.WriteMessage(NewRequest(2, 0, make([]byte, 1024))); err != nil {
+				flushed <- err
+				return
+			}
+		}
+	}()
+	time.Sleep(100 * time.Millisecond)
+
+	closed := make(chan struct{})
+	go func() {
+		a.Close()
+		close(closed)
+	}()
+	select {
+	case <-closed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Close blocked by a pending Flush")
+	}
+	select {
+	case <-flushed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Flush still blocked after Close")
+	}
+}
+
+func TestPartialMessages(t *testing.T) {
+	a, b := socketPair(t)
+	defer a.Close()
+	defer b.Close()
+
+	var data []byte
+	for i := uint32(0); i < 3; i++ {
+		header := make([]byte, 12)
+		order.PutUint32(header, 5)
+		order.PutUint32(header[4:], 12<<16|i)
+		order.PutUint32(header[8:], i*10)
+		data = append(data, header...)
+	}
+
+	// split the messages at awkward places
+	go func() {
+		for _, chunk := range [][]byte{data[:3], data[3:17], data[17:]} {
+			a.UnixConn().Write(chunk)
+		}
+	}()
+	for i := uint32(0); i < 3; i++ {
+		ev, err := b.ReadMessage()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ev.Id() != 5 || ev.Opcode != i || ev.Uint32() != i*10 {
+			t.Errorf("message %d decoded wrong", i)
+		}
+	}
+}
+
+func TestRingWrap(t *testing.T) {
+	var r ring
+	msg := make([]byte, 1000)
+	for i := range msg {
+		msg[i] = byte(i)
+	}
+	out := make([]byte, len(msg))
+	for i := 0; i < 3*ringSize/len(msg); i++ {
+		for n := 0; n < len(msg); {
+			free := r.free()
+			k := copy(free, msg[n:])
+			r.fill(k)
+			n += k
+		}
+		r.read(out)
+		if string(out) != string(msg) || r.len() != 0 {
+			t.Fatalf("round %d: data corrupted", i)
+		}
+	}
+}
+
+func TestQueueMessage(t *testing.T) {
+	a, b := socketPair(t)
+	defer a.Close()
+	defer b.Close()
+
+	var p [2]int
+	if err := syscall.Pipe(p[:]); err != nil {
+		t.Fatal(err)
+	}
+	defer syscall.Close(p[0])
+	for i := uint32(0); i < 3; i++ {
+		if err := a.QueueMessage(NewRequest(2, i, i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := a.QueueMessage(NewRequest(2, 3, uintptr(p[1]))); err != nil {
+		t.Fatal(err)
+	}
+	// the queued message holds its own copy
+	syscall.Close(p[1])
+	if err := a.QueueMessage(NewRequest(2, 4, make([]byte, maxMessageSize))); err == nil {
+		t.Error("queued an oversized message")
+	}
+	if err := a.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := uint32(0); i < 4; i++ {
+		ev, err := b.ReadMessage()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ev.Opcode != i {
+			t.Fatalf("got opcode %d, want %d", ev.Opcode, i)
+		}
+	}
+	ev := &Event{}
+	if err := b.ClaimFds(ev, 1); err != nil {
+		t.Fatal(err)
+	}
+	fd := int(ev.FD())
+	defer syscall.Close(fd)
+	if _, err := syscall.Write(fd, []byte{1}); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestQueueMessageCopies(t *testing.T) {
+	a, b := socketPair(t)
+	defer a.Close()
+	defer b.Close()
+
+	// the request stays the caller's, and may be sent again
+	r := NewRequest(3, 1, "hello", uint32(7))
+	for i := 0; i < 2; i++ {
+		if err := a.QueueMessage(r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := a.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		ev, err := b.ReadMessage()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if s, u := ev.String(), ev.Uint32(); s != "hello" || u != 7 {
+			t.Errorf("message %d decoded as %q, %d", i, s, u)
+		}
+	}
+}
@@ -1,13 +1,15 @@
 package wl
 
 import (
-	"context"
 	"errors"
-	"io"
+	"fmt"
 	"log"
 	"net"
 	"os"
+	"path/filepath"
+	"strconv"
 	"sync"
+	"syscall"
 	"time"
 )
 
@@ -15,22 +17,62 @@ func init() {
 	log.SetFlags(0)
 }
 
+// ServerIdStart is the first of the object ids allocated by the
+// server, for objects it creates through new_id arguments of events.
+const ServerIdStart ProxyId = 0xff000000
+
 type Context struct {
-	mu           sync.RWMutex
-	conn         *net.UnixConn
-	currentId    ProxyId
-	objects      map[ProxyId]Proxy
-	dispatchChan chan struct{}
-	exitChan     chan struct{}
+	mu        sync.RWMutex
+	conn      *Conn
+	currentId ProxyId
+	freeIds   []ProxyId
+	objects   map[ProxyId]Proxy
+	zombies   map[ProxyId]*Interface
+	display   *Display
+	queue     *EventQueue   // the default queue
+	queues    []*EventQueue // the queues made with NewEventQueue
+	tracer    Tracer
+	recorder  *Recorder
+	metrics   Metrics
+	// syncs holds the time wl_display.sync requests were sent, by
+	// callback, while metrics are on
+	syncs map[ProxyId]time.Time
+	// recordFile is the recording opened for WL_RECORD
+	recordFile *os.File
+	// err is the error that stopped the Context, and done is closed
+	// when it is set.
+	err       error
+	done      chan struct{}
+	readDone  chan struct{} // closed when read returns
+	closeOnce sync.Once
 }
 
+// Register allocates an object id for proxy and binds it to the
+// context.  Ids released by the server through wl_display.delete_id
+// are reused before new ones are handed out.
 func (ctx *Context) Register(proxy Proxy) {
 	ctx.mu.Lock()
 	defer ctx.mu.Unlock()
-	ctx.currentId += 1
-	proxy.SetId(ctx.currentId)
+	var id ProxyId
+	if n := len(ctx.freeIds); n > 0 {
+		id = ctx.freeIds[n-1]
+		ctx.freeIds = ctx.freeIds[:n-1]
+	} else {
+		ctx.currentId += 1
+		id = ctx.currentId
+	}
+	proxy.SetId(id)
+	proxy.SetContext(ctx)
+	ctx.objects[id] = proxy
+}
+
+// registerAt registers a proxy under an id chosen by the server.
+func (ctx *Context) registerAt(id ProxyId, proxy Proxy) {
+	ctx.mu.Lock()
+	defer ctx.mu.Unlock()
+	proxy.SetId(id)
 	proxy.SetContext(ctx)
-	ctx.objects[ctx.currentId] = proxy
+	ctx.objects[id] = proxy
 }
 
 func (ctx *Context) lookupProxy(id ProxyId) Proxy {
@@ -43,27 +85,150 @@ func (ctx *Context) lookupProxy(id ProxyId) Proxy {
 	return proxy
 }
 
-func (ctx *Context) unregister(proxy Proxy) {
+// Unregister forgets proxy after a destructor request was sent for
+// it.  Its id stays reserved as a zombie, which silently absorbs
+// events the server sent before it processed the destructor, until
+// the server confirms the deletion with wl_display.delete_id.
+func (ctx *Context) Unregister(proxy Proxy) {
 	ctx.mu.Lock()
-	defer ctx.mu.Unlock()
-	delete(ctx.objects, proxy.Id())
+	id := proxy.Id()
+	if ctx.objects[id] != proxy {
+		ctx.mu.Unlock()
+		return
+	}
+	delete(ctx.objects, id)
+	if id < ServerIdStart {
+		ctx.zombies[id] = interfaceOf(proxy)
+	}
+	ctx.mu.Unlock()
+	destroyed(proxy)
 }
 
-func (c *Context) Close() {
-	c.conn.Close()
-	c.exitChan <- struct{}{}
-	close(c.dispatchChan)
+// Discard forgets a proxy whose constructor request could not be sent,
+// and frees its id right away, as the server never learnt of it.
+// Otherwise the next object created would skip an id, which the server
+// rejects.
+func (ctx *Context) Discard(proxy Proxy) {
+	ctx.mu.Lock()
+	id := proxy.Id()
+	if ctx.objects[id] != proxy {
+		ctx.mu.Unlock()
+		return
+	}
+	delete(ctx.objects, id)
+	if id < ServerIdStart {
+		ctx.freeIds = append(ctx.freeIds, id)
+	}
+	ctx.mu.Unlock()
+	destroyed(proxy)
+}
 
+// deleteId releases an id in response to wl_display.delete_id, making
+// it available for reuse.  delete_id is dispatched on the default
+// queue, so the id of a live proxy on another queue, such as the
+// callback of EventQueue.Roundtrip, is only released once the events
+// queued for it before have been dispatched.
+func (ctx *Context) deleteId(id ProxyId) {
+	ctx.mu.Lock()
+	proxy, live := ctx.objects[id]
+	ctx.mu.Unlock()
+	if live {
+		if q := proxy.Queue(); q != nil && q != ctx.queue {
+			q.push(&Event{pid: id, proxy: proxy, release: true})
+			return
+		}
+	}
+	ctx.releaseId(id, proxy)
 }
 
-func (c *Context) Dispatch() chan<- struct{} {
-	return c.dispatchChan
+// releaseId forgets the object with the given id, which is proxy if it
+// is still live, and makes the id available for reuse.
+func (ctx *Context) releaseId(id ProxyId, proxy Proxy) {
+	ctx.mu.Lock()
+	_, zombie := ctx.zombies[id]
+	live := proxy != nil && ctx.objects[id] == proxy
+	if !zombie && !live {
+		ctx.mu.Unlock()
+		return
+	}
+	delete(ctx.zombies, id)
+	if live {
+		delete(ctx.objects, id)
+	}
+	if id < ServerIdStart {
+		ctx.freeIds = append(ctx.freeIds, id)
+	}
+	ctx.mu.Unlock()
+	if live {
+		destroyed(proxy)
+	}
 }
 
+// zombie reports whether id is a zombie, and the interface the object
+// had, which is needed to discard the file descriptors of its events.
+func (ctx *Context) zombie(id ProxyId) (*Interface, bool) {
+	ctx.mu.RLock()
+	defer ctx.mu.RUnlock()
+	iface, ok := ctx.zombies[id]
+	return iface, ok
+}
+
+type idDeleter struct {
+	ctx *Context
+}
+
+func (d idDeleter) HandleDisplayDeleteId(ev DisplayDeleteIdEvent) {
+	d.ctx.deleteId(ProxyId(ev.Id))
+}
+
+// Close disconnects from the server.  It stops the Context with
+// ErrClosed, unless it already failed, destroys all proxies and closes
+// the file descriptors of the events that were not dispatched.  Close
+// may be called from any goroutine, including event handlers, and more
+// than once.
+func (c *Context) Close() {
+	c.fail(ErrClosed)
+	c.closeOnce.Do(func() {
+		c.conn.Close()
+		// once read is done, no more events are queued
+		<-c.readDone
+
+		c.mu.Lock()
+		queues := append([]*EventQueue{c.queue}, c.queues...)
+		objects := c.objects
+		c.objects = make(map[ProxyId]Proxy)
+		c.zombies = make(map[ProxyId]*Interface)
+		c.mu.Unlock()
+		for _, q := range queues {
+			q.drain()
+		}
+		for _, p := range objects {
+			destroyed(p)
+		}
+		if c.recordFile != nil {
+			c.recordFile.Close()
+		}
+	})
+}
+
+// Connect connects to a Wayland server the way libwayland does.  If
+// WAYLAND_SOCKET is set, it holds the number of a socket already
+// connected to the server, typically inherited from the compositor
+// that started the client.  Connect takes ownership of that socket and
+// removes the variable from the environment, so that it is not
+// inherited by child processes.  Otherwise addr names the socket to
+// connect to, defaulting to WAYLAND_DISPLAY and then wayland-0.  An
+// absolute addr is used as is, a relative one is looked up in
+// XDG_RUNTIME_DIR.
 func Connect(addr string) (ret *Display, err error) {
-	runtime_dir := os.Getenv("XDG_RUNTIME_DIR")
-	if runtime_dir == "" {
-		return nil, errors.New("XDG_RUNTIME_DIR not set in the environment.")
+	if s, ok := os.LookupEnv("WAYLAND_SOCKET"); ok {
+		os.Unsetenv("WAYLAND_SOCKET")
+		fd, err := strconv.Atoi(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid WAYLAND_SOCKET %q", s)
+		}
+		syscall.CloseOnExec(fd)
+		return ConnectFd(fd)
 	}
 	if addr == "" {
 		addr = os.Getenv("WAYLAND_DISPLAY")
@@ -71,59 +236,241 @@ func Connect(addr string) (ret *Display, err error) {
 	if addr == "" {
 		addr = "wayland-0"
 	}
-	addr = runtime_dir + "/" + addr
-	c := new(Context)
-	c.objects = make(map[ProxyId]Proxy)
-	c.currentId = 0
-	c.dispatchChan = make(chan struct{})
-	c.exitChan = make(chan struct{})
-	c.conn, err = net.DialUnix("unix", nil, &net.UnixAddr{Name: addr, Net: "unix"})
+	if !filepath.IsAbs(addr) {
+		runtime_dir := os.Getenv("XDG_RUNTIME_DIR")
+		if runtime_dir == "" {
+			return nil, errors.New("XDG_RUNTIME_DIR not set in the environment.")
+		}
+		addr = filepath.Join(runtime_dir, addr)
+	}
+	conn, err := net.DialUnix("unix", nil, &net.UnixAddr{Name: addr, Net: "unix"})
+	if err != nil {
+		return nil, err
+	}
+	conn.SetReadDeadline(time.Time{})
+	return NewContextFromConn(conn), nil
+}
+
+// ConnectFd creates a Context talking over fd, a unix socket connected
+// to a Wayland server, and returns its wl_display.  It takes ownership
+// of fd, which is closed even if ConnectFd fails.
+func ConnectFd(fd int) (*Display, error) {
+	if fd < 0 {
+		return nil, fmt.Errorf("invalid fd %d", fd)
+	}
+	f := os.NewFile(uintptr(fd), "wayland socket")
+	conn, err := net.FileConn(f)
+	f.Close()
 	if err != nil {
 		return nil, err
 	}
-	c.conn.SetReadDeadline(time.Time{})
-	//dispatch events in separate gorutine
-	go c.run()
-	return NewDisplay(c), nil
+	unixConn, ok := conn.(*net.UnixConn)
+	if !ok {
+		conn.Close()
+		return nil, fmt.Errorf("fd %d is not a unix socket", fd)
+	}
+	return NewContextFromConn(unixConn), nil
 }
 
-func (c *Context) run() {
-	ctx := context.Background()
+// NewContextFromConn creates a Context talking to the server at the
+// other end of conn, and returns its wl_display.  The Context takes
+// ownership of conn.
+func NewContextFromConn(conn *net.UnixConn) *Display {
+	c := new(Context)
+	c.objects = make(map[ProxyId]Proxy)
+	c.zombies = make(map[ProxyId]*Interface)
+	c.currentId = 0
+	c.queue = newQueue(c)
+	if debugFromEnv() {
+		c.tracer = NewTextTracer(os.Stderr)
+	}
+	c.recordFile = c.recordFromEnv()
+	c.done = make(chan struct{})
+	c.readDone = make(chan struct{})
+	c.conn = NewConn(conn)
+	display := NewDisplay(c)
+	display.SetVersion(1)
+	display.AddDeleteIdHandler(idDeleter{c})
+	c.display = display
+	// events are read in the background, and dispatched by Run and
+	// friends on the goroutines calling them
+	go c.read()
+	return display
+}
 
-loop:
+// read reads events until the connection breaks or is closed, and
+// queues them for dispatching.
+func (c *Context) read() {
+	defer close(c.readDone)
 	for {
-		select {
-		case <-c.dispatchChan:
-			ev, err := c.readEvent()
-			if err != nil {
-				if err == io.EOF {
-					// connection closed
-					break loop
+		ev, err := c.conn.ReadMessage()
+		if err != nil {
+			if neterr, ok := err.(net.Error); ok && neterr.Timeout() {
+				log.Print("Timeout Error")
+				continue
+			}
+			if !errors.Is(err, net.ErrClosed) {
+				// the connection was not closed by Close
+				c.fail(err)
+			}
+			return
+		}
 
-				}
+		proxy := c.lookupProxy(ev.pid)
+		if proxy != nil {
+			iface := interfaceOf(proxy)
+			err := c.conn.ClaimFds(ev, eventFds(iface, ev.Opcode))
+			if err == nil {
+				c.recordEvent(ev)
+				c.countEvent(iface, ev)
+				c.traceEvent(iface, ev)
+				err = c.createProxies(proxy, ev)
+			}
+			_, isDisplay := proxy.(*Display)
+			if err != nil && isDisplay && ev.Opcode == 0 {
+				// the server reported an error, which cannot be
+				// decoded, and is about to hang up
+				c.fail(err)
+			}
+			if err != nil {
+				log.Printf("%s@%d: event %d: %s", ifaceName(iface), ev.pid, ev.Opcode, err)
+				ev.CloseFds()
+				continue
+			}
+			if isDisplay && ev.Opcode == 0 {
+				c.fail(c.decodeProtocolError(ev))
+			}
+			ev.proxy = proxy
+			c.queueOf(proxy).push(ev)
+		} else if iface, ok := c.zombie(ev.pid); ok {
+			// the fds of events for zombies still have to be
+			// taken off the queue, or the following events would
+			// get the wrong ones
+			c.conn.ClaimFds(ev, eventFds(iface, ev.Opcode))
+			c.createZombies(iface, ev)
+			c.recordEvent(ev)
+			c.countEvent(iface, ev)
+			c.traceEvent(iface, ev)
+			ev.CloseFds()
+		} else {
+			c.recordEvent(ev)
+			c.countEvent(nil, ev)
+			c.traceEvent(nil, ev)
+			log.Printf("event %d for unknown object %d", ev.Opcode, ev.pid)
+		}
+	}
+}
 
-				if neterr, ok := err.(net.Error); ok && neterr.Timeout() {
-					log.Print("Timeout Error")
-					continue
-				}
+// createProxies constructs and registers a proxy for every new_id
+// argument of ev, so that the objects exist before the event is
+// dispatched and before any later event addresses them.  It also
+// rejects null objects and strings where the protocol does not allow
+// them.
+func (c *Context) createProxies(proxy Proxy, ev *Event) error {
+	iface := interfaceOf(proxy)
+	if iface == nil || int(ev.Opcode) >= len(iface.Events) {
+		return nil
+	}
+	msg := &iface.Events[ev.Opcode]
 
-				log.Fatal(err)
+	off := 0
+	for _, arg := range msg.Arguments() {
+		if arg.Type == 'h' {
+			continue
+		}
+		if off+4 > len(ev.data) {
+			return fmt.Errorf("%s.%s: message too short", iface.Name, msg.Name)
+		}
+		v := order.Uint32(ev.data[off : off+4])
+		off += 4
+		switch arg.Type {
+		case 's', 'a':
+			off += int(v) + padding(int(v))
+		}
+		switch arg.Type {
+		case 's', 'o':
+			if v == 0 && !arg.Nullable {
+				return fmt.Errorf("%s.%s: null value for a non-nullable argument", iface.Name, msg.Name)
 			}
-
-			proxy := c.lookupProxy(ev.pid)
-			if proxy != nil {
-				if dispatcher, ok := proxy.(Dispatcher); ok {
-					dispatcher.Dispatch(ctx, ev)
-					bytePool.Give(ev.data)
-				} else {
-					log.Print("Not dispatched")
-				}
-			} else {
-				log.Print("Proxy NULL")
+		case 'n':
+			if arg.Interface == "" {
+				return fmt.Errorf("%s.%s: new_id without interface", iface.Name, msg.Name)
+			}
+			if err := c.createProxy(proxy, ProxyId(v), arg.Interface); err != nil {
+				return fmt.Errorf("%s.%s: %s", iface.Name, msg.Name, err)
 			}
+		}
+	}
+	// the last string or array may run past the end
+	if off > len(ev.data) {
+		return fmt.Errorf("%s.%s: message too short", iface.Name, msg.Name)
+	}
+	return nil
+}
 
-		case <-c.exitChan:
-			break loop
+// createZombies makes zombies of the objects created by ev, an event
+// for a zombie of the given interface, as the server considers them
+// live and may send them events carrying file descriptors.
+func (c *Context) createZombies(iface *Interface, ev *Event) {
+	if iface == nil || int(ev.Opcode) >= len(iface.Events) {
+		return
+	}
+	msg := &iface.Events[ev.Opcode]
+
+	off := 0
+	for _, arg := range msg.Arguments() {
+		if arg.Type == 'h' {
+			continue
+		}
+		if off+4 > len(ev.data) {
+			return
 		}
+		v := order.Uint32(ev.data[off : off+4])
+		off += 4
+		switch arg.Type {
+		case 's', 'a':
+			off += int(v) + padding(int(v))
+		case 'n':
+			if id := ProxyId(v); id >= ServerIdStart {
+				c.mu.Lock()
+				c.zombies[id] = LookupInterface(arg.Interface)
+				c.mu.Unlock()
+			}
+		}
+	}
+}
+
+func (c *Context) createProxy(parent Proxy, id ProxyId, name string) error {
+	if id < ServerIdStart {
+		return fmt.Errorf("new_id %d is not in the server range", id)
+	}
+	iface := LookupInterface(name)
+	if iface == nil || iface.New == nil {
+		return fmt.Errorf("unknown interface %q", name)
+	}
+	proxy := iface.New()
+	proxy.SetQueue(parent.Queue())
+	proxy.SetVersion(parent.Version())
+	c.registerAt(id, proxy)
+	return nil
+}
+
+func (c *Context) recordEvent(ev *Event) {
+	if r := c.getRecorder(); r != nil {
+		r.record(false, ev.pid, ev.Opcode, ev.data, ev.fds)
+	}
+}
+
+func (c *Context) traceEvent(iface *Interface, ev *Event) {
+	if t := c.getTracer(); t != nil {
+		c.trace(t, false, ev.pid, iface, ev.Opcode, ev.data, ev.fds)
+	}
+}
+
+// queueOf returns the queue the events of proxy go to.
+func (c *Context) queueOf(proxy Proxy) *EventQueue {
+	if q := proxy.Queue(); q != nil {
+		return q
 	}
+	return c.queue
 }
@@ -0,0 +1,476 @@
+package wl
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestCreateProxies(t *testing.T) {
+	c := &Context{objects: make(map[ProxyId]Proxy)}
+	dev := new(DataDevice)
+	c.Register(dev)
+
+	ev := &Event{pid: dev.Id(), Opcode: 0, data: make([]byte, 4)}
+	order.PutUint32(ev.data, 0xff000001)
+	if err := c.createProxies(dev, ev); err != nil {
+		t.Fatal(err)
+	}
+	offer, ok := c.lookupProxy(0xff000001).(*DataOffer)
+	if !ok {
+		t.Fatal("data offer not registered")
+	}
+	if offer.Id() != 0xff000001 || offer.Context() != c {
+		t.Fail()
+	}
+
+	order.PutUint32(ev.data, 7)
+	if err := c.createProxies(dev, ev); err == nil {
+		t.Error("accepted new_id outside the server range")
+	}
+}
+
+func TestZombieNewId(t *testing.T) {
+	c := &Context{
+		objects: make(map[ProxyId]Proxy),
+		zombies: make(map[ProxyId]*Interface),
+	}
+	dev := new(DataDevice)
+	c.Register(dev)
+	c.Unregister(dev)
+
+	// wl_data_device.data_offer, sent before the server destroyed
+	// the device
+	ev := &Event{pid: dev.Id(), Opcode: 0, data: make([]byte, 4)}
+	order.PutUint32(ev.data, 0xff000001)
+	c.createZombies(dev.Interface(), ev)
+	iface, ok := c.zombie(0xff000001)
+	if !ok || iface == nil || iface.Name != "wl_data_offer" {
+		t.Errorf("object created for a zombie is not a zombie: %v", iface)
+	}
+	if c.lookupProxy(0xff000001) != nil {
+		t.Error("proxy created for a zombie")
+	}
+}
+
+func TestIdRecycling(t *testing.T) {
+	c := &Context{
+		objects: make(map[ProxyId]Proxy),
+		zombies: make(map[ProxyId]*Interface),
+	}
+	a, b := new(Surface), new(Surface)
+	c.Register(a)
+	c.Register(b)
+	if a.Id() != 1 || b.Id() != 2 {
+		t.Fatalf("unexpected ids %d, %d", a.Id(), b.Id())
+	}
+
+	c.Unregister(a)
+	if c.lookupProxy(1) != nil || !isZombie(c, 1) {
+		t.Fatal("destroyed proxy is not a zombie")
+	}
+	d := new(Surface)
+	c.Register(d)
+	if d.Id() != 3 {
+		t.Fatalf("zombie id reused before delete_id: %d", d.Id())
+	}
+
+	c.deleteId(1)
+	if isZombie(c, 1) {
+		t.Fatal("zombie survived delete_id")
+	}
+	e := new(Surface)
+	c.Register(e)
+	if e.Id() != 1 {
+		t.Fatalf("deleted id not reused: %d", e.Id())
+	}
+
+	// a live object deleted by the server, like a wl_callback
+	c.deleteId(2)
+	if c.lookupProxy(2) != nil {
+		t.Fatal("live proxy survived delete_id")
+	}
+}
+
+// TestDeleteIdOtherQueue checks that a callback on a queue of its own
+// gets its done event even if the default queue handles the delete_id
+// following it first.
+func TestDeleteIdOtherQueue(t *testing.T) {
+	a, server := socketPair(t)
+	defer server.Close()
+	display := NewContextFromConn(a.UnixConn())
+	c := display.Context()
+	defer c.Close()
+
+	q := c.NewEventQueue()
+	callback := NewCallback(c)
+	callback.SetQueue(q)
+	done := false
+	callback.OnDone(func(CallbackDoneEvent) { done = true })
+	if err := c.SendRequest(display, 0, callback); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := server.ReadMessage(); err != nil {
+		t.Fatal(err)
+	}
+	server.WriteMessage(NewRequest(callback.Id(), 0, uint32(1)))
+	server.WriteMessage(NewRequest(1, 1, uint32(callback.Id())))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	// the done event is read before the delete_id dispatched here
+	if err := c.DispatchOne(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if c.lookupProxy(callback.Id()) != callback {
+		t.Fatal("id released before the events of its queue were dispatched")
+	}
+	if err := q.DispatchPending(); err != nil {
+		t.Fatal(err)
+	}
+	if !done {
+		t.Fatal("done event dropped")
+	}
+	s := new(Surface)
+	c.Register(s)
+	if s.Id() != callback.Id() {
+		t.Errorf("callback id %d not released, got %d", callback.Id(), s.Id())
+	}
+}
+
+func isZombie(c *Context, id ProxyId) bool {
+	_, ok := c.zombie(id)
+	return ok
+}
+
+func TestNullArguments(t *testing.T) {
+	c := &Context{objects: make(map[ProxyId]Proxy)}
+	dev := new(DataDevice)
+	c.Register(dev)
+
+	// wl_data_device.enter: serial, surface, x, y, nullable offer
+	ev := &Event{pid: dev.Id(), Opcode: 1, data: make([]byte, 20)}
+	order.PutUint32(ev.data[4:], 0)
+	if err := c.createProxies(dev, ev); err == nil {
+		t.Error("accepted a null surface")
+	}
+	order.PutUint32(ev.data[4:], uint32(dev.Id()))
+	if err := c.createProxies(dev, ev); err != nil {
+		t.Errorf("rejected a null offer: %s", err)
+	}
+
+	var surface *Surface
+	r := NewRequest(3, 0, uint32(1), NullableProxy(surface), int32(0), int32(0))
+	if len(r.data) != 16 || order.Uint32(r.data[4:]) != 0 {
+		t.Errorf("nil surface encoded as % x", r.data)
+	}
+	empty := ""
+	r = NewRequest(3, 0, NullableString(nil), NullableString(&empty))
+	if len(r.data) != 12 || order.Uint32(r.data) != 0 || order.Uint32(r.data[4:]) != 1 {
+		t.Errorf("null and empty strings encoded as % x", r.data)
+	}
+}
+
+func TestTruncatedEvent(t *testing.T) {
+	c := &Context{objects: make(map[ProxyId]Proxy)}
+	display := NewDisplay(c)
+
+	// wl_display.error with a message longer than the event
+	ev := &Event{pid: display.Id(), Opcode: 0, data: make([]byte, 16)}
+	order.PutUint32(ev.data, uint32(display.Id()))
+	order.PutUint32(ev.data[8:], 100)
+	if err := c.createProxies(display, ev); err == nil {
+		t.Error("accepted a string running past the end of the event")
+	}
+}
+
+func TestEnumArguments(t *testing.T) {
+	r := NewRequest(3, 0, uint32(SeatCapabilityPointer|SeatCapabilityKeyboard))
+	if len(r.data) != 4 || order.Uint32(r.data) != 3 {
+		t.Errorf("capabilities encoded as % x", r.data)
+	}
+	ev := &Event{Opcode: 0, data: r.data}
+	caps := SeatCapability(ev.Uint32())
+	if caps&SeatCapabilityKeyboard == 0 || caps&SeatCapabilityTouch != 0 {
+		t.Errorf("capabilities decoded as %d", caps)
+	}
+}
+
+func TestFormatArgs(t *testing.T) {
+	// a null string, an fd, and a new_id and an object of interfaces
+	// the protocol leaves open
+	msg := &Message{Name: "test", Signature: "?shsno"}
+	r := &Request{}
+	r.PutUint32(0)
+	r.PutFd(7)
+	r.PutString("wl_seat")
+	r.PutUint32(5)
+	r.PutUint32(2)
+	ev := &Event{data: r.data, fds: r.fds}
+	args := ev.FormatArgs(msg, func(id ProxyId) *Interface {
+		if id == 2 {
+			return &Interface{Name: "wl_registry"}
+		}
+		return nil
+	})
+	want := []string{"nil", "fd 7", `"wl_seat"`, "new id wl_seat@5", "wl_registry@2"}
+	if len(args) != len(want) {
+		t.Fatalf("got %q, want %q", args, want)
+	}
+	for i := range want {
+		if args[i] != want[i] {
+			t.Errorf("argument %d formatted as %s, want %s", i, args[i], want[i])
+		}
+	}
+}
+
+// expectGetRegistry has display send wl_display.get_registry, and
+// checks that it arrives at server.
+func expectGetRegistry(t *testing.T, display *Display, server *Conn) {
+	t.Helper()
+	registry, err := display.GetRegistry()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := display.Context().Flush(); err != nil {
+		t.Fatal(err)
+	}
+	ev, err := server.ReadMessage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ev.Id() != 1 || ev.Opcode != 1 || ProxyId(ev.Uint32()) != registry.Id() {
+		t.Errorf("unexpected request %d to %d", ev.Opcode, ev.Id())
+	}
+}
+
+func TestConnectWaylandSocket(t *testing.T) {
+	fds, err := syscall.Socketpair(syscall.AF_UNIX, syscall.SOCK_STREAM|syscall.SOCK_CLOEXEC, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := os.NewFile(uintptr(fds[1]), "server")
+	c, err := net.FileConn(f)
+	f.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := NewConn(c.(*net.UnixConn))
+	defer server.Close()
+
+	t.Setenv("WAYLAND_SOCKET", strconv.Itoa(fds[0]))
+	t.Setenv("XDG_RUNTIME_DIR", "")
+	display, err := Connect("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer display.Context().Close()
+	if _, ok := os.LookupEnv("WAYLAND_SOCKET"); ok {
+		t.Error("WAYLAND_SOCKET still set")
+	}
+	expectGetRegistry(t, display, server)
+}
+
+func TestConnectAbsolute(t *testing.T) {
+	dir, err := os.MkdirTemp("", "wl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "wayland-test")
+	l, err := net.ListenUnix("unix", &net.UnixAddr{Name: path, Net: "unix"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	t.Setenv("XDG_RUNTIME_DIR", "")
+	display, err := Connect(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer display.Context().Close()
+	c, err := l.AcceptUnix()
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := NewConn(c)
+	defer server.Close()
+	expectGetRegistry(t, display, server)
+
+	if _, err := Connect("wayland-test"); err == nil {
+		t.Error("relative name connected without XDG_RUNTIME_DIR")
+	}
+}
+
+func TestConnectFd(t *testing.T) {
+	if _, err := ConnectFd(-1); err == nil {
+		t.Error("connected to fd -1")
+	}
+	var pipe [2]int
+	if err := syscall.Pipe(pipe[:]); err != nil {
+		t.Fatal(err)
+	}
+	defer syscall.Close(pipe[1])
+	if _, err := ConnectFd(pipe[0]); err == nil {
+		t.Error("connected to a pipe")
+	}
+}
+
+func TestClose(t *testing.T) {
+	a, server := socketPair(t)
+	defer server.Close()
+	display := NewContextFromConn(a.UnixConn())
+	c := display.Context()
+	keyboard := NewKeyboard(c)
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	// an event that is never dispatched
+	keymap := NewRequest(keyboard.Id(), 0, uint32(KeyboardKeymapFormatXkbV1), w.Fd(), uint32(0))
+	err = server.WriteMessage(keymap)
+	w.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(time.Millisecond) {
+		c.queue.mu.Lock()
+		n := len(c.queue.events)
+		c.queue.mu.Unlock()
+		if n > 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("event not received")
+		}
+	}
+
+	select {
+	case <-c.Done():
+		t.Fatal("Done closed before Close")
+	default:
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c.Close()
+		}()
+	}
+	wg.Wait()
+	<-c.Done()
+	if err := c.Err(); err != ErrClosed {
+		t.Errorf("Err is %v", err)
+	}
+	if c.lookupProxy(keyboard.Id()) != nil {
+		t.Error("proxy still registered")
+	}
+	if err := c.Run(context.Background()); err != ErrClosed {
+		t.Errorf("Run returned %v", err)
+	}
+	if _, err := display.Sync(); err != ErrClosed {
+		t.Errorf("request after Close returned %v", err)
+	}
+
+	// the fd of the event was closed, so the pipe has no writer left
+	r.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if _, err := r.Read(make([]byte, 1)); err != io.EOF {
+		t.Errorf("reading the pipe returned %v", err)
+	}
+}
+
+func TestDestroyHooks(t *testing.T) {
+	c := &Context{
+		objects: make(map[ProxyId]Proxy),
+		zombies: make(map[ProxyId]*Interface),
+	}
+	s := new(Surface)
+	c.Register(s)
+	s.SetUserData("window")
+	if s.UserData() != "window" {
+		t.Errorf("user data is %v", s.UserData())
+	}
+	var calls int
+	s.OnDestroy(func() { calls++ })
+	cancel := s.OnDestroy(func() { t.Error("canceled hook called") })
+	cancel()
+
+	c.Unregister(s)
+	c.deleteId(s.Id())
+	c.Unregister(s)
+	if calls != 1 {
+		t.Errorf("destroy hook called %d times", calls)
+	}
+
+	// objects created by the server are destroyed when it deletes
+	// their id
+	offer := new(DataOffer)
+	c.registerAt(ServerIdStart, offer)
+	destroyed := false
+	offer.OnDestroy(func() { destroyed = true })
+	c.deleteId(ServerIdStart)
+	if !destroyed {
+		t.Error("delete_id did not destroy the proxy")
+	}
+}
+
+func TestFailedConstructor(t *testing.T) {
+	c := &Context{
+		objects: make(map[ProxyId]Proxy),
+		zombies: make(map[ProxyId]*Interface),
+		done:    make(chan struct{}),
+	}
+	compositor := NewCompositor(c)
+	c.fail(ErrClosed)
+	if s, err := compositor.CreateSurface(); s != nil || err != ErrClosed {
+		t.Fatalf("CreateSurface returned %v, %v", s, err)
+	}
+	shm := NewShm(c)
+	if err := NewRegistry(c).Bind(1, "wl_shm", 1, shm); err != ErrClosed {
+		t.Fatalf("Bind returned %v", err)
+	}
+	if c.lookupProxy(shm.Id()) != nil {
+		t.Error("proxy of a failed bind still registered")
+	}
+	// the ids of the objects never sent are reused, without gaps
+	var ids []ProxyId
+	for i := 0; i < 3; i++ {
+		s := new(Surface)
+		c.Register(s)
+		ids = append(ids, s.Id())
+	}
+	if want := []ProxyId{2, 4, 5}; ids[0] != want[0] || ids[1] != want[1] || ids[2] != want[2] {
+		t.Errorf("got ids %v, want %v", ids, want)
+	}
+}
+
+func TestRefusedDestructor(t *testing.T) {
+	c := &Context{
+		objects: make(map[ProxyId]Proxy),
+		zombies: make(map[ProxyId]*Interface),
+	}
+	k := NewKeyboard(c)
+	k.SetVersion(1)
+	// wl_keyboard.release needs version 3, and is not sent
+	if err := k.Release(); !errors.Is(err, ErrUnsupportedVersion) {
+		t.Fatalf("release returned %v", err)
+	}
+	if c.lookupProxy(k.Id()) != k || isZombie(c, k.Id()) {
+		t.Error("keyboard unregistered without sending release")
+	}
+}
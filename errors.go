@@ -0,0 +1,113 @@
+package wl
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrUnsupportedVersion is returned for requests the object does not
+// support, because it was bound at a version older than the one that
+// introduced the request.
+var ErrUnsupportedVersion = errors.New("unsupported version")
+
+// ErrClosed is the error of a Context stopped by Close.
+var ErrClosed = errors.New("connection closed")
+
+// CheckVersion returns an error wrapping ErrUnsupportedVersion if
+// request opcode is newer than the version of proxy.  SendRequest
+// checks it too, but requests creating objects check it first, so as
+// not to allocate an id for an object that is never created.
+func CheckVersion(proxy Proxy, opcode uint32) error {
+	iface := interfaceOf(proxy)
+	version := proxy.Version()
+	if iface == nil || version == 0 || int(opcode) >= len(iface.Requests) {
+		return nil
+	}
+	req := &iface.Requests[opcode]
+	if req.Since > version {
+		return fmt.Errorf("%s.%s needs version %d, %s@%d has version %d: %w",
+			iface.Name, req.Name, req.Since, iface.Name, proxy.Id(), version, ErrUnsupportedVersion)
+	}
+	return nil
+}
+
+// ProtocolError is a fatal error reported by the server with the
+// wl_display.error event.  The server disconnects the client right
+// after sending it.
+type ProtocolError struct {
+	Object    ProxyId // the object the error is about
+	Interface string  // the interface of Object, if known
+	Code      uint32  // a value of the error enum of Interface
+	Message   string
+}
+
+func (e *ProtocolError) Error() string {
+	code := fmt.Sprint(e.Code)
+	if name := errorName(e.Interface, e.Code); name != "" {
+		code += " (" + name + ")"
+	}
+	return fmt.Sprintf("%s@%d: error %s: %s", e.Interface, e.Object, code, e.Message)
+}
+
+// errorName returns the name of code in the error enum of the
+// interface, or the empty string if the interface is unknown or has no
+// error enum.
+func errorName(iface string, code uint32) string {
+	i := LookupInterface(iface)
+	if i == nil {
+		return ""
+	}
+	return i.Errors[code]
+}
+
+// decodeProtocolError decodes a wl_display.error event without
+// consuming it, so that it can be dispatched afterwards.
+func (c *Context) decodeProtocolError(ev *Event) *ProtocolError {
+	e := *ev
+	err := &ProtocolError{
+		Object:  ProxyId(e.Uint32()),
+		Code:    e.Uint32(),
+		Message: e.String(),
+	}
+	if p := c.lookupProxy(err.Object); p != nil {
+		if iface := interfaceOf(p); iface != nil {
+			err.Interface = iface.Name
+		}
+	} else if iface, _ := c.zombie(err.Object); iface != nil {
+		err.Interface = iface.Name
+	}
+	return err
+}
+
+// fail records err as the reason the Context stopped working.  Only
+// the first error is kept.
+func (c *Context) fail(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.err != nil {
+		return
+	}
+	c.err = err
+	close(c.done)
+}
+
+// failure returns the error that stopped the Context, or nil.
+func (c *Context) failure() error {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.err
+}
+
+// Done returns a channel that is closed when the Context stops, because
+// the server reported a protocol error, the connection broke or Close
+// was called.
+func (c *Context) Done() <-chan struct{} {
+	return c.done
+}
+
+// Err returns nil until Done is closed, and then the reason the Context
+// stopped: a *ProtocolError, the error that broke the connection, or
+// ErrClosed.
+func (c *Context) Err() error {
+	return c.failure()
+}
@@ -0,0 +1,60 @@
+package wl_test
+
+import (
+	"testing"
+
+	"github.com/dkolbly/wl"
+)
+
+func TestProtocolError(t *testing.T) {
+	display, ctx := connect(t, nil)
+
+	registry, err := display.GetRegistry()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := registry.Bind(42, "wl_compositor", 1, wl.NewCompositor(display.Context())); err != nil {
+		t.Fatal(err)
+	}
+	err = display.Roundtrip(ctx)
+	perr, ok := err.(*wl.ProtocolError)
+	if !ok {
+		t.Fatalf("got %v, want a protocol error", err)
+	}
+	if perr.Object != registry.Id() || perr.Interface != "wl_registry" || perr.Code != wl.DisplayErrorInvalidObject {
+		t.Errorf("unexpected error %#v", perr)
+	}
+	// the code is wl_display's invalid_object, which wl_registry has
+	// no name for
+	if want := "wl_registry@2: error 0: invalid global wl_compositor (42)"; perr.Error() != want {
+		t.Errorf("got %q, want %q", perr.Error(), want)
+	}
+	<-display.Context().Done()
+	if err := display.Context().Err(); err != perr {
+		t.Errorf("Err delivered %v", err)
+	}
+	if _, err := display.Sync(); err != perr {
+		t.Errorf("request after the error returned %v", err)
+	}
+}
+
+func TestProtocolErrorNames(t *testing.T) {
+	for _, c := range []struct {
+		err  wl.ProtocolError
+		want string
+	}{
+		{wl.ProtocolError{Object: 1, Interface: "wl_display", Code: 1, Message: "m"},
+			"wl_display@1: error 1 (invalid_method): m"},
+		{wl.ProtocolError{Object: 5, Interface: "wl_shm", Code: 1, Message: "m"},
+			"wl_shm@5: error 1 (invalid_stride): m"},
+		// wl_callback has no error enum
+		{wl.ProtocolError{Object: 3, Interface: "wl_callback", Code: 0, Message: "m"},
+			"wl_callback@3: error 0: m"},
+		{wl.ProtocolError{Object: 9, Code: 0, Message: "m"},
+			"@9: error 0: m"},
+	} {
+		if s := c.err.Error(); s != c.want {
+			t.Errorf("got %q, want %q", s, c.want)
+		}
+	}
+}
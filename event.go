@@ -2,73 +2,54 @@ package wl
 
 import (
 	"bytes"
-	"fmt"
-	"syscall"
 )
 
 type Event struct {
 	pid    ProxyId
+	proxy  Proxy // the object the event was read for
 	Opcode uint32
 	data   []byte
-	scms   []syscall.SocketControlMessage
+	fds    []int
 	off    int
+	// release marks an event queued by deleteId, which releases the
+	// id of proxy when it is dispatched
+	release bool
 }
 
-func (c *Context) readEvent() (*Event, error) {
-	buf := bytePool.Take(8)
-	control := bytePool.Take(24)
-
-	n, oobn, _, _, err := c.conn.ReadMsgUnix(buf[:], control)
-	if err != nil {
-		return nil, err
-	}
-	if n != 8 {
-		return nil, fmt.Errorf("Unable to read message header.")
-	}
-	ev := new(Event)
-	if oobn > 0 {
-		if oobn > len(control) {
-			return nil, fmt.Errorf("Unsufficient control msg buffer")
-		}
-		scms, err := syscall.ParseSocketControlMessage(control)
-		if err != nil {
-			return nil, fmt.Errorf("Control message parse error: %s", err)
-		}
-		ev.scms = scms
-	}
-
-	ev.pid = ProxyId(order.Uint32(buf[0:4]))
-	ev.Opcode = uint32(order.Uint16(buf[4:6]))
-	size := uint32(order.Uint16(buf[6:8]))
+// Id returns the id of the object the message is addressed to.
+func (ev *Event) Id() ProxyId {
+	return ev.pid
+}
 
-	// subtract 8 bytes from header
-	data := bytePool.Take(int(size) - 8)
-	n, err = c.conn.Read(data)
-	if err != nil {
-		return nil, err
-	}
-	if n != int(size)-8 {
-		return nil, fmt.Errorf("Invalid message size.")
+// FD returns the next file descriptor claimed by the message.  The
+// caller owns it and is responsible for closing it.
+func (ev *Event) FD() uintptr {
+	if len(ev.fds) == 0 {
+		panic("Unable to read file descriptor")
 	}
-	ev.data = data
-
-	bytePool.Give(buf)
-	bytePool.Give(control)
+	fd := ev.fds[0]
+	ev.fds = ev.fds[1:]
+	return uintptr(fd)
+}
 
-	return ev, nil
+// CloseFds closes the file descriptors of the message that were not
+// taken with FD.
+func (ev *Event) CloseFds() {
+	closeFds(ev.fds)
+	ev.fds = nil
 }
 
-func (ev *Event) FD() uintptr {
-	if ev.scms == nil {
-		return 0
-	}
-	fds, err := syscall.ParseUnixRights(&ev.scms[0])
-	if err != nil {
-		panic("Unable to parse unix rights")
+// Request returns a message with the contents and the claimed file
+// descriptors of ev, for writing it to another connection, as a proxy
+// between a client and a server does.  ev keeps its file descriptors,
+// which are duplicated when the message is queued.
+func (ev *Event) Request() *Request {
+	return &Request{
+		pid:    ev.pid,
+		opcode: ev.Opcode,
+		data:   append([]byte(nil), ev.data...),
+		fds:    append([]int(nil), ev.fds...),
 	}
-	//TODO is this required
-	ev.scms = append(ev.scms, ev.scms[1:]...)
-	return uintptr(fds[0])
 }
 
 func (ev *Event) Uint32() uint32 {
@@ -91,9 +72,7 @@ func (ev *Event) String() string {
 	}
 	ret := string(bytes.TrimRight(buf, "\x00"))
 	//padding to 32 bit boundary
-	if (l & 0x3) != 0 {
-		ev.next(4 - (l & 0x3))
-	}
+	ev.next(padding(l))
 	return ret
 }
 
@@ -101,20 +80,31 @@ func (ev *Event) Int32() int32 {
 	return int32(ev.Uint32())
 }
 
+// Fixed reads a fixed-point argument.
+func (ev *Event) Fixed() Fixed {
+	return Fixed(ev.Int32())
+}
+
 func (ev *Event) Float32() float32 {
 	return float32(fixedToFloat64(ev.Int32()))
 }
 
-func (ev *Event) Array() []int32 {
+// Array reads an array argument.  The returned Array is a copy, which
+// stays valid after the event was dispatched.
+func (ev *Event) Array() Array {
 	l := int(ev.Uint32())
-	arr := make([]int32, l/4)
-	for i := range arr {
-		arr[i] = ev.Int32()
+	buf := ev.next(l)
+	if len(buf) != l {
+		panic("Unable to read array")
 	}
-	return arr
+	ev.next(padding(l))
+	return append(Array(nil), buf...)
 }
 
 func (ev *Event) next(n int) []byte {
+	if ev.off+n > len(ev.data) {
+		n = len(ev.data) - ev.off
+	}
 	ret := ev.data[ev.off : ev.off+n]
 	ev.off += n
 	return ret
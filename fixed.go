@@ -0,0 +1,65 @@
+package wl
+
+import (
+	"fmt"
+	"math"
+)
+
+// Fixed is a signed 24.8 fixed-point number, the wl_fixed_t of the
+// protocol.  Every Fixed converts to a float64 exactly.
+type Fixed int32
+
+// FromFloat64 returns the Fixed closest to v, rounding halfway cases to
+// even like libwayland.  Values out of range saturate.
+func FromFloat64(v float64) Fixed {
+	v = math.RoundToEven(v * 256)
+	switch {
+	case math.IsNaN(v):
+		return 0
+	case v >= math.MaxInt32:
+		return math.MaxInt32
+	case v <= math.MinInt32:
+		return math.MinInt32
+	}
+	return Fixed(v)
+}
+
+// FromInt returns i as a Fixed.  i must be within ±2^23.
+func FromInt(i int) Fixed {
+	return Fixed(i << 8)
+}
+
+// Float64 returns f as a float64, without loss.
+func (f Fixed) Float64() float64 {
+	return float64(f) / 256
+}
+
+// Int returns the integer part of f, truncating towards zero like
+// wl_fixed_to_int.
+func (f Fixed) Int() int {
+	return int(f / 256)
+}
+
+// Add returns f+g.
+func (f Fixed) Add(g Fixed) Fixed {
+	return f + g
+}
+
+// Sub returns f-g.
+func (f Fixed) Sub(g Fixed) Fixed {
+	return f - g
+}
+
+// Mul returns f*g, rounded towards negative infinity.
+func (f Fixed) Mul(g Fixed) Fixed {
+	return Fixed(int64(f) * int64(g) >> 8)
+}
+
+// Div returns f/g, truncated towards zero.  It panics if g is zero.
+func (f Fixed) Div(g Fixed) Fixed {
+	return Fixed(int64(f) << 8 / int64(g))
+}
+
+func (f Fixed) String() string {
+	return fmt.Sprint(f.Float64())
+}
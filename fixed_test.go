@@ -0,0 +1,126 @@
+package wl
+
+import (
+	"flag"
+	"fmt"
+	"math"
+	"testing"
+)
+
+var exhaustive = flag.Bool("exhaustive", false, "check all 2^32 Fixed values")
+
+// TestFixedRoundTrip checks a spread of Fixed values and those around
+// zero and the limits, or every value with -exhaustive.
+func TestFixedRoundTrip(t *testing.T) {
+	step := int64(4099)
+	if *exhaustive {
+		step = 1
+	}
+	for i := int64(math.MinInt32); i <= math.MaxInt32; i += step {
+		if err := checkFixed(i); err != "" {
+			t.Fatal(err)
+		}
+	}
+	for _, base := range []int64{math.MinInt32 + 1024, 0, math.MaxInt32 - 1024} {
+		for i := base - 1024; i <= base+1024; i++ {
+			if err := checkFixed(i); err != "" {
+				t.Fatal(err)
+			}
+		}
+	}
+}
+
+// checkFixed returns what is wrong with the conversions of Fixed(i),
+// if anything.
+func checkFixed(i int64) string {
+	f := Fixed(i)
+	d := f.Float64()
+	if d*256 != float64(i) {
+		return fmt.Sprintf("%d: Float64 is %v", i, d)
+	}
+	if g := FromFloat64(d); g != f {
+		return fmt.Sprintf("%d: round trip through %v gives %d", i, d, g)
+	}
+	if n := f.Int(); n != int(int32(i)/256) {
+		return fmt.Sprintf("%d: Int is %d", i, n)
+	}
+	return ""
+}
+
+func TestFixedFromFloat64(t *testing.T) {
+	for _, c := range []struct {
+		v float64
+		f Fixed
+	}{
+		{0, 0},
+		{1, 256},
+		{-1, -256},
+		{0.5, 128},
+		{288.1875, 0x12030},
+		{-288.1875, -0x12030},
+		// halfway between two steps rounds to even
+		{1.0 / 512, 0},
+		{3.0 / 512, 2},
+		{-3.0 / 512, -2},
+		{0.3 / 256, 0},
+		{0.7 / 256, 1},
+		{1e12, math.MaxInt32},
+		{-1e12, math.MinInt32},
+		{math.Inf(1), math.MaxInt32},
+		{math.NaN(), 0},
+	} {
+		if f := FromFloat64(c.v); f != c.f {
+			t.Errorf("FromFloat64(%v) = %d, want %d", c.v, f, c.f)
+		}
+	}
+}
+
+func TestFixedInt(t *testing.T) {
+	for _, c := range []struct {
+		f Fixed
+		i int
+	}{
+		{FromInt(5), 5},
+		{FromInt(-5), -5},
+		{FromFloat64(2.75), 2},
+		{FromFloat64(-2.75), -2},
+		{FromInt(1 << 22), 1 << 22},
+		{FromInt(-1 << 23), -1 << 23},
+	} {
+		if i := c.f.Int(); i != c.i {
+			t.Errorf("%v.Int() = %d, want %d", c.f, i, c.i)
+		}
+	}
+}
+
+func TestFixedArithmetic(t *testing.T) {
+	a, b := FromFloat64(2.5), FromFloat64(-1.25)
+	if s := a.Add(b); s.Float64() != 1.25 {
+		t.Errorf("Add: %v", s)
+	}
+	if s := a.Sub(b); s.Float64() != 3.75 {
+		t.Errorf("Sub: %v", s)
+	}
+	if p := a.Mul(b); p.Float64() != -3.125 {
+		t.Errorf("Mul: %v", p)
+	}
+	if q := a.Div(b); q.Float64() != -2 {
+		t.Errorf("Div: %v", q)
+	}
+	// large operands do not overflow the intermediate result
+	big := FromInt(1 << 20)
+	if p := big.Mul(FromFloat64(0.5)); p != FromInt(1<<19) {
+		t.Errorf("Mul: %v", p)
+	}
+	if q := big.Div(FromInt(1 << 10)); q != FromInt(1<<10) {
+		t.Errorf("Div: %v", q)
+	}
+}
+
+func TestFixedMatchesLegacyConversion(t *testing.T) {
+	for _, i := range []int32{0, 1, -1, 0x12030, -0x12030, math.MaxInt32, math.MinInt32} {
+		if d := Fixed(i).Float64(); d != fixedToFloat64(i) {
+			t.Errorf("%d: %v, legacy %v", i, d, fixedToFloat64(i))
+		}
+	}
+}
@@ -0,0 +1,3 @@
+package wl
+
+//go:generate go run ./cmd/wl-scanner -pkg wl -prefix wl_ -o client.go protocol/wayland.xml
@@ -0,0 +1,124 @@
+package wl
+
+import (
+	"strings"
+	"sync"
+)
+
+// Interface describes a protocol interface as declared in the
+// protocol XML.  The generated code provides one for every interface
+// so that the Context can construct proxies for objects created by
+// the server.
+type Interface struct {
+	Name string
+	// Version is the highest version of the interface the generated
+	// code supports.
+	Version  uint32
+	New      func() Proxy
+	Requests []Message
+	Events   []Message
+	// Errors maps the values of the error enum of the interface to
+	// their names.
+	Errors map[uint32]string
+}
+
+// Message describes a single request or event.  Signature holds one
+// character per argument, using the same codes as libwayland:
+//
+//	i int, u uint, f fixed, s string, o object, n new_id, a array, h fd
+//
+// preceded by ? for object, string and array arguments that may be
+// null.  Types holds, for each argument, the name of the interface of
+// an object or new_id argument, and is empty for other arguments.  Since
+// is the version of the interface that introduced the message, zero
+// meaning the first one.
+type Message struct {
+	Name      string
+	Signature string
+	Types     []string
+	Since     uint32
+}
+
+// Argument is a single argument of a message.
+type Argument struct {
+	Type      byte // the signature code
+	Nullable  bool
+	Interface string // the interface of an object or new_id
+}
+
+// Arguments splits the signature of m into its arguments.
+func (m *Message) Arguments() []Argument {
+	var args []Argument
+	nullable := false
+	for i := 0; i < len(m.Signature); i++ {
+		c := m.Signature[i]
+		if c == '?' {
+			nullable = true
+			continue
+		}
+		arg := Argument{Type: c, Nullable: nullable}
+		if n := len(args); n < len(m.Types) {
+			arg.Interface = m.Types[n]
+		}
+		args = append(args, arg)
+		nullable = false
+	}
+	return args
+}
+
+// NumFds returns the number of file descriptors the message carries.
+func (m *Message) NumFds() int {
+	return strings.Count(m.Signature, "h")
+}
+
+// Interfacer is implemented by proxies that carry a protocol
+// description.
+type Interfacer interface {
+	Interface() *Interface
+}
+
+var (
+	interfacesMu sync.RWMutex
+	interfaces   = make(map[string]*Interface)
+)
+
+// RegisterInterface makes iface known under its protocol name, so that
+// objects of that interface created by the server can be constructed.
+// The generated protocol packages register their interfaces at init.
+func RegisterInterface(iface *Interface) {
+	interfacesMu.Lock()
+	defer interfacesMu.Unlock()
+	interfaces[iface.Name] = iface
+}
+
+// LookupInterface returns the registered interface with the given
+// protocol name, or nil.
+func LookupInterface(name string) *Interface {
+	interfacesMu.RLock()
+	defer interfacesMu.RUnlock()
+	return interfaces[name]
+}
+
+// eventFds returns the number of file descriptors carried by event
+// opcode of iface, or 0 if the event is not described.
+func eventFds(iface *Interface, opcode uint32) int {
+	if iface == nil || int(opcode) >= len(iface.Events) {
+		return 0
+	}
+	return iface.Events[opcode].NumFds()
+}
+
+// ifaceName returns the name of iface for messages, which may be nil.
+func ifaceName(iface *Interface) string {
+	if iface == nil {
+		return "[unknown]"
+	}
+	return iface.Name
+}
+
+func interfaceOf(p Proxy) *Interface {
+	if i, ok := p.(Interfacer); ok {
+		return i.Interface()
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+package wl
+
+import (
+	"time"
+)
+
+// Metrics receives measurements of the traffic of a Context, to be
+// exported with expvar, Prometheus or the like.  The methods are called
+// from the goroutines sending requests, reading events and dispatching
+// them, so they must be safe for concurrent use and must not block.
+type Metrics interface {
+	// Message counts a request sent or an event received for an
+	// object of the named interface, empty if the object is unknown.
+	// size is the size of the message in bytes, header included, and
+	// fds the number of file descriptors passed with it.
+	Message(sent bool, iface string, opcode uint32, size, fds int)
+	// Roundtrip reports the time from sending wl_display.sync, as
+	// Display.Sync and Roundtrip do, until the done event of its
+	// callback was received.
+	Roundtrip(d time.Duration)
+	// Dispatch reports the time the handlers of an event took.
+	Dispatch(iface string, opcode uint32, d time.Duration)
+}
+
+// SetMetrics makes the Context report its traffic to m.  A nil m turns
+// the measurements off.
+func (c *Context) SetMetrics(m Metrics) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.metrics = m
+	c.syncs = nil
+}
+
+func (c *Context) getMetrics() Metrics {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.metrics
+}
+
+// countRequest reports a request about to be sent.  For
+// wl_display.sync, it notes the time, to report the roundtrip when the
+// callback is done.
+func (c *Context) countRequest(proxy Proxy, r *Request) {
+	m := c.getMetrics()
+	if m == nil {
+		return
+	}
+	m.Message(true, metricsName(interfaceOf(proxy)), r.opcode, len(r.data)+8, len(r.fds))
+	if _, ok := proxy.(*Display); ok && r.opcode == 0 && len(r.data) >= 4 {
+		c.mu.Lock()
+		if c.syncs == nil {
+			c.syncs = make(map[ProxyId]time.Time)
+		}
+		c.syncs[ProxyId(order.Uint32(r.data))] = time.Now()
+		c.mu.Unlock()
+	}
+}
+
+// countEvent reports an event received for an object of iface, which
+// may be nil.
+func (c *Context) countEvent(iface *Interface, ev *Event) {
+	m := c.getMetrics()
+	if m == nil {
+		return
+	}
+	name := metricsName(iface)
+	m.Message(false, name, ev.Opcode, len(ev.data)+8, len(ev.fds))
+	if name == "wl_callback" && ev.Opcode == 0 {
+		c.mu.Lock()
+		start, ok := c.syncs[ev.pid]
+		delete(c.syncs, ev.pid)
+		c.mu.Unlock()
+		if ok {
+			m.Roundtrip(time.Since(start))
+		}
+	}
+}
+
+// metricsName returns the name of iface, or the empty string if it is
+// nil.
+func metricsName(iface *Interface) string {
+	if iface == nil {
+		return ""
+	}
+	return iface.Name
+}
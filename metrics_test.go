@@ -0,0 +1,87 @@
+package wl
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+type testMetrics struct {
+	mu         sync.Mutex
+	messages   map[string]int
+	bytes      int
+	fds        int
+	roundtrips int
+	dispatched map[string]int
+}
+
+func (m *testMetrics) Message(sent bool, iface string, opcode uint32, size, fds int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	dir := "event"
+	if sent {
+		dir = "request"
+	}
+	m.messages[dir+" "+iface+" "+string(rune('0'+opcode))]++
+	m.bytes += size
+	m.fds += fds
+}
+
+func (m *testMetrics) Roundtrip(d time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.roundtrips++
+}
+
+func (m *testMetrics) Dispatch(iface string, opcode uint32, d time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.dispatched[iface]++
+}
+
+func TestMetrics(t *testing.T) {
+	a, server := socketPair(t)
+	defer server.Close()
+	display := NewContextFromConn(a.UnixConn())
+	c := display.Context()
+	defer c.Close()
+	m := &testMetrics{messages: make(map[string]int), dispatched: make(map[string]int)}
+	c.SetMetrics(m)
+
+	// the server answers the sync of the roundtrip
+	go func() {
+		ev, err := server.ReadMessage()
+		if err != nil {
+			return
+		}
+		callback := ProxyId(ev.Uint32())
+		server.WriteMessage(NewRequest(callback, 0, uint32(7)))
+		server.WriteMessage(NewRequest(1, 1, uint32(callback)))
+	}()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := display.Roundtrip(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.DispatchOne(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, key := range []string{"request wl_display 0", "event wl_callback 0", "event wl_display 1"} {
+		if m.messages[key] != 1 {
+			t.Errorf("%q counted %d times", key, m.messages[key])
+		}
+	}
+	if m.bytes != 3*12 || m.fds != 0 {
+		t.Errorf("%d bytes and %d fds", m.bytes, m.fds)
+	}
+	if m.roundtrips != 1 {
+		t.Errorf("%d roundtrips", m.roundtrips)
+	}
+	if m.dispatched["wl_callback"] != 1 || m.dispatched["wl_display"] != 1 {
+		t.Errorf("dispatched %v", m.dispatched)
+	}
+}
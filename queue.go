@@ -0,0 +1,252 @@
+package wl
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+)
+
+// EventQueue holds the events read from the connection until they are
+// dispatched.  Every Context has a default queue, dispatched by Run and
+// friends.  Proxies can be moved to queues of their own with SetQueue,
+// so that a component can wait for its events without dispatching, or
+// being stalled by, the events of the rest of the application.
+type EventQueue struct {
+	ctx    *Context
+	mu     sync.Mutex
+	events []*Event
+	// wake is signalled when events are added
+	wake chan struct{}
+	// destroyed is set by Destroy, after which the events pushed go
+	// to the default queue
+	destroyed bool
+}
+
+// NewEventQueue creates a queue for the events of proxies moved to it
+// with SetQueue.
+func (c *Context) NewEventQueue() *EventQueue {
+	q := newQueue(c)
+	c.mu.Lock()
+	c.queues = append(c.queues, q)
+	c.mu.Unlock()
+	return q
+}
+
+// Destroy discards the events on the queue and stops the Context from
+// tracking it.  The events that arrive afterwards for proxies still on
+// the queue are dispatched on the default queue.
+func (q *EventQueue) Destroy() {
+	c := q.ctx
+	c.mu.Lock()
+	for i, other := range c.queues {
+		if other == q {
+			c.queues = append(c.queues[:i], c.queues[i+1:]...)
+			break
+		}
+	}
+	c.mu.Unlock()
+
+	q.mu.Lock()
+	q.destroyed = true
+	events := q.events
+	q.events = nil
+	q.mu.Unlock()
+	for _, ev := range events {
+		if ev.release {
+			c.releaseId(ev.pid, ev.proxy)
+			continue
+		}
+		ev.CloseFds()
+		bytePool.Give(ev.data)
+	}
+}
+
+func newQueue(c *Context) *EventQueue {
+	return &EventQueue{
+		ctx:  c,
+		wake: make(chan struct{}, 1),
+	}
+}
+
+func (q *EventQueue) push(ev *Event) {
+	q.mu.Lock()
+	if q.destroyed {
+		q.mu.Unlock()
+		q.ctx.queue.push(ev)
+		return
+	}
+	q.events = append(q.events, ev)
+	q.mu.Unlock()
+	q.signal()
+}
+
+func (q *EventQueue) signal() {
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (q *EventQueue) pop() *Event {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if len(q.events) == 0 {
+		return nil
+	}
+	ev := q.events[0]
+	q.events[0] = nil
+	q.events = q.events[1:]
+	if len(q.events) > 0 {
+		// pass the wakeup on to other dispatching goroutines
+		q.signal()
+	}
+	return ev
+}
+
+// drain discards the queued events, closing their file descriptors.
+func (q *EventQueue) drain() {
+	for ev := q.pop(); ev != nil; ev = q.pop() {
+		ev.CloseFds()
+		bytePool.Give(ev.data)
+	}
+}
+
+// dispatchPending dispatches the queued events without waiting for
+// more.
+func (q *EventQueue) dispatchPending(ctx context.Context) {
+	for ev := q.pop(); ev != nil; ev = q.pop() {
+		q.ctx.dispatch(ctx, ev)
+	}
+}
+
+// dispatchOne dispatches one event, waiting for it if none is queued.
+// It returns early when ctx is done, done is closed or the Context
+// failed.
+func (q *EventQueue) dispatchOne(ctx context.Context, done <-chan struct{}) error {
+	for {
+		if ev := q.pop(); ev != nil {
+			q.ctx.dispatch(ctx, ev)
+			return nil
+		}
+		// the server may be waiting for requests sent by handlers
+		// before it sends more events
+		if err := q.ctx.Flush(); err != nil {
+			q.ctx.fail(err)
+		}
+		select {
+		case <-q.wake:
+		case <-done:
+			return nil
+		case <-q.ctx.done:
+			if ev := q.pop(); ev != nil {
+				// events read before the failure, such as the
+				// protocol error itself, are still dispatched
+				q.ctx.dispatch(ctx, ev)
+				return nil
+			}
+			return q.ctx.failure()
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// Dispatch dispatches the events on the queue on the calling
+// goroutine, waiting for at least one if none has been received yet.
+func (q *EventQueue) Dispatch(ctx context.Context) error {
+	if err := q.dispatchOne(ctx, nil); err != nil {
+		return err
+	}
+	q.dispatchPending(ctx)
+	return nil
+}
+
+// DispatchPending dispatches the events received so far on the queue,
+// without waiting for more.  It returns the error that stopped the
+// Context, if any.
+func (q *EventQueue) DispatchPending() error {
+	q.dispatchPending(context.Background())
+	return q.ctx.failure()
+}
+
+// Roundtrip blocks until the server has processed all requests sent
+// before it, dispatching the events on the queue in the meantime.
+func (q *EventQueue) Roundtrip(ctx context.Context) error {
+	callback := NewCallback(q.ctx)
+	callback.SetQueue(q)
+	done := make(chan struct{})
+	var once sync.Once
+	callback.OnDone(func(CallbackDoneEvent) {
+		once.Do(func() { close(done) })
+	})
+	if err := q.ctx.SendRequest(q.ctx.display, 0, callback); err != nil {
+		q.ctx.Discard(callback)
+		return err
+	}
+
+	for {
+		select {
+		case <-done:
+			return nil
+		default:
+		}
+		// another goroutine may dispatch the done event
+		if err := q.dispatchOne(ctx, done); err != nil {
+			return err
+		}
+	}
+}
+
+// Run dispatches events on the calling goroutine until ctx is done or
+// the Context fails.  It returns ctx.Err() or the error that stopped
+// the Context, such as a *ProtocolError.  To have handlers run in the
+// background, call it in a goroutine of its own.
+func (c *Context) Run(ctx context.Context) error {
+	for {
+		if err := c.queue.dispatchOne(ctx, nil); err != nil {
+			return err
+		}
+	}
+}
+
+// DispatchOne dispatches one event on the calling goroutine, waiting
+// for it if none has been received yet.
+func (c *Context) DispatchOne(ctx context.Context) error {
+	return c.queue.dispatchOne(ctx, nil)
+}
+
+// DispatchPending dispatches the events received so far on the calling
+// goroutine, without waiting for more.  It returns the error that
+// stopped the Context, if any.
+func (c *Context) DispatchPending() error {
+	return c.queue.DispatchPending()
+}
+
+// dispatch calls the handlers of ev, unless its object was destroyed
+// after the event was read.
+func (c *Context) dispatch(ctx context.Context, ev *Event) {
+	if ev.release {
+		c.releaseId(ev.pid, ev.proxy)
+		return
+	}
+	defer bytePool.Give(ev.data)
+	// close the fds no handler took
+	defer ev.CloseFds()
+
+	if c.lookupProxy(ev.pid) != ev.proxy {
+		return
+	}
+	if dispatcher, ok := ev.proxy.(Dispatcher); ok {
+		if m := c.getMetrics(); m != nil {
+			start := time.Now()
+			defer func() {
+				m.Dispatch(metricsName(interfaceOf(ev.proxy)), ev.Opcode, time.Since(start))
+			}()
+		}
+		dispatcher.Dispatch(ctx, ev)
+	} else {
+		log.Printf("%s@%d: event %d not dispatched: the proxy has no Dispatch method",
+			ifaceName(interfaceOf(ev.proxy)), ev.pid, ev.Opcode)
+	}
+}
@@ -0,0 +1,114 @@
+package wl_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/dkolbly/wl"
+	"github.com/dkolbly/wl/server"
+)
+
+type canceler context.CancelFunc
+
+func (c canceler) HandleCallbackDone(ev wl.CallbackDoneEvent) {
+	c()
+}
+
+func TestRun(t *testing.T) {
+	display, sctx := connect(t, func(srv *server.Display) {
+		srv.AddGlobal("wl_compositor", 4, nil)
+	})
+
+	registry, err := display.GetRegistry()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the handlers run on this goroutine, so no locking is needed
+	seen := make(globals)
+	registry.AddGlobalHandler(seen)
+	callback, err := display.Sync()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(sctx)
+	callback.AddDoneHandler(canceler(cancel))
+	if err := display.Context().Run(ctx); err != context.Canceled {
+		t.Fatalf("Run returned %v", err)
+	}
+	if _, ok := seen["wl_compositor"]; !ok {
+		t.Error("global not dispatched before wl_callback.done")
+	}
+	if err := display.Context().DispatchPending(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestEventQueue(t *testing.T) {
+	display, ctx := connect(t, func(srv *server.Display) {
+		srv.AddGlobal("wl_compositor", 4, nil)
+	})
+
+	// the registry inherits the queue of the display
+	q := display.Context().NewEventQueue()
+	display.SetQueue(q)
+	registry, err := display.GetRegistry()
+	display.SetQueue(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if registry.Queue() != q {
+		t.Fatal("registry not on the queue of its factory")
+	}
+	seen := make(globals)
+	registry.AddGlobalHandler(seen)
+
+	if err := display.Roundtrip(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if len(seen) != 0 {
+		t.Fatal("events of the queue dispatched by the default queue")
+	}
+	if err := q.Roundtrip(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := seen["wl_compositor"]; !ok {
+		t.Error("global not dispatched by the queue")
+	}
+}
+
+func TestDestroyEventQueue(t *testing.T) {
+	display, ctx := connect(t, func(srv *server.Display) {
+		srv.AddGlobal("wl_compositor", 4, nil)
+	})
+
+	q := display.Context().NewEventQueue()
+	display.SetQueue(q)
+	defer display.SetQueue(nil)
+	registry, err := display.GetRegistry()
+	if err != nil {
+		t.Fatal(err)
+	}
+	seen := make(globals)
+	registry.AddGlobalHandler(seen)
+	if err := display.Roundtrip(ctx); err != nil {
+		t.Fatal(err)
+	}
+	q.Destroy()
+
+	// the callback is made on the destroyed queue
+	callback, err := display.Sync()
+	if err != nil {
+		t.Fatal(err)
+	}
+	done := false
+	callback.OnDone(func(wl.CallbackDoneEvent) { done = true })
+	if err := display.Roundtrip(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if len(seen) != 0 {
+		t.Error("events queued before Destroy dispatched")
+	}
+	if !done {
+		t.Error("event for the destroyed queue not dispatched on the default queue")
+	}
+}
@@ -0,0 +1,250 @@
+package wl
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// A recording starts with recordMagic, followed by one record per
+// message:
+//
+//	uvarint  microseconds since the previous record, or since the
+//	         recording started
+//	byte     1 for a request sent, 0 for an event received
+//	byte     the number of file descriptors
+//	per fd   uvarint length+1 of the contents of the file, or 0 if
+//	         they were not recorded, followed by the contents
+//	message  the message as on the wire, header included
+//
+// The contents of file descriptors are recorded for events only, and
+// only for regular files up to maxRecordedFile bytes, such as keymaps.
+const recordMagic = "wlrec\x00\x01\x00"
+
+const maxRecordedFile = 1 << 20
+
+// Recorder writes the messages exchanged by a Context to a recording,
+// which RecordReader reads back, for example to replay the events
+// with cmd/wl-replay.
+type Recorder struct {
+	mu   sync.Mutex
+	w    io.Writer
+	last time.Time
+	err  error
+}
+
+// NewRecorder starts a recording written to w.
+func NewRecorder(w io.Writer) (*Recorder, error) {
+	if _, err := io.WriteString(w, recordMagic); err != nil {
+		return nil, err
+	}
+	return &Recorder{w: w, last: time.Now()}, nil
+}
+
+// Err returns the first error writing the recording.  Messages are not
+// recorded after an error.
+func (r *Recorder) Err() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.err
+}
+
+func (r *Recorder) record(sent bool, id ProxyId, opcode uint32, data []byte, fds []int) {
+	var buf bytes.Buffer
+	now := time.Now()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.err != nil {
+		return
+	}
+	buf.Write(binary.AppendUvarint(nil, uint64(now.Sub(r.last).Microseconds())))
+	r.last = now
+	if sent {
+		buf.WriteByte(1)
+	} else {
+		buf.WriteByte(0)
+	}
+	buf.WriteByte(byte(len(fds)))
+	for _, fd := range fds {
+		var contents []byte
+		if !sent {
+			contents = fileContents(fd)
+		}
+		if contents == nil {
+			buf.WriteByte(0)
+			continue
+		}
+		buf.Write(binary.AppendUvarint(nil, uint64(len(contents))+1))
+		buf.Write(contents)
+	}
+	var header [8]byte
+	order.PutUint32(header[0:4], uint32(id))
+	order.PutUint32(header[4:8], uint32(len(data)+8)<<16|opcode&0x0000ffff)
+	buf.Write(header[:])
+	buf.Write(data)
+	_, r.err = r.w.Write(buf.Bytes())
+}
+
+// fileContents returns the contents of fd if it is a small regular
+// file, without moving its offset, or nil.
+func fileContents(fd int) []byte {
+	var st syscall.Stat_t
+	if err := syscall.Fstat(fd, &st); err != nil {
+		return nil
+	}
+	if st.Mode&syscall.S_IFMT != syscall.S_IFREG || st.Size > maxRecordedFile {
+		return nil
+	}
+	contents := make([]byte, st.Size)
+	n, err := syscall.Pread(fd, contents, 0)
+	if err != nil {
+		return nil
+	}
+	return contents[:n]
+}
+
+// SetRecorder makes the Context record every message to r.  A nil r
+// stops recording.  If the WL_RECORD environment variable is set, a
+// new Context records to the file it names.
+func (c *Context) SetRecorder(r *Recorder) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.recorder = r
+}
+
+func (c *Context) getRecorder() *Recorder {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.recorder
+}
+
+// recordFromEnv starts the recording asked for by WL_RECORD, and
+// returns the file to close with the Context.
+func (c *Context) recordFromEnv() *os.File {
+	name := os.Getenv("WL_RECORD")
+	if name == "" {
+		return nil
+	}
+	f, err := os.Create(name)
+	if err != nil {
+		log.Printf("WL_RECORD: %s", err)
+		return nil
+	}
+	r, err := NewRecorder(f)
+	if err != nil {
+		log.Printf("WL_RECORD: %s", err)
+		f.Close()
+		return nil
+	}
+	c.recorder = r
+	return f
+}
+
+// RecordedMessage is a message read from a recording.
+type RecordedMessage struct {
+	Time   time.Duration // since the recording started
+	Sent   bool          // a request sent, rather than an event received
+	Object ProxyId
+	Opcode uint32
+	Data   []byte // the arguments, as on the wire
+	// Files holds the contents of the file descriptors of the
+	// message, nil for those that were not recorded.
+	Files [][]byte
+}
+
+// Request returns m as a message to write to a Conn, with the given
+// file descriptors standing in for the recorded ones.
+func (m *RecordedMessage) Request(fds ...uintptr) *Request {
+	r := &Request{pid: m.Object, opcode: m.Opcode, data: append([]byte(nil), m.Data...)}
+	for _, fd := range fds {
+		r.PutFd(fd)
+	}
+	return r
+}
+
+// RecordReader reads the messages of a recording made by a Recorder.
+type RecordReader struct {
+	r    *bufio.Reader
+	time time.Duration
+}
+
+// NewRecordReader checks that r holds a recording, and returns a
+// reader for its messages.
+func NewRecordReader(r io.Reader) (*RecordReader, error) {
+	br := bufio.NewReader(r)
+	magic := make([]byte, len(recordMagic))
+	if _, err := io.ReadFull(br, magic); err != nil || string(magic) != recordMagic {
+		return nil, errors.New("not a Wayland recording")
+	}
+	return &RecordReader{r: br}, nil
+}
+
+// Next returns the next message of the recording, or io.EOF at its
+// end.
+func (rr *RecordReader) Next() (*RecordedMessage, error) {
+	us, err := binary.ReadUvarint(rr.r)
+	if err != nil {
+		return nil, err
+	}
+	m, err := rr.next()
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading recording: %w", err)
+	}
+	rr.time += time.Duration(us) * time.Microsecond
+	m.Time = rr.time
+	return m, nil
+}
+
+func (rr *RecordReader) next() (*RecordedMessage, error) {
+	m := new(RecordedMessage)
+	var head [2]byte
+	if _, err := io.ReadFull(rr.r, head[:]); err != nil {
+		return nil, err
+	}
+	m.Sent = head[0] == 1
+	for i := 0; i < int(head[1]); i++ {
+		n, err := binary.ReadUvarint(rr.r)
+		if err != nil {
+			return nil, err
+		}
+		if n == 0 {
+			m.Files = append(m.Files, nil)
+			continue
+		}
+		if n-1 > maxRecordedFile {
+			return nil, fmt.Errorf("file of %d bytes", n-1)
+		}
+		contents := make([]byte, n-1)
+		if _, err := io.ReadFull(rr.r, contents); err != nil {
+			return nil, err
+		}
+		m.Files = append(m.Files, contents)
+	}
+	var header [8]byte
+	if _, err := io.ReadFull(rr.r, header[:]); err != nil {
+		return nil, err
+	}
+	m.Object = ProxyId(order.Uint32(header[0:4]))
+	m.Opcode = uint32(order.Uint16(header[4:6]))
+	size := int(order.Uint16(header[6:8]))
+	if size < len(header) {
+		return nil, fmt.Errorf("invalid message size %d", size)
+	}
+	m.Data = make([]byte, size-len(header))
+	if _, err := io.ReadFull(rr.r, m.Data); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
@@ -0,0 +1,88 @@
+package wl
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestRecord(t *testing.T) {
+	a, server := socketPair(t)
+	defer server.Close()
+	display := NewContextFromConn(a.UnixConn())
+	c := display.Context()
+	defer c.Close()
+	var buf bytes.Buffer
+	rec, err := NewRecorder(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.SetRecorder(rec)
+
+	keyboard := NewKeyboard(c)
+	keymaps := make(chan KeyboardKeymapEvent, 1)
+	keyboard.OnKeymap(func(ev KeyboardKeymapEvent) {
+		keymaps <- ev
+	})
+	callback, err := display.Sync()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := server.ReadMessage(); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.CreateTemp("", "wl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+	f.WriteString("keymap")
+	keymap := NewRequest(keyboard.Id(), 0, uint32(KeyboardKeymapFormatXkbV1), f.Fd(), uint32(6))
+	if err := server.WriteMessage(keymap); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := c.DispatchOne(ctx); err != nil {
+		t.Fatal(err)
+	}
+	ev := <-keymaps
+	os.NewFile(ev.Fd, "keymap").Close()
+	if err := rec.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	rr, err := NewRecordReader(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sync, err := rr.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !sync.Sent || sync.Object != 1 || sync.Opcode != 0 || ProxyId(order.Uint32(sync.Data)) != callback.Id() {
+		t.Errorf("unexpected request %+v", sync)
+	}
+	event, err := rr.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if event.Sent || event.Object != keyboard.Id() || len(event.Data) != 8 || event.Time < sync.Time {
+		t.Errorf("unexpected event %+v", event)
+	}
+	if len(event.Files) != 1 || string(event.Files[0]) != "keymap" {
+		t.Errorf("fd contents %q", event.Files)
+	}
+	if _, err := rr.Next(); err != io.EOF {
+		t.Errorf("got %v at the end of the recording", err)
+	}
+
+	if _, err := NewRecordReader(bytes.NewReader([]byte("wayland"))); err == nil {
+		t.Error("read a recording without the magic")
+	}
+}
@@ -0,0 +1,12 @@
+package wl
+
+// BindGlobal binds the global announced by ev to proxy, at the highest
+// version both the server and the generated code of proxy support.  It
+// returns the version bound.
+func (p *Registry) BindGlobal(ev RegistryGlobalEvent, proxy Proxy) (uint32, error) {
+	version := ev.Version
+	if iface := interfaceOf(proxy); iface != nil && iface.Version < version {
+		version = iface.Version
+	}
+	return version, p.Bind(ev.Name, ev.Interface, version, proxy)
+}
@@ -0,0 +1,64 @@
+package wl_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/dkolbly/wl"
+	"github.com/dkolbly/wl/server"
+)
+
+func TestBindGlobal(t *testing.T) {
+	bound := make(chan *server.Resource, 1)
+	display, ctx := connect(t, func(srv *server.Display) {
+		srv.AddGlobal("wl_compositor", 3, func(r *server.Resource) {
+			r.SetHandler(server.HandlerFunc(func(r *server.Resource, req *wl.Event) {
+				// create_surface
+				r.Client().NewResource(wl.ProxyId(req.Uint32()), "wl_surface", r.Version, nil)
+			}))
+			bound <- r
+		})
+		srv.AddGlobal("wl_output", 99, nil)
+	})
+
+	registry, err := display.GetRegistry()
+	if err != nil {
+		t.Fatal(err)
+	}
+	seen := make(globals)
+	registry.AddGlobalHandler(seen)
+	if err := display.Roundtrip(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// the server is older than the client
+	compositor := wl.NewCompositor(display.Context())
+	if v, err := registry.BindGlobal(seen["wl_compositor"], compositor); err != nil || v != 3 {
+		t.Fatalf("bound version %d: %v", v, err)
+	}
+	if err := display.Roundtrip(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if r := <-bound; r.Version != 3 || compositor.Version() != 3 {
+		t.Errorf("bound at %d, proxy has version %d", r.Version, compositor.Version())
+	}
+	surface, err := compositor.CreateSurface()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if surface.Version() != 3 {
+		t.Errorf("surface has version %d", surface.Version())
+	}
+	if err := surface.DamageBuffer(0, 0, 1, 1); !errors.Is(err, wl.ErrUnsupportedVersion) {
+		t.Errorf("request newer than the object sent: %v", err)
+	}
+
+	// the client is older than the server
+	output := wl.NewOutput(display.Context())
+	if v, err := registry.BindGlobal(seen["wl_output"], output); err != nil || v != output.Interface().Version {
+		t.Fatalf("bound version %d: %v", v, err)
+	}
+	if err := display.Roundtrip(ctx); err != nil {
+		t.Fatal(err)
+	}
+}
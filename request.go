@@ -1,44 +1,85 @@
 package wl
 
-import (
-	"errors"
-	"net"
-	"syscall"
-)
-
 type Request struct {
 	pid    ProxyId
 	opcode uint32
 	data   []byte
-	oob    []byte
+	fds    []int
 }
 
+// SendRequest queues a request.  It is written by the next Flush, at
+// the latest when the Context waits for events, or right away for
+// wl_display.sync, which is always followed by waiting for the reply.
+// Once the Context failed, SendRequest returns the error that stopped
+// it, such as a *ProtocolError.  Requests newer than the version of
+// proxy fail with ErrUnsupportedVersion.
 func (context *Context) SendRequest(proxy Proxy, opcode uint32, args ...interface{}) (err error) {
-	req := Request{
-		pid:    proxy.Id(),
+	if err := context.failure(); err != nil {
+		return err
+	}
+	if err := CheckVersion(proxy, opcode); err != nil {
+		return err
+	}
+	r := NewRequest(proxy.Id(), opcode, args...)
+	if rec := context.getRecorder(); rec != nil {
+		rec.record(true, r.pid, opcode, r.data, r.fds)
+	}
+	context.countRequest(proxy, r)
+	if t := context.getTracer(); t != nil {
+		context.trace(t, true, r.pid, interfaceOf(proxy), opcode, r.data, r.fds)
+	}
+	err = context.conn.QueueMessage(r)
+	bytePool.Give(r.data)
+	if err != nil {
+		return err
+	}
+	if _, ok := proxy.(*Display); ok && opcode == 0 {
+		return context.Flush()
+	}
+	return nil
+}
+
+// Flush writes the queued requests to the server.
+func (context *Context) Flush() error {
+	return context.conn.Flush()
+}
+
+// NewRequest encodes a message with the given arguments for the object
+// with the given id.
+func NewRequest(id ProxyId, opcode uint32, args ...interface{}) *Request {
+	req := &Request{
+		pid:    id,
 		opcode: opcode,
 	}
 
 	for _, arg := range args {
 		req.Write(arg)
 	}
-
-	return writeRequest(context.conn, req)
+	return req
 }
 
 func (r *Request) Write(arg interface{}) {
 	switch t := arg.(type) {
+	case nil:
+		// the null object or string
+		r.PutUint32(0)
 	case Proxy:
 		r.PutProxy(t)
+	case ProxyId:
+		r.PutUint32(uint32(t))
 	case uint32:
 		r.PutUint32(t)
 	case int32:
 		r.PutInt32(t)
+	case Fixed:
+		r.PutUint32(uint32(t))
 	case float32:
 		r.PutFloat32(t)
 	case string:
 		r.PutString(t)
-	case []int32:
+	case Array:
+		r.PutArray(t)
+	case []byte:
 		r.PutArray(t)
 	case uintptr:
 		r.PutFd(t)
@@ -66,47 +107,42 @@ func (r *Request) PutFloat32(f float32) {
 	r.PutUint32(uint32(fx))
 }
 
-func (r *Request) PutString(s string) {
-	tail := 4 - (len(s) & 0x3)
-	r.PutUint32(uint32(len(s) + tail))
-	r.data = append(r.data, []byte(s)...)
-	// if padding required
-	if tail > 0 {
-		padding := make([]byte, tail)
-		r.data = append(r.data, padding...)
+// NullableProxy returns p as an argument for an object that may be
+// null: nil pointers become the null object.
+func NullableProxy[T any, P interface {
+	*T
+	Proxy
+}](p P) interface{} {
+	if p == nil {
+		return nil
 	}
+	return p
 }
 
-func (r *Request) PutArray(a []int32) {
-	r.PutUint32(uint32(len(a)))
-	for _, e := range a {
-		r.PutUint32(uint32(e))
+// NullableString returns s as an argument for a string that may be
+// null: a nil pointer becomes the null string.
+func NullableString(s *string) interface{} {
+	if s == nil {
+		return nil
 	}
+	return *s
 }
 
-func (r *Request) PutFd(fd uintptr) {
-	rights := syscall.UnixRights(int(fd))
-	r.oob = append(r.oob, rights...)
+// PutString writes s with its terminating NUL, padded to 32 bits.
+func (r *Request) PutString(s string) {
+	r.PutUint32(uint32(len(s) + 1))
+	r.data = append(r.data, s...)
+	r.data = append(r.data, make([]byte, 1+padding(len(s)+1))...)
 }
 
-func writeRequest(conn *net.UnixConn, r Request) error {
-	var header []byte
-	// calculate message total size
-	size := uint32(len(r.data) + 8)
-	buf := make([]byte, 4)
-	order.PutUint32(buf, uint32(r.pid))
-	header = append(header, buf...)
-	order.PutUint32(buf, uint32(size<<16|r.opcode&0x0000ffff))
-	header = append(header, buf...)
-
-	d, c, err := conn.WriteMsgUnix(append(header, r.data...), r.oob, nil)
-	if err != nil {
-		return err
-	}
-	if c != len(r.oob) || d != (len(header)+len(r.data)) {
-		return errors.New("WriteMsgUnix failed")
-	}
-	bytePool.Give(r.data)
+// PutArray writes the length of a in bytes and its contents, padded
+// to 32 bits.
+func (r *Request) PutArray(a []byte) {
+	r.PutUint32(uint32(len(a)))
+	r.data = append(r.data, a...)
+	r.data = append(r.data, make([]byte, padding(len(a)))...)
+}
 
-	return nil
+func (r *Request) PutFd(fd uintptr) {
+	r.fds = append(r.fds, int(fd))
 }
@@ -0,0 +1,52 @@
+package wl
+
+// ringSize is the capacity of a ring, and so the size of the largest
+// message that can be read.  It must be a power of two.
+const ringSize = 1 << 14
+
+// ring is a circular buffer for the bytes read from a connection.  The
+// positions are free running, so head-tail is the number of buffered
+// bytes even after they wrapped around.
+type ring struct {
+	data [ringSize]byte
+	head uint32 // where the next byte is stored
+	tail uint32 // where the next byte is read from
+}
+
+// len returns the number of buffered bytes.
+func (r *ring) len() int {
+	return int(r.head - r.tail)
+}
+
+// free returns the longest contiguous region that may be filled, to be
+// committed with fill.  It is empty if the ring is full.
+func (r *ring) free() []byte {
+	if r.len() == ringSize {
+		return nil
+	}
+	h, t := r.head%ringSize, r.tail%ringSize
+	if t > h {
+		return r.data[h:t]
+	}
+	return r.data[h:]
+}
+
+// fill marks n bytes of the region returned by free as buffered.
+func (r *ring) fill(n int) {
+	r.head += uint32(n)
+}
+
+// peek copies the first len(p) buffered bytes into p without consuming
+// them.  The caller makes sure that enough bytes are buffered.
+func (r *ring) peek(p []byte) {
+	t := r.tail % ringSize
+	n := copy(p, r.data[t:])
+	copy(p[n:], r.data[:])
+}
+
+// read copies the first len(p) buffered bytes into p and consumes
+// them.
+func (r *ring) read(p []byte) {
+	r.peek(p)
+	r.tail += uint32(len(p))
+}
@@ -0,0 +1,16 @@
+package wl
+
+import (
+	"context"
+)
+
+// Roundtrip blocks until the server has processed all requests sent
+// before it, dispatching events of the default queue on the calling
+// goroutine in the meantime.  The handlers of the events the server
+// sent in reply to those requests have run when it returns.  It
+// returns early with ctx.Err() when ctx is done, and with the error
+// that stopped the Context, such as a *ProtocolError, if the server
+// reports an error or the connection breaks.
+func (d *Display) Roundtrip(ctx context.Context) error {
+	return d.Context().queue.Roundtrip(ctx)
+}
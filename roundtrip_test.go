@@ -0,0 +1,44 @@
+package wl
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestRoundtripCanceled(t *testing.T) {
+	a, server := socketPair(t)
+	defer server.Close()
+	display := NewContextFromConn(a.UnixConn())
+	defer display.Context().Close()
+
+	// the server never answers the sync
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := display.Roundtrip(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("Roundtrip returned %v", err)
+	}
+	if err := display.Context().Err(); err != nil {
+		t.Errorf("cancelling stopped the Context: %v", err)
+	}
+}
+
+func TestRoundtripError(t *testing.T) {
+	a, server := socketPair(t)
+	display := NewContextFromConn(a.UnixConn())
+	defer display.Context().Close()
+
+	go func() {
+		server.ReadMessage()
+		server.Close()
+	}()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	err := display.Roundtrip(ctx)
+	if err == nil || err == ctx.Err() {
+		t.Fatalf("Roundtrip returned %v after the server hung up", err)
+	}
+	if err != display.Context().Err() {
+		t.Errorf("Roundtrip returned %v, the Context stopped with %v", err, display.Context().Err())
+	}
+}
@@ -0,0 +1,176 @@
+package server
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/dkolbly/wl"
+)
+
+// Client is a connection accepted by a Display, together with the
+// objects the client created.
+type Client struct {
+	display         *Display
+	conn            *wl.Conn
+	mu              sync.Mutex
+	objects         map[wl.ProxyId]*Resource
+	nextId          wl.ProxyId
+	closed          bool
+	displayResource *Resource
+}
+
+func newClient(d *Display, conn *wl.Conn) *Client {
+	c := &Client{
+		display: d,
+		conn:    conn,
+		objects: make(map[wl.ProxyId]*Resource),
+		nextId:  wl.ServerIdStart,
+	}
+	c.displayResource, _ = c.NewResource(1, "wl_display", 1, HandlerFunc(handleDisplayRequest))
+	return c
+}
+
+func (c *Client) Display() *Display {
+	return c.display
+}
+
+// Conn returns the connection to the client.
+func (c *Client) Conn() *wl.Conn {
+	return c.conn
+}
+
+// NewResource adds an object the client created, through a new_id
+// argument of a request, to the client's object table.  Ids that are
+// zero, in the server range or already in use are a protocol error.
+func (c *Client) NewResource(id wl.ProxyId, iface string, version uint32, h RequestHandler) (*Resource, error) {
+	c.mu.Lock()
+	_, used := c.objects[id]
+	if id == 0 || id >= wl.ServerIdStart || used {
+		c.mu.Unlock()
+		err := fmt.Errorf("invalid new id %d", id)
+		c.postError(c.displayResource, wl.DisplayErrorInvalidObject, err.Error())
+		return nil, err
+	}
+	r := c.addResource(id, iface, version, h)
+	c.mu.Unlock()
+	return r, nil
+}
+
+// NewServerResource adds an object the server creates, to be sent in a
+// new_id argument of an event, with an id allocated from the server
+// range.
+func (c *Client) NewServerResource(iface string, version uint32, h RequestHandler) *Resource {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	id := c.nextId
+	c.nextId++
+	return c.addResource(id, iface, version, h)
+}
+
+// addResource adds a resource to the object table, with c.mu held.
+func (c *Client) addResource(id wl.ProxyId, iface string, version uint32, h RequestHandler) *Resource {
+	r := &Resource{
+		client:    c,
+		id:        id,
+		Interface: iface,
+		Version:   version,
+		handler:   h,
+	}
+	c.objects[id] = r
+	return r
+}
+
+// Resource returns the object with the given id, or nil.
+func (c *Client) Resource(id wl.ProxyId) *Resource {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.objects[id]
+}
+
+// Close disconnects the client and destroys all its resources.
+func (c *Client) Close() {
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return
+	}
+	c.closed = true
+	objects := c.objects
+	c.objects = make(map[wl.ProxyId]*Resource)
+	c.mu.Unlock()
+
+	c.conn.Close()
+	for _, r := range objects {
+		r.destroyed()
+	}
+	c.display.removeClient(c)
+}
+
+func (c *Client) read() {
+	for {
+		req, err := c.conn.ReadMessage()
+		select {
+		case c.display.msgs <- message{c, req, err}:
+		case <-c.display.done:
+			return
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+func (c *Client) dispatch(req *wl.Event) {
+	defer req.CloseFds()
+	r := c.Resource(req.Id())
+	if r == nil {
+		c.postError(c.displayResource, wl.DisplayErrorInvalidObject,
+			fmt.Sprintf("invalid object %d", req.Id()))
+		return
+	}
+	if r.handler == nil {
+		c.postError(r, wl.DisplayErrorInvalidMethod,
+			fmt.Sprintf("invalid method %d of %s@%d", req.Opcode, r.Interface, r.id))
+		return
+	}
+	if err := c.conn.ClaimFds(req, requestFds(r, req.Opcode)); err != nil {
+		c.postError(c.displayResource, wl.DisplayErrorInvalidMethod, err.Error())
+		return
+	}
+
+	defer func() {
+		// the wire decoders panic on truncated messages
+		if e := recover(); e != nil {
+			c.postError(r, wl.DisplayErrorInvalidMethod,
+				fmt.Sprintf("malformed request %d of %s@%d: %v", req.Opcode, r.Interface, r.id, e))
+		}
+	}()
+	r.handler.HandleRequest(r, req)
+}
+
+// requestFds returns the number of file descriptors carried by request
+// opcode of r, according to the registered interface description.
+func requestFds(r *Resource, opcode uint32) int {
+	iface := wl.LookupInterface(r.Interface)
+	if iface == nil || int(opcode) >= len(iface.Requests) {
+		return 0
+	}
+	return iface.Requests[opcode].NumFds()
+}
+
+func (c *Client) send(r *wl.Request) error {
+	c.mu.Lock()
+	closed := c.closed
+	c.mu.Unlock()
+	if closed {
+		return fmt.Errorf("client is disconnected")
+	}
+	return c.conn.WriteMessage(r)
+}
+
+// postError sends wl_display.error about object r and disconnects the
+// client, as a protocol error is fatal.
+func (c *Client) postError(r *Resource, code uint32, msg string) {
+	c.displayResource.PostEvent(displayError, r, code, msg)
+	c.Close()
+}
@@ -0,0 +1,53 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/dkolbly/wl"
+)
+
+// testClient returns a client of a new Display, and the other end of
+// its connection.
+func testClient(t *testing.T) (*Client, *wl.Conn) {
+	a, b, err := wl.SocketPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn := wl.NewConn(b)
+	t.Cleanup(func() { conn.Close() })
+	return newClient(NewDisplay(), wl.NewConn(a)), conn
+}
+
+func TestNewResource(t *testing.T) {
+	for _, id := range []wl.ProxyId{0, 1, wl.ServerIdStart, 0xffffffff} {
+		c, peer := testClient(t)
+		if _, err := c.NewResource(id, "wl_callback", 1, nil); err == nil {
+			t.Errorf("accepted new id %d", id)
+			continue
+		}
+		ev, err := peer.ReadMessage()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ev.Id() != 1 || ev.Opcode != 0 {
+			t.Fatalf("got event %d of object %d instead of wl_display.error", ev.Opcode, ev.Id())
+		}
+		ev.Uint32() // the object
+		if code := ev.Uint32(); code != wl.DisplayErrorInvalidObject {
+			t.Errorf("new id %d: error code %d", id, code)
+		}
+	}
+
+	c, _ := testClient(t)
+	r, err := c.NewResource(2, "wl_callback", 1, nil)
+	if err != nil || r.Id() != 2 || c.Resource(2) != r {
+		t.Fatalf("client id not registered: %v", err)
+	}
+	s := c.NewServerResource("wl_data_offer", 3, nil)
+	if s.Id() != wl.ServerIdStart || c.Resource(wl.ServerIdStart) != s {
+		t.Errorf("server resource got id %d", s.Id())
+	}
+	if s = c.NewServerResource("wl_data_offer", 3, nil); s.Id() != wl.ServerIdStart+1 {
+		t.Errorf("second server resource got id %d", s.Id())
+	}
+}
@@ -0,0 +1,274 @@
+// Package server implements the compositor side of the Wayland
+// protocol.
+//
+// A Display listens on one or more sockets, accepts clients and keeps
+// a table of the objects (resources) every client created.  Requests
+// are decoded with the same wire code the client side uses for
+// events, and all request handlers run on the goroutine that called
+// Display.Run, so compositor state needs no locking.
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"syscall"
+
+	"github.com/dkolbly/wl"
+)
+
+type Display struct {
+	mu         sync.Mutex
+	sockets    []*socket
+	clients    map[*Client]struct{}
+	globals    []*Global
+	nextGlobal uint32
+	registries map[*Resource]struct{}
+	serial     uint32
+	msgs       chan message
+	done       chan struct{}
+	closeOnce  sync.Once
+}
+
+// message is a request read by a client goroutine, or the error that
+// ended its connection.
+type message struct {
+	client *Client
+	req    *wl.Event
+	err    error
+}
+
+type socket struct {
+	listener *net.UnixListener
+	lock     *os.File
+	path     string
+}
+
+func NewDisplay() *Display {
+	return &Display{
+		clients:    make(map[*Client]struct{}),
+		registries: make(map[*Resource]struct{}),
+		msgs:       make(chan message),
+		done:       make(chan struct{}),
+	}
+}
+
+// NextSerial returns a new serial number for events that need one.
+func (d *Display) NextSerial() uint32 {
+	return atomic.AddUint32(&d.serial, 1)
+}
+
+// AddSocket listens on $XDG_RUNTIME_DIR/name.  An empty name means
+// $WAYLAND_DISPLAY, or wayland-0 if that is not set.
+func (d *Display) AddSocket(name string) error {
+	if name == "" {
+		name = os.Getenv("WAYLAND_DISPLAY")
+	}
+	if name == "" {
+		name = "wayland-0"
+	}
+	runtimeDir := os.Getenv("XDG_RUNTIME_DIR")
+	if runtimeDir == "" {
+		return errors.New("XDG_RUNTIME_DIR not set in the environment.")
+	}
+	s, err := listen(filepath.Join(runtimeDir, name))
+	if err != nil {
+		return err
+	}
+	d.mu.Lock()
+	d.sockets = append(d.sockets, s)
+	d.mu.Unlock()
+	go d.accept(s)
+	return nil
+}
+
+// AddSocketAuto listens on the first free socket out of wayland-1 to
+// wayland-32 and returns its name.
+func (d *Display) AddSocketAuto() (string, error) {
+	var err error
+	for i := 1; i <= 32; i++ {
+		name := fmt.Sprintf("wayland-%d", i)
+		if err = d.AddSocket(name); err == nil {
+			return name, nil
+		}
+	}
+	return "", fmt.Errorf("no free wayland socket: %s", err)
+}
+
+// listen creates the socket at path, guarded by a lock file like
+// libwayland's so that a stale socket left by a dead compositor can be
+// replaced safely.
+func listen(path string) (*socket, error) {
+	lock, err := os.OpenFile(path+".lock", os.O_CREATE|os.O_RDWR, 0660)
+	if err != nil {
+		return nil, err
+	}
+	if err := syscall.Flock(int(lock.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		lock.Close()
+		return nil, fmt.Errorf("%s is in use", path)
+	}
+	os.Remove(path)
+	l, err := net.ListenUnix("unix", &net.UnixAddr{Name: path, Net: "unix"})
+	if err != nil {
+		lock.Close()
+		return nil, err
+	}
+	l.SetUnlinkOnClose(true)
+	return &socket{listener: l, lock: lock, path: path}, nil
+}
+
+func (d *Display) accept(s *socket) {
+	for {
+		conn, err := s.listener.AcceptUnix()
+		if err != nil {
+			select {
+			case <-d.done:
+			default:
+				log.Printf("accept on %s: %s", s.path, err)
+			}
+			return
+		}
+		d.AddClient(conn)
+	}
+}
+
+// AddClient starts serving a client connected over conn, which may
+// also be one end of a socketpair created by the compositor.
+func (d *Display) AddClient(conn *net.UnixConn) *Client {
+	c := newClient(d, wl.NewConn(conn))
+	d.mu.Lock()
+	d.clients[c] = struct{}{}
+	d.mu.Unlock()
+	go c.read()
+	return c
+}
+
+// Run dispatches client requests until ctx is cancelled, then closes
+// the display.
+func (d *Display) Run(ctx context.Context) error {
+	defer d.Close()
+	for {
+		select {
+		case m := <-d.msgs:
+			if m.err != nil {
+				m.client.Close()
+				continue
+			}
+			m.client.dispatch(m.req)
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// Close stops listening, disconnects all clients and removes the
+// sockets.
+func (d *Display) Close() {
+	d.closeOnce.Do(func() {
+		close(d.done)
+		d.mu.Lock()
+		sockets := d.sockets
+		d.sockets = nil
+		clients := make([]*Client, 0, len(d.clients))
+		for c := range d.clients {
+			clients = append(clients, c)
+		}
+		d.mu.Unlock()
+
+		for _, s := range sockets {
+			s.listener.Close()
+			os.Remove(s.lock.Name())
+			s.lock.Close()
+		}
+		for _, c := range clients {
+			c.Close()
+		}
+	})
+}
+
+func (d *Display) removeClient(c *Client) {
+	d.mu.Lock()
+	delete(d.clients, c)
+	d.mu.Unlock()
+}
+
+// Global is an object advertised to clients through wl_registry.
+type Global struct {
+	display   *Display
+	name      uint32
+	Interface string
+	Version   uint32
+	bind      func(*Resource)
+}
+
+// AddGlobal advertises an interface to all current and future
+// clients.  When a client binds it, bind is called with the new
+// resource, at the version the client asked for, so that it can
+// install a request handler.
+func (d *Display) AddGlobal(iface string, version uint32, bind func(*Resource)) *Global {
+	d.mu.Lock()
+	d.nextGlobal++
+	g := &Global{
+		display:   d,
+		name:      d.nextGlobal,
+		Interface: iface,
+		Version:   version,
+		bind:      bind,
+	}
+	d.globals = append(d.globals, g)
+	registries := d.registryList()
+	d.mu.Unlock()
+
+	for _, r := range registries {
+		r.PostEvent(registryGlobal, g.name, g.Interface, g.Version)
+	}
+	return g
+}
+
+// RemoveGlobal stops advertising g.
+func (d *Display) RemoveGlobal(g *Global) {
+	d.mu.Lock()
+	for i, e := range d.globals {
+		if e == g {
+			d.globals = append(d.globals[:i], d.globals[i+1:]...)
+			break
+		}
+	}
+	registries := d.registryList()
+	d.mu.Unlock()
+
+	for _, r := range registries {
+		r.PostEvent(registryGlobalRemove, g.name)
+	}
+}
+
+// Name returns the numeric name the global is advertised under.
+func (g *Global) Name() uint32 {
+	return g.name
+}
+
+func (d *Display) lookupGlobal(name uint32) *Global {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, g := range d.globals {
+		if g.name == name {
+			return g
+		}
+	}
+	return nil
+}
+
+// registryList must be called with d.mu held.
+func (d *Display) registryList() []*Resource {
+	ret := make([]*Resource, 0, len(d.registries))
+	for r := range d.registries {
+		ret = append(ret, r)
+	}
+	return ret
+}
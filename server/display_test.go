@@ -0,0 +1,71 @@
+package server
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/dkolbly/wl"
+)
+
+type globals map[string]wl.RegistryGlobalEvent
+
+func (g globals) HandleRegistryGlobal(ev wl.RegistryGlobalEvent) {
+	g[ev.Interface] = ev
+}
+
+func TestRegistry(t *testing.T) {
+	t.Setenv("XDG_RUNTIME_DIR", t.TempDir())
+
+	srv := NewDisplay()
+	name, err := srv.AddSocketAuto()
+	if err != nil {
+		t.Fatal(err)
+	}
+	bound := make(chan *Resource, 1)
+	srv.AddGlobal("wl_compositor", 4, func(r *Resource) {
+		bound <- r
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go srv.Run(ctx)
+
+	display, err := wl.Connect(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer display.Context().Close()
+
+	registry, err := display.GetRegistry()
+	if err != nil {
+		t.Fatal(err)
+	}
+	seen := make(globals)
+	registry.AddGlobalHandler(seen)
+	rctx, rcancel := context.WithTimeout(ctx, 5*time.Second)
+	defer rcancel()
+	if err := display.Roundtrip(rctx); err != nil {
+		t.Fatal(err)
+	}
+
+	g, ok := seen["wl_compositor"]
+	if !ok || g.Version != 4 {
+		t.Fatalf("wl_compositor not advertised: %v", seen)
+	}
+
+	compositor := wl.NewCompositor(display.Context())
+	if err := registry.Bind(g.Name, g.Interface, 3, compositor); err != nil {
+		t.Fatal(err)
+	}
+	if err := display.Context().Flush(); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case r := <-bound:
+		if r.Id() != compositor.Id() || r.Version != 3 || r.Interface != "wl_compositor" {
+			t.Errorf("unexpected resource %s@%d version %d", r.Interface, r.Id(), r.Version)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout waiting for bind")
+	}
+}
@@ -0,0 +1,171 @@
+package server
+
+import (
+	"fmt"
+
+	"github.com/dkolbly/wl"
+)
+
+// Opcodes of the core events sent by the server itself.
+const (
+	displayError         = 0
+	displayDeleteId      = 1
+	registryGlobal       = 0
+	registryGlobalRemove = 1
+	callbackDone         = 0
+)
+
+// RequestHandler decodes and handles the requests sent to a resource.
+// The request arguments are read in order with the methods of
+// wl.Event, exactly like a client decodes events.
+type RequestHandler interface {
+	HandleRequest(r *Resource, req *wl.Event)
+}
+
+type HandlerFunc func(r *Resource, req *wl.Event)
+
+func (f HandlerFunc) HandleRequest(r *Resource, req *wl.Event) {
+	f(r, req)
+}
+
+// Resource is the server side of a protocol object owned by a client.
+type Resource struct {
+	client          *Client
+	id              wl.ProxyId
+	Interface       string
+	Version         uint32
+	handler         RequestHandler
+	destroyHandlers []func(*Resource)
+}
+
+func (r *Resource) Id() wl.ProxyId {
+	return r.id
+}
+
+func (r *Resource) Client() *Client {
+	return r.client
+}
+
+func (r *Resource) SetHandler(h RequestHandler) {
+	r.handler = h
+}
+
+// AddDestroyHandler registers f to be called when the resource is
+// destroyed or its client disconnects.
+func (r *Resource) AddDestroyHandler(f func(*Resource)) {
+	r.destroyHandlers = append(r.destroyHandlers, f)
+}
+
+// PostEvent sends an event from this object.  Arguments are encoded
+// like request arguments on the client side; resources are sent as
+// their ids and a nil *Resource as a null object.
+func (r *Resource) PostEvent(opcode uint32, args ...interface{}) error {
+	wire := make([]interface{}, len(args))
+	for i, arg := range args {
+		wire[i] = arg
+		if res, ok := arg.(*Resource); ok {
+			if res == nil {
+				wire[i] = wl.ProxyId(0)
+			} else {
+				wire[i] = res.id
+			}
+		}
+	}
+	return r.client.send(wl.NewRequest(r.id, opcode, wire...))
+}
+
+// PostError sends a protocol error about this object and disconnects
+// the client.
+func (r *Resource) PostError(code uint32, format string, args ...interface{}) {
+	r.client.postError(r, code, fmt.Sprintf(format, args...))
+}
+
+// Destroy removes the resource from its client.  For ids allocated by
+// the client, wl_display.delete_id tells the client that it may reuse
+// the id.
+func (r *Resource) Destroy() {
+	c := r.client
+	c.mu.Lock()
+	if c.objects[r.id] != r {
+		c.mu.Unlock()
+		return
+	}
+	delete(c.objects, r.id)
+	c.mu.Unlock()
+
+	if r.id < wl.ServerIdStart {
+		c.displayResource.PostEvent(displayDeleteId, uint32(r.id))
+	}
+	r.destroyed()
+}
+
+func (r *Resource) destroyed() {
+	for _, f := range r.destroyHandlers {
+		f(r)
+	}
+}
+
+// handleDisplayRequest implements wl_display.
+func handleDisplayRequest(r *Resource, req *wl.Event) {
+	c := r.client
+	switch req.Opcode {
+	case 0: // sync
+		cb, err := c.NewResource(wl.ProxyId(req.Uint32()), "wl_callback", 1, nil)
+		if err != nil {
+			return
+		}
+		cb.PostEvent(callbackDone, c.display.NextSerial())
+		cb.Destroy()
+	case 1: // get_registry
+		reg, err := c.NewResource(wl.ProxyId(req.Uint32()), "wl_registry", 1, HandlerFunc(handleRegistryRequest))
+		if err != nil {
+			return
+		}
+		d := c.display
+		d.mu.Lock()
+		d.registries[reg] = struct{}{}
+		globals := append([]*Global(nil), d.globals...)
+		d.mu.Unlock()
+		reg.AddDestroyHandler(func(reg *Resource) {
+			d.mu.Lock()
+			delete(d.registries, reg)
+			d.mu.Unlock()
+		})
+		for _, g := range globals {
+			reg.PostEvent(registryGlobal, g.name, g.Interface, g.Version)
+		}
+	default:
+		r.PostError(wl.DisplayErrorInvalidMethod, "invalid method %d", req.Opcode)
+	}
+}
+
+// handleRegistryRequest implements wl_registry.
+func handleRegistryRequest(r *Resource, req *wl.Event) {
+	if req.Opcode != 0 {
+		r.PostError(wl.DisplayErrorInvalidMethod, "invalid method %d", req.Opcode)
+		return
+	}
+	// bind
+	name := req.Uint32()
+	iface := req.String()
+	version := req.Uint32()
+	id := wl.ProxyId(req.Uint32())
+
+	g := r.client.display.lookupGlobal(name)
+	if g == nil || g.Interface != iface {
+		r.PostError(wl.DisplayErrorInvalidObject, "invalid global %s (%d)", iface, name)
+		return
+	}
+	if version == 0 || version > g.Version {
+		r.PostError(wl.DisplayErrorInvalidObject, "invalid version for global %s (%d): have %d, wanted %d",
+			iface, name, g.Version, version)
+		return
+	}
+	res, err := r.client.NewResource(id, iface, version, nil)
+	if err != nil {
+		return
+	}
+	if g.bind != nil {
+		g.bind(res)
+	}
+}
@@ -0,0 +1,51 @@
+package wl_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/dkolbly/wl"
+	"github.com/dkolbly/wl/server"
+)
+
+// The tests in package wl_test run the client against the compositor
+// of package server, which imports wl.
+
+type globals map[string]wl.RegistryGlobalEvent
+
+func (g globals) HandleRegistryGlobal(ev wl.RegistryGlobalEvent) {
+	g[ev.Interface] = ev
+}
+
+// connect starts a server, with the globals added by setup, in a
+// runtime directory of its own, and connects a client to it.  Both are
+// stopped when the test ends, or after the returned context expires.
+func connect(t *testing.T, setup func(*server.Display)) (*wl.Display, context.Context) {
+	t.Setenv("XDG_RUNTIME_DIR", t.TempDir())
+	srv := server.NewDisplay()
+	if setup != nil {
+		setup(srv)
+	}
+	name, err := srv.AddSocketAuto()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	stopped := make(chan struct{})
+	go func() {
+		srv.Run(ctx)
+		close(stopped)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-stopped
+	})
+
+	display, err := wl.Connect(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(display.Context().Close)
+	return display, ctx
+}
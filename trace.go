@@ -0,0 +1,195 @@
+package wl
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Tracer receives every request sent and every event received by a
+// Context, for debugging.  Trace is called on the goroutine sending the
+// request or reading the event, and must not block.
+type Tracer interface {
+	Trace(m TraceMessage)
+}
+
+// TraceMessage describes a request or event, decoded using the
+// protocol description of its interface.
+type TraceMessage struct {
+	Time      time.Time
+	Sent      bool // a request sent, rather than an event received
+	Object    ProxyId
+	Interface string // empty if the object is unknown
+	Message   string // the opcode, if the message is not described
+	Args      []string
+}
+
+// String formats m the way libwayland does for WAYLAND_DEBUG.
+func (m TraceMessage) String() string {
+	us := m.Time.UnixMicro()
+	dir := ""
+	if m.Sent {
+		dir = " -> "
+	}
+	iface := m.Interface
+	if iface == "" {
+		iface = "[unknown]"
+	}
+	return fmt.Sprintf("[%7d.%03d] %s%s@%d.%s(%s)", us/1000%1000000, us%1000,
+		dir, iface, m.Object, m.Message, strings.Join(m.Args, ", "))
+}
+
+type textTracer struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// NewTextTracer returns a Tracer writing one line per message to w, in
+// the format of WAYLAND_DEBUG.
+func NewTextTracer(w io.Writer) Tracer {
+	return &textTracer{w: w}
+}
+
+func (t *textTracer) Trace(m TraceMessage) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	fmt.Fprintln(t.w, m.String())
+}
+
+// debugFromEnv reports whether WAYLAND_DEBUG asks for tracing the
+// client side, as libwayland understands it.
+func debugFromEnv() bool {
+	for _, s := range strings.Split(os.Getenv("WAYLAND_DEBUG"), ",") {
+		if s == "1" || s == "client" {
+			return true
+		}
+	}
+	return false
+}
+
+// SetTracer makes the Context report every message to t.  A nil t
+// turns tracing off.  Connect sets a tracer writing to stderr if the
+// WAYLAND_DEBUG environment variable is 1 or client.
+func (c *Context) SetTracer(t Tracer) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.tracer = t
+}
+
+func (c *Context) getTracer() Tracer {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.tracer
+}
+
+// trace reports a message about the object with the given id and
+// interface, whose arguments are encoded in data and fds.
+func (c *Context) trace(t Tracer, sent bool, id ProxyId, iface *Interface, opcode uint32, data []byte, fds []int) {
+	m := TraceMessage{
+		Time:    time.Now(),
+		Sent:    sent,
+		Object:  id,
+		Message: fmt.Sprint(opcode),
+	}
+	var msgs []Message
+	if iface != nil {
+		m.Interface = iface.Name
+		msgs = iface.Events
+		if sent {
+			msgs = iface.Requests
+		}
+	}
+	if int(opcode) < len(msgs) {
+		msg := &msgs[opcode]
+		m.Message = msg.Name
+		ev := &Event{data: data, fds: fds}
+		m.Args = ev.FormatArgs(msg, func(id ProxyId) *Interface {
+			return interfaceOf(c.lookupProxy(id))
+		})
+	}
+	t.Trace(m)
+}
+
+// FormatArgs formats the arguments of ev, described by msg, the way
+// libwayland does for WAYLAND_DEBUG.  Objects whose interface msg
+// leaves open are named by typeOf, which returns nil for unknown ids.
+func (ev *Event) FormatArgs(msg *Message, typeOf func(ProxyId) *Interface) []string {
+	var args []string
+	data, fds := ev.data, ev.fds
+	off := 0
+	uint32At := func() (uint32, bool) {
+		if off+4 > len(data) {
+			return 0, false
+		}
+		v := order.Uint32(data[off : off+4])
+		off += 4
+		return v, true
+	}
+	var last string // the last string, the interface of an untyped new_id
+	for _, arg := range msg.Arguments() {
+		t, typ := arg.Type, arg.Interface
+		if t == 'h' {
+			if len(fds) == 0 {
+				args = append(args, "fd ?")
+				continue
+			}
+			args = append(args, fmt.Sprintf("fd %d", fds[0]))
+			fds = fds[1:]
+			continue
+		}
+		v, ok := uint32At()
+		if !ok {
+			args = append(args, "<truncated>")
+			break
+		}
+		switch t {
+		case 'i':
+			args = append(args, fmt.Sprint(int32(v)))
+		case 'u':
+			args = append(args, fmt.Sprint(v))
+		case 'f':
+			args = append(args, fmt.Sprintf("%f", Fixed(v).Float64()))
+		case 's':
+			if v == 0 {
+				args = append(args, "nil")
+				continue
+			}
+			if off+int(v) > len(data) {
+				args = append(args, "<truncated>")
+				return args
+			}
+			last = string(bytes.TrimRight(data[off:off+int(v)], "\x00"))
+			args = append(args, fmt.Sprintf("%q", last))
+			off += int(v) + padding(int(v))
+		case 'a':
+			args = append(args, fmt.Sprintf("array[%d]", v))
+			off += int(v) + padding(int(v))
+		case 'o':
+			if v == 0 {
+				args = append(args, "nil")
+				continue
+			}
+			args = append(args, fmt.Sprintf("%s@%d", formatType(ProxyId(v), typ, typeOf), v))
+		case 'n':
+			if typ == "" {
+				// the interface was sent along, as in wl_registry.bind
+				typ = last
+			}
+			args = append(args, fmt.Sprintf("new id %s@%d", formatType(ProxyId(v), typ, typeOf), v))
+		}
+	}
+	return args
+}
+
+// formatType returns the interface name of the object with the given
+// id, preferring the one declared in the protocol.
+func formatType(id ProxyId, declared string, typeOf func(ProxyId) *Interface) string {
+	if declared != "" {
+		return declared
+	}
+	return ifaceName(typeOf(id))
+}
@@ -0,0 +1,62 @@
+package wl_test
+
+import (
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/dkolbly/wl"
+	"github.com/dkolbly/wl/server"
+)
+
+type traceRecorder struct {
+	lines []string
+	mu    sync.Mutex
+}
+
+func (r *traceRecorder) Trace(m wl.TraceMessage) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	// strip the timestamp
+	s := m.String()
+	r.lines = append(r.lines, s[strings.Index(s, "]")+2:])
+}
+
+func TestTrace(t *testing.T) {
+	display, ctx := connect(t, func(srv *server.Display) {
+		srv.AddGlobal("wl_compositor", 4, nil)
+	})
+	rec := new(traceRecorder)
+	display.Context().SetTracer(rec)
+
+	registry, err := display.GetRegistry()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := display.Roundtrip(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := registry.Bind(1, "wl_compositor", 1, wl.NewCompositor(display.Context())); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{
+		" -> wl_display@1.get_registry(new id wl_registry@2)",
+		" -> wl_display@1.sync(new id wl_callback@3)",
+		`wl_registry@2.global(1, "wl_compositor", 4)`,
+		"wl_callback@3.done(1)",
+		` -> wl_registry@2.bind(1, "wl_compositor", 1, new id wl_compositor@4)`,
+	}
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	// the order of requests and events depends on scheduling
+	got := strings.Join(rec.lines, "\n")
+	for _, line := range want {
+		if !strings.Contains(got, line) {
+			t.Errorf("trace lacks %s", line)
+		}
+	}
+	if t.Failed() {
+		t.Logf("got trace\n%s", got)
+	}
+}
@@ -2,7 +2,7 @@ package ui
 
 import (
 	"fmt"
-	//"github.com/dkolbly/wl"
+	"github.com/dkolbly/wl"
 	"github.com/dkolbly/wl/xdg"
 )
 
@@ -32,7 +32,7 @@ func (w *Window) HandleToplevelConfigure(ev xdg.ToplevelConfigureEvent) {
 		Width:  int(ev.Width),
 		Height: int(ev.Height),
 	}
-	for _, state := range ev.States {
+	for _, state := range wl.ArrayOf[xdg.ToplevelState](ev.States) {
 		if state == xdg.ToplevelStateActivated {
 			pend.Active = true
 		}
@@ -106,6 +106,10 @@ func (w *Window) setupXDGTopLevel() error {
 	if err != nil {
 		return fmt.Errorf("Surface.Commit failed: %s", err)
 	}
+	err = w.display.Context().Flush()
+	if err != nil {
+		return fmt.Errorf("Flush failed: %s", err)
+	}
 
 	fmt.Printf("ok...\n")
 	return nil
@@ -1,8 +1,8 @@
 package ui
 
 import (
+	"context"
 	"fmt"
-	"log"
 	"sync"
 	"syscall"
 )
@@ -30,16 +30,20 @@ type Display struct {
 }
 
 func Connect(addr string) (*Display, error) {
-	d := new(Display)
 	display, err := wl.Connect(addr)
 	if err != nil {
 		return nil, fmt.Errorf("Connect to Wayland server failed %s", err)
 	}
+	return NewDisplay(display)
+}
 
+// NewDisplay binds the globals and input devices of the server display
+// is connected to, which may be a wltest.Server.
+func NewDisplay(display *wl.Display) (*Display, error) {
+	d := new(Display)
 	d.display = display
-	display.AddErrorHandler(d)
 
-	err = d.registerGlobals()
+	err := d.registerGlobals()
 	if err != nil {
 		return nil, err
 	}
@@ -72,28 +76,26 @@ func (d *Display) Disconnect() {
 	d.display.Context().Close()
 }
 
-func (d *Display) Dispatch() chan<- struct{} {
-	return d.Context().Dispatch()
-}
-
-func (d *Display) Context() *wl.Context {
-	return d.display.Context()
+// Run dispatches events on the calling goroutine until ctx is done or
+// the connection breaks.
+func (d *Display) Run(ctx context.Context) error {
+	return d.Context().Run(ctx)
 }
 
-type registrar struct {
-	ch chan wl.RegistryGlobalEvent
+// Done returns a channel that is closed when the connection is closed
+// or breaks.
+func (d *Display) Done() <-chan struct{} {
+	return d.Context().Done()
 }
 
-func (r registrar) HandleRegistryGlobal(ev wl.RegistryGlobalEvent) {
-	r.ch <- ev
+// Err returns the reason the connection stopped, such as a
+// *wl.ProtocolError, once Done is closed.
+func (d *Display) Err() error {
+	return d.Context().Err()
 }
 
-type doner struct {
-	ch chan wl.CallbackDoneEvent
-}
-
-func (d doner) HandleCallbackDone(ev wl.CallbackDoneEvent) {
-	d.ch <- ev
+func (d *Display) Context() *wl.Context {
+	return d.display.Context()
 }
 
 func (d *Display) registerGlobals() error {
@@ -103,93 +105,58 @@ func (d *Display) registerGlobals() error {
 	}
 	d.registry = registry
 
-	callback, err := d.display.Sync()
+	// the globals are collected during the roundtrip, and bound
+	// after it returned
+	var globals []wl.RegistryGlobalEvent
+	cancel := registry.OnGlobal(func(ev wl.RegistryGlobalEvent) {
+		globals = append(globals, ev)
+	})
+	err = d.display.Roundtrip(context.Background())
+	cancel()
 	if err != nil {
-		return fmt.Errorf("Display.Sync failed %s", err)
+		return fmt.Errorf("Display.Roundtrip failed %s", err)
 	}
 
-	rgeChan := make(chan wl.RegistryGlobalEvent)
-	rgeHandler := registrar{rgeChan}
-	registry.AddGlobalHandler(rgeHandler)
-
-	cdeChan := make(chan wl.CallbackDoneEvent)
-	cdeHandler := doner{cdeChan}
-
-	callback.AddDoneHandler(cdeHandler)
-loop:
-	for {
-		select {
-		case ev := <-rgeChan:
-			if err := d.registerInterface(registry, ev); err != nil {
-				return err
-			}
-		case d.Dispatch() <- struct{}{}:
-		case <-cdeChan:
-			break loop
+	for _, ev := range globals {
+		if err := d.registerInterface(registry, ev); err != nil {
+			return err
 		}
 	}
-
-	registry.RemoveGlobalHandler(rgeHandler)
-	callback.RemoveDoneHandler(cdeHandler)
 	return nil
 }
 
-type seatcap struct {
-	ch chan wl.SeatCapabilitiesEvent
-}
-
-func (sce seatcap) HandleSeatCapabilities(ev wl.SeatCapabilitiesEvent) {
-	sce.ch <- ev
-}
-
 func (d *Display) registerInputs() error {
-	callback, err := d.display.Sync()
+	var caps wl.SeatCapability
+	cancel := d.seat.OnCapabilities(func(ev wl.SeatCapabilitiesEvent) {
+		caps = ev.Capabilities
+	})
+	err := d.display.Roundtrip(context.Background())
+	cancel()
 	if err != nil {
-		return fmt.Errorf("Display.Sync failed %s", err)
+		return fmt.Errorf("Display.Roundtrip failed %s", err)
 	}
 
-	cdeChan := make(chan wl.CallbackDoneEvent)
-	cdeHandler := doner{cdeChan}
-	callback.AddDoneHandler(cdeHandler)
-
-	sceChan := make(chan wl.SeatCapabilitiesEvent)
-	sceHandler := seatcap{sceChan}
-	d.seat.AddCapabilitiesHandler(sceHandler)
-
-loop:
-	for {
-		select {
-		case ev := <-sceChan:
-			if (ev.Capabilities & wl.SeatCapabilityPointer) != 0 {
-				pointer, err := d.seat.GetPointer()
-				if err != nil {
-					return fmt.Errorf("Unable to get Pointer object: %s", err)
-				}
-				d.pointer = pointer
-			}
-			if (ev.Capabilities & wl.SeatCapabilityKeyboard) != 0 {
-				keyboard, err := d.seat.GetKeyboard()
-				if err != nil {
-					return fmt.Errorf("Unable to get Keyboard object: %s", err)
-				}
-				d.keyboard = keyboard
-			}
-			if (ev.Capabilities & wl.SeatCapabilityTouch) != 0 {
-				touch, err := d.seat.GetTouch()
-				if err != nil {
-					return fmt.Errorf("Unable to get Touch object: %s", err)
-				}
-				d.touch = touch
-			}
-		case d.Dispatch() <- struct{}{}:
-		case <-cdeChan:
-			break loop
+	if (caps & wl.SeatCapabilityPointer) != 0 {
+		pointer, err := d.seat.GetPointer()
+		if err != nil {
+			return fmt.Errorf("Unable to get Pointer object: %s", err)
 		}
+		d.pointer = pointer
+	}
+	if (caps & wl.SeatCapabilityKeyboard) != 0 {
+		keyboard, err := d.seat.GetKeyboard()
+		if err != nil {
+			return fmt.Errorf("Unable to get Keyboard object: %s", err)
+		}
+		d.keyboard = keyboard
+	}
+	if (caps & wl.SeatCapabilityTouch) != 0 {
+		touch, err := d.seat.GetTouch()
+		if err != nil {
+			return fmt.Errorf("Unable to get Touch object: %s", err)
+		}
+		d.touch = touch
 	}
-
-	d.seat.RemoveCapabilitiesHandler(sceHandler)
-	callback.RemoveDoneHandler(cdeHandler)
-
 	return nil
 }
 
@@ -198,49 +165,49 @@ func (d *Display) registerInterface(registry *wl.Registry, ev wl.RegistryGlobalE
 	switch ev.Interface {
 	case "wl_shm":
 		ret := wl.NewShm(d.Context())
-		err := registry.Bind(ev.Name, ev.Interface, ev.Version, ret)
+		_, err := registry.BindGlobal(ev, ret)
 		if err != nil {
 			return fmt.Errorf("Unable to bind Shm interface: %s", err)
 		}
 		d.shm = ret
 	case "wl_compositor":
 		ret := wl.NewCompositor(d.Context())
-		err := registry.Bind(ev.Name, ev.Interface, ev.Version, ret)
+		_, err := registry.BindGlobal(ev, ret)
 		if err != nil {
 			return fmt.Errorf("Unable to bind Compositor interface: %s", err)
 		}
 		d.compositor = ret
 	case "wl_shell":
 		ret := wl.NewShell(d.Context())
-		err := registry.Bind(ev.Name, ev.Interface, ev.Version, ret)
+		_, err := registry.BindGlobal(ev, ret)
 		if err != nil {
 			return fmt.Errorf("Unable to bind Shell interface: %s", err)
 		}
 		d.shell = ret
 	case "wl_seat":
 		ret := wl.NewSeat(d.Context())
-		err := registry.Bind(ev.Name, ev.Interface, ev.Version, ret)
+		_, err := registry.BindGlobal(ev, ret)
 		if err != nil {
 			return fmt.Errorf("Unable to bind Seat interface: %s", err)
 		}
 		d.seat = ret
 	case "wl_data_device_manager":
 		ret := wl.NewDataDeviceManager(d.Context())
-		err := registry.Bind(ev.Name, ev.Interface, ev.Version, ret)
+		_, err := registry.BindGlobal(ev, ret)
 		if err != nil {
 			return fmt.Errorf("Unable to bind DataDeviceManager interface: %s", err)
 		}
 		d.dataDeviceManager = ret
 	case "wl_subcompositor":
 		ret := wl.NewSubcompositor(d.Context())
-		err := registry.Bind(ev.Name, ev.Interface, ev.Version, ret)
+		_, err := registry.BindGlobal(ev, ret)
 		if err != nil {
 			return fmt.Errorf("Unable to bind Subcompositor interface: %s", err)
 		}
 		d.subCompositor = ret
 	case "zxdg_shell_v6":
 		ret := xdg.NewWmBase(d.Context())
-		err := registry.Bind(ev.Name, ev.Interface, ev.Version, ret)
+		_, err := registry.BindGlobal(ev, ret)
 		if err != nil {
 			return fmt.Errorf("Unable to bind Subcompositor interface: %s", err)
 		}
@@ -250,10 +217,6 @@ func (d *Display) registerInterface(registry *wl.Registry, ev wl.RegistryGlobalE
 	return nil
 }
 
-func (d *Display) HandleDisplayError(ev wl.DisplayErrorEvent) {
-	log.Fatalf("Display Error Event: %d - %s - %d", ev.ObjectId.Id(), ev.Message, ev.Code)
-}
-
 func (d *Display) newBuffer(width, height, stride int32) (*wl.Buffer, []byte, error) {
 	size := stride * height
 
@@ -287,6 +250,10 @@ func (d *Display) registerWindow(w *Window) {
 	defer d.mu.Unlock()
 
 	d.windows = append(d.windows, w)
+	w.surface.SetUserData(w)
+	w.surface.OnDestroy(func() {
+		d.unregisterWindow(w)
+	})
 }
 
 func (d *Display) unregisterWindow(w *Window) {
@@ -301,9 +268,17 @@ func (d *Display) unregisterWindow(w *Window) {
 	}
 }
 
-// TODO
-func (d *Display) FindWindow() *Window {
-	return nil
+// FindWindow returns the window of a surface, such as the one of
+// wl.PointerEnterEvent, or nil if the surface is not a window of d.
+func (d *Display) FindWindow(s *wl.Surface) *Window {
+	if s == nil {
+		return nil
+	}
+	w, _ := s.UserData().(*Window)
+	if w == nil || w.display != d {
+		return nil
+	}
+	return w
 }
 
 func (d *Display) checkGlobalsRegistered() error {
@@ -0,0 +1,87 @@
+package ui
+
+import (
+	"testing"
+
+	"github.com/dkolbly/wl"
+	"github.com/dkolbly/wl/wltest"
+)
+
+func TestNewDisplay(t *testing.T) {
+	display, srv := wltest.NewServer(t)
+	type result struct {
+		d   *Display
+		err error
+	}
+	done := make(chan result, 1)
+	go func() {
+		d, err := NewDisplay(display)
+		done <- result{d, err}
+	}()
+
+	reg := srv.Expect("wl_display", "get_registry").Id(0)
+	ifaces := []string{"wl_compositor", "wl_shm", "wl_shell", "wl_seat", "wl_data_device_manager"}
+	for i, name := range ifaces {
+		srv.Send(reg, "global", i+1, name, 1)
+	}
+	srv.ExpectSync()
+	var seat wl.ProxyId
+	for i, name := range ifaces {
+		id := srv.Expect("wl_registry", "bind", i+1, name, 1).Id(3)
+		if name == "wl_seat" {
+			seat = id
+		}
+	}
+	srv.Send(seat, "capabilities", wl.SeatCapabilityPointer|wl.SeatCapabilityKeyboard)
+	srv.ExpectSync()
+
+	r := <-done
+	if r.err != nil {
+		t.Fatal(r.err)
+	}
+	if r.d.Pointer() == nil || r.d.Keyboard() == nil || r.d.Touch() != nil {
+		t.Errorf("input devices not set up from the seat capabilities")
+	}
+	if err := display.Context().Flush(); err != nil {
+		t.Fatal(err)
+	}
+	srv.Expect("wl_seat", "get_pointer", r.d.Pointer())
+	srv.Expect("wl_seat", "get_keyboard", r.d.Keyboard())
+}
+
+func TestNewDisplayMissingGlobals(t *testing.T) {
+	display, srv := wltest.NewServer(t)
+	done := make(chan error, 1)
+	go func() {
+		_, err := NewDisplay(display)
+		done <- err
+	}()
+
+	reg := srv.Expect("wl_display", "get_registry").Id(0)
+	srv.Send(reg, "global", 1, "wl_compositor", 4)
+	srv.ExpectSync()
+	if err := <-done; err == nil {
+		t.Error("NewDisplay succeeded without a seat")
+	}
+}
+
+func TestFindWindow(t *testing.T) {
+	display, _ := wltest.NewServer(t)
+	d := &Display{display: display}
+	s := wl.NewSurface(display.Context())
+	w := &Window{display: d, surface: s}
+	d.registerWindow(w)
+
+	if d.FindWindow(s) != w {
+		t.Error("window not found")
+	}
+	if d.FindWindow(wl.NewSurface(display.Context())) != nil || d.FindWindow(nil) != nil {
+		t.Error("found a window for a foreign surface")
+	}
+	if err := s.Destroy(); err != nil {
+		t.Fatal(err)
+	}
+	if len(d.windows) != 0 {
+		t.Error("window not unregistered when its surface was destroyed")
+	}
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"log"
 	"net/http"
@@ -30,8 +31,6 @@ func main() {
 		log.Println(http.ListenAndServe("localhost:6060", nil))
 	}()
 
-	exitChan := make(chan bool, 10)
-
 	if flag.NArg() == 0 {
 		log.Fatalf("usage: %s imagefile", os.Args[0])
 	}
@@ -55,30 +54,20 @@ func main() {
 		log.Fatal(err)
 	}
 
-	display.Keyboard().AddKeyHandler(quitter{exitChan})
+	ctx, cancel := context.WithCancel(context.Background())
+	display.Keyboard().OnKey(func(ev wl.KeyboardKeyEvent) {
+		if ev.Key == 16 {
+			cancel()
+		}
+	})
 
 	window.Draw(img)
 
-loop:
-	for {
-		select {
-		case <-exitChan:
-			break loop
-		case display.Dispatch() <- struct{}{}:
-		}
+	if err := display.Run(ctx); err != context.Canceled {
+		log.Fatal(err)
 	}
 
 	log.Print("Loop finished")
 	window.Dispose()
 	display.Disconnect()
 }
-
-type quitter struct {
-	ch chan bool
-}
-
-func (q quitter) HandleKeyboardKey(ev wl.KeyboardKeyEvent) {
-	if ev.Key == 16 {
-		q.ch <- true
-	}
-}
@@ -78,6 +78,10 @@ func (d *Display) NewWindow(width, height int32) (*Window, error) {
 		if err != nil {
 			return nil, fmt.Errorf("Surface.Commit failed: %s", err)
 		}
+		err = d.Context().Flush()
+		if err != nil {
+			return nil, fmt.Errorf("Flush failed: %s", err)
+		}
 
 		w.image = NewBGRAWithData(
 			image.Rect(0, 0, int(width), int(height)),
@@ -104,7 +108,6 @@ func (w *Window) Dispose() {
 	w.surface.Destroy()
 	w.buffer.Destroy()
 	syscall.Munmap(w.data)
-	w.display.unregisterWindow(w)
 }
 
 func (w *Window) HandleShellSurfacePing(ev wl.ShellSurfacePingEvent) {
@@ -0,0 +1,451 @@
+// Package wltest provides a scriptable fake compositor for testing
+// Wayland clients without a real server.
+//
+// NewServer connects a wl.Context to a Server over a socketpair.  The
+// test then plays the compositor: it checks the requests the client
+// sent, in order, with Expect, and answers them with events sent with
+// Send.  Requests are decoded using the protocol descriptions
+// registered with wl.RegisterInterface, so the interfaces of the
+// protocols the client uses must be linked into the test.
+//
+// The Server methods that take no error return report failures with
+// t.Fatalf, so they must be called from the goroutine running the
+// test.  Client calls that wait for the server, such as Roundtrip,
+// are made from another goroutine:
+//
+//	display, srv := wltest.NewServer(t)
+//	done := make(chan error, 1)
+//	go func() { done <- display.Roundtrip(ctx) }()
+//	srv.ExpectSync()
+//	if err := <-done; err != nil {
+//		t.Fatal(err)
+//	}
+package wltest
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dkolbly/wl"
+)
+
+// DefaultTimeout is how long Expect waits for a request.
+const DefaultTimeout = 5 * time.Second
+
+// Any matches any value of an argument in Expect.
+var Any = anyValue{}
+
+type anyValue struct{}
+
+// Request is a request received from the client, decoded using the
+// description of the interface of its object.
+type Request struct {
+	Object    wl.ProxyId
+	Interface string // empty if the object is unknown
+	Opcode    uint32
+	Name      string // empty if the request is not described
+	// Args holds the arguments as int32, uint32, wl.Fixed, string,
+	// wl.ProxyId for objects and new ids, wl.Array and *os.File for
+	// file descriptors.  Null objects are 0 and null strings are
+	// empty.
+	Args []interface{}
+}
+
+func (r *Request) String() string {
+	args := make([]string, len(r.Args))
+	for i, arg := range r.Args {
+		if f, ok := arg.(*os.File); ok {
+			arg = fmt.Sprintf("fd %d", f.Fd())
+		}
+		args[i] = fmt.Sprint(arg)
+	}
+	iface, name := r.Interface, r.Name
+	if iface == "" {
+		iface = "[unknown]"
+	}
+	if name == "" {
+		name = fmt.Sprint(r.Opcode)
+	}
+	return fmt.Sprintf("%s@%d.%s(%s)", iface, r.Object, name, strings.Join(args, ", "))
+}
+
+// Id returns argument i, which must be an object or a new id.
+func (r *Request) Id(i int) wl.ProxyId {
+	return r.Args[i].(wl.ProxyId)
+}
+
+// File returns argument i, which must be a file descriptor.
+func (r *Request) File(i int) *os.File {
+	return r.Args[i].(*os.File)
+}
+
+// Server is the compositor end of a connection to a wl.Context.
+type Server struct {
+	t    testing.TB
+	conn *wl.Conn
+	// Timeout is how long Expect waits for a request.
+	Timeout time.Duration
+
+	mu      sync.Mutex
+	objects map[wl.ProxyId]string // the interface of every object
+	nextId  wl.ProxyId
+	serial  uint32
+	files   []*os.File // received, closed by Close
+
+	requests chan *Request
+	err      error // the error that ended reading, once requests is closed
+	closed   chan struct{}
+	once     sync.Once
+}
+
+// NewServer creates a Server connected to a new wl.Context, and
+// returns the wl_display of the Context.  Both ends are closed when
+// the test ends.
+func NewServer(t testing.TB) (*wl.Display, *Server) {
+	t.Helper()
+	client, server, err := wl.SocketPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := &Server{
+		t:        t,
+		conn:     wl.NewConn(server),
+		Timeout:  DefaultTimeout,
+		objects:  map[wl.ProxyId]string{1: "wl_display"},
+		nextId:   wl.ServerIdStart,
+		requests: make(chan *Request, 64),
+		closed:   make(chan struct{}),
+	}
+	go s.read()
+	display := wl.NewContextFromConn(client)
+	t.Cleanup(func() {
+		display.Context().Close()
+		s.Close()
+	})
+	return display, s
+}
+
+// Close disconnects the client and closes the file descriptors it
+// sent.
+func (s *Server) Close() {
+	s.once.Do(func() {
+		close(s.closed)
+		s.conn.Close()
+	})
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, f := range s.files {
+		f.Close()
+	}
+	s.files = nil
+}
+
+// Conn returns the server end of the connection.
+func (s *Server) Conn() *wl.Conn {
+	return s.conn
+}
+
+func (s *Server) read() {
+	defer close(s.requests)
+	for {
+		ev, err := s.conn.ReadMessage()
+		if err != nil {
+			s.err = err
+			return
+		}
+		req, err := s.decode(ev)
+		if err != nil {
+			s.err = err
+			return
+		}
+		select {
+		case s.requests <- req:
+		case <-s.closed:
+			return
+		}
+	}
+}
+
+// decode decodes the arguments of a request, and records the objects
+// it creates.
+func (s *Server) decode(ev *wl.Event) (req *Request, err error) {
+	req = &Request{Object: ev.Id(), Opcode: ev.Opcode}
+	s.mu.Lock()
+	req.Interface = s.objects[req.Object]
+	s.mu.Unlock()
+	iface := wl.LookupInterface(req.Interface)
+	if iface == nil || int(req.Opcode) >= len(iface.Requests) {
+		return req, nil
+	}
+	msg := &iface.Requests[req.Opcode]
+	req.Name = msg.Name
+	if err := s.conn.ClaimFds(ev, msg.NumFds()); err != nil {
+		return nil, fmt.Errorf("%s: %s", req, err)
+	}
+	defer ev.CloseFds()
+	defer func() {
+		// the wire decoders panic on truncated messages
+		if e := recover(); e != nil {
+			err = fmt.Errorf("%s: malformed request: %v", req, e)
+		}
+	}()
+
+	for _, arg := range msg.Arguments() {
+		var v interface{}
+		switch arg.Type {
+		case 'i':
+			v = ev.Int32()
+		case 'u':
+			v = ev.Uint32()
+		case 'f':
+			v = ev.Fixed()
+		case 's':
+			v = ev.String()
+		case 'a':
+			v = ev.Array()
+		case 'o':
+			v = wl.ProxyId(ev.Uint32())
+		case 'n':
+			id := wl.ProxyId(ev.Uint32())
+			name := arg.Interface
+			if name == "" && len(req.Args) >= 2 {
+				// the interface was sent along, as in
+				// wl_registry.bind
+				name, _ = req.Args[len(req.Args)-2].(string)
+			}
+			s.mu.Lock()
+			s.objects[id] = name
+			s.mu.Unlock()
+			v = id
+		case 'h':
+			f := os.NewFile(ev.FD(), "fd")
+			s.mu.Lock()
+			s.files = append(s.files, f)
+			s.mu.Unlock()
+			v = f
+		}
+		req.Args = append(req.Args, v)
+	}
+	return req, nil
+}
+
+// Next returns the next request from the client, waiting up to
+// Timeout for it.
+func (s *Server) Next() (*Request, error) {
+	select {
+	case req, ok := <-s.requests:
+		if !ok {
+			return nil, fmt.Errorf("connection closed: %v", s.err)
+		}
+		return req, nil
+	case <-time.After(s.Timeout):
+		return nil, fmt.Errorf("no request within %v", s.Timeout)
+	}
+}
+
+// Expect returns the next request, failing the test unless it is the
+// named request of the given interface, with arguments matching args.
+// Arguments are compared after converting them as Send does; Any
+// matches any value, and file descriptors match anything.  Fewer args
+// than the request has check only the leading arguments.
+func (s *Server) Expect(iface, name string, args ...interface{}) *Request {
+	s.t.Helper()
+	req, err := s.Next()
+	if err != nil {
+		s.t.Fatalf("expecting %s.%s: %s", iface, name, err)
+	}
+	if req.Interface != iface || req.Name != name {
+		s.t.Fatalf("expecting %s.%s, got %s", iface, name, req)
+	}
+	_, msg := message(iface, false, name)
+	types := msg.Arguments()
+	if len(args) > len(types) {
+		s.t.Fatalf("%s.%s has %d arguments, expecting %d", iface, name, len(types), len(args))
+	}
+	for i, want := range args {
+		if want == Any || types[i].Type == 'h' {
+			continue
+		}
+		w, err := convert(types[i].Type, want)
+		if err != nil {
+			s.t.Fatalf("%s.%s argument %d: %s", iface, name, i, err)
+		}
+		if !equal(types[i].Type, req.Args[i], w) {
+			s.t.Fatalf("%s: argument %d is %v, expecting %v", req, i, req.Args[i], want)
+		}
+	}
+	return req
+}
+
+// ExpectSync expects a wl_display.sync request and answers it, as
+// wl.Display.Roundtrip waits for.
+func (s *Server) ExpectSync() *Request {
+	s.t.Helper()
+	req := s.Expect("wl_display", "sync")
+	s.Send(req.Id(0), "done", s.NextSerial())
+	s.Send(1, "delete_id", uint32(req.Id(0)))
+	return req
+}
+
+// NextSerial returns a new serial number for events that need one.
+func (s *Server) NextSerial() uint32 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.serial++
+	return s.serial
+}
+
+// NewObject allocates an id for an object created by the server, to be
+// passed as the new_id argument of an event.
+func (s *Server) NewObject(iface string) wl.ProxyId {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	id := s.nextId
+	s.nextId++
+	s.objects[id] = iface
+	return id
+}
+
+// Send sends the named event to object id, failing the test on error.
+// Arguments are converted to the types of the event signature: ints
+// to int32 or uint32, float64 to wl.Fixed, proxies to their ids, and
+// *os.File or int to file descriptors.  A nil argument is the null
+// object or string.
+func (s *Server) Send(id wl.ProxyId, event string, args ...interface{}) {
+	s.t.Helper()
+	if err := s.SendEvent(id, event, args...); err != nil {
+		s.t.Fatal(err)
+	}
+}
+
+// SendEvent is like Send, but returns errors instead of failing the
+// test, so that it may be called from any goroutine.
+func (s *Server) SendEvent(id wl.ProxyId, event string, args ...interface{}) error {
+	s.mu.Lock()
+	name := s.objects[id]
+	s.mu.Unlock()
+	opcode, msg := message(name, true, event)
+	if msg == nil {
+		return fmt.Errorf("%s@%d has no event %q", name, id, event)
+	}
+	types := msg.Arguments()
+	if len(args) != len(types) {
+		return fmt.Errorf("%s.%s takes %d arguments, not %d", name, event, len(types), len(args))
+	}
+	wire := make([]interface{}, len(args))
+	for i, arg := range args {
+		v, err := convert(types[i].Type, arg)
+		if err != nil {
+			return fmt.Errorf("%s.%s argument %d: %s", name, event, i, err)
+		}
+		wire[i] = v
+	}
+	return s.conn.WriteMessage(wl.NewRequest(id, opcode, wire...))
+}
+
+// message returns the opcode and description of the named event or
+// request of the interface, or nil.
+func message(iface string, event bool, name string) (uint32, *wl.Message) {
+	i := wl.LookupInterface(iface)
+	if i == nil {
+		return 0, nil
+	}
+	msgs := i.Requests
+	if event {
+		msgs = i.Events
+	}
+	for j := range msgs {
+		if msgs[j].Name == name {
+			return uint32(j), &msgs[j]
+		}
+	}
+	return 0, nil
+}
+
+// equal reports whether a decoded argument of type typ matches want,
+// as returned by convert.
+func equal(typ byte, got, want interface{}) bool {
+	if want == nil {
+		// the null object or string
+		switch typ {
+		case 'o':
+			want = wl.ProxyId(0)
+		case 's':
+			want = ""
+		}
+	}
+	if typ == 'a' {
+		g, _ := got.(wl.Array)
+		w, _ := want.(wl.Array)
+		return bytes.Equal(g, w)
+	}
+	return reflect.DeepEqual(got, want)
+}
+
+// convert converts v to the type wl.NewRequest writes for an argument
+// of type typ.
+func convert(typ byte, v interface{}) (interface{}, error) {
+	if v == nil {
+		return nil, nil
+	}
+	switch typ {
+	case 'i':
+		switch v := v.(type) {
+		case int:
+			return int32(v), nil
+		case int32:
+			return v, nil
+		}
+	case 'u':
+		switch v := v.(type) {
+		case int:
+			return uint32(v), nil
+		case uint32:
+			return v, nil
+		}
+	case 'f':
+		switch v := v.(type) {
+		case int:
+			return wl.FromInt(v), nil
+		case float64:
+			return wl.FromFloat64(v), nil
+		case wl.Fixed:
+			return v, nil
+		}
+	case 's':
+		if v, ok := v.(string); ok {
+			return v, nil
+		}
+	case 'a':
+		switch v := v.(type) {
+		case []byte:
+			return wl.Array(v), nil
+		case wl.Array:
+			return v, nil
+		}
+	case 'o', 'n':
+		switch v := v.(type) {
+		case wl.ProxyId:
+			return v, nil
+		case wl.Proxy:
+			return v.Id(), nil
+		}
+	case 'h':
+		switch v := v.(type) {
+		case *os.File:
+			return v.Fd(), nil
+		case int:
+			return uintptr(v), nil
+		case uintptr:
+			return v, nil
+		}
+	}
+	return nil, fmt.Errorf("%T is not valid for type %c", v, typ)
+}
@@ -0,0 +1,188 @@
+package wltest
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/dkolbly/wl"
+)
+
+type globals map[string]wl.RegistryGlobalEvent
+
+func (g globals) HandleRegistryGlobal(ev wl.RegistryGlobalEvent) {
+	g[ev.Interface] = ev
+}
+
+type keymap chan wl.KeyboardKeymapEvent
+
+func (k keymap) HandleKeyboardKeymap(ev wl.KeyboardKeymapEvent) {
+	k <- ev
+}
+
+// roundtrip runs display.Roundtrip while the server answers the sync.
+func roundtrip(t *testing.T, display *wl.Display, srv *Server) {
+	t.Helper()
+	done := make(chan error, 1)
+	go func() { done <- display.Roundtrip(context.Background()) }()
+	srv.ExpectSync()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+}
+
+// bind advertises a global and has the client bind proxy to it.
+func bind(t *testing.T, display *wl.Display, srv *Server, iface string, proxy wl.Proxy) {
+	t.Helper()
+	registry, err := display.GetRegistry()
+	if err != nil {
+		t.Fatal(err)
+	}
+	seen := make(globals)
+	registry.AddGlobalHandler(seen)
+	if err := display.Context().Flush(); err != nil {
+		t.Fatal(err)
+	}
+	reg := srv.Expect("wl_display", "get_registry", registry).Id(0)
+	srv.Send(reg, "global", 1, iface, 1)
+	roundtrip(t, display, srv)
+
+	g, ok := seen[iface]
+	if !ok || g.Version != 1 {
+		t.Fatalf("%s not advertised: %v", iface, seen)
+	}
+	if _, err := registry.BindGlobal(g, proxy); err != nil {
+		t.Fatal(err)
+	}
+	if err := display.Context().Flush(); err != nil {
+		t.Fatal(err)
+	}
+	srv.Expect("wl_registry", "bind", 1, iface, 1, proxy)
+}
+
+func TestSendFd(t *testing.T) {
+	display, srv := NewServer(t)
+	seat := wl.NewSeat(display.Context())
+	bind(t, display, srv, "wl_seat", seat)
+
+	keyboard, err := seat.GetKeyboard()
+	if err != nil {
+		t.Fatal(err)
+	}
+	keymaps := make(keymap, 1)
+	keyboard.AddKeymapHandler(keymaps)
+	if err := display.Context().Flush(); err != nil {
+		t.Fatal(err)
+	}
+	kbd := srv.Expect("wl_seat", "get_keyboard", keyboard).Id(0)
+
+	f, err := os.CreateTemp("", "wltest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+	if _, err := f.WriteString("keymap"); err != nil {
+		t.Fatal(err)
+	}
+	srv.Send(kbd, "keymap", wl.KeyboardKeymapFormatXkbV1, f, 6)
+	roundtrip(t, display, srv)
+
+	ev := <-keymaps
+	got := os.NewFile(ev.Fd, "keymap")
+	defer got.Close()
+	buf := make([]byte, ev.Size)
+	if _, err := got.ReadAt(buf, 0); err != nil {
+		t.Fatal(err)
+	}
+	if string(buf) != "keymap" {
+		t.Errorf("keymap is %q", buf)
+	}
+}
+
+func TestRequestFd(t *testing.T) {
+	display, srv := NewServer(t)
+	shm := wl.NewShm(display.Context())
+	bind(t, display, srv, "wl_shm", shm)
+
+	f, err := os.CreateTemp("", "wltest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+	if _, err := f.WriteString("pixels"); err != nil {
+		t.Fatal(err)
+	}
+	pool, err := shm.CreatePool(f.Fd(), 6)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := display.Context().Flush(); err != nil {
+		t.Fatal(err)
+	}
+	req := srv.Expect("wl_shm", "create_pool", pool, Any, 6)
+	buf := make([]byte, 6)
+	if _, err := req.File(1).ReadAt(buf, 0); err != nil {
+		t.Fatal(err)
+	}
+	if string(buf) != "pixels" {
+		t.Errorf("pool contents %q", buf)
+	}
+}
+
+func TestNext(t *testing.T) {
+	display, srv := NewServer(t)
+	if _, err := display.GetRegistry(); err != nil {
+		t.Fatal(err)
+	}
+	if err := display.Context().Flush(); err != nil {
+		t.Fatal(err)
+	}
+	req, err := srv.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if req.Interface != "wl_display" || req.Name != "get_registry" || len(req.Args) != 1 {
+		t.Errorf("unexpected request %s", req)
+	}
+}
+
+func TestOnEvent(t *testing.T) {
+	display, srv := NewServer(t)
+	seat := wl.NewSeat(display.Context())
+	bind(t, display, srv, "wl_seat", seat)
+	keyboard, err := seat.GetKeyboard()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := display.Context().Flush(); err != nil {
+		t.Fatal(err)
+	}
+	kbd := srv.Expect("wl_seat", "get_keyboard", keyboard).Id(0)
+
+	var first, all []uint32
+	var cancelFirst func()
+	cancelFirst = keyboard.OnKey(func(ev wl.KeyboardKeyEvent) {
+		first = append(first, ev.Key)
+		// handlers may remove themselves
+		cancelFirst()
+	})
+	cancelAll := keyboard.OnKey(func(ev wl.KeyboardKeyEvent) {
+		all = append(all, ev.Key)
+	})
+	srv.Send(kbd, "key", 1, 0, 16, wl.KeyboardKeyStatePressed)
+	srv.Send(kbd, "key", 2, 0, 17, wl.KeyboardKeyStatePressed)
+	roundtrip(t, display, srv)
+	cancelAll()
+	cancelAll()
+	srv.Send(kbd, "key", 3, 0, 18, wl.KeyboardKeyStatePressed)
+	roundtrip(t, display, srv)
+
+	if len(first) != 1 || first[0] != 16 {
+		t.Errorf("one-shot handler got %v", first)
+	}
+	if len(all) != 2 || all[1] != 17 {
+		t.Errorf("handler got %v", all)
+	}
+}
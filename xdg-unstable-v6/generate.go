@@ -0,0 +1,3 @@
+package zxdg
+
+//go:generate go run ../cmd/wl-scanner -pkg zxdg -prefix zxdg_ -suffix _v6 -o shell.go ../protocol/xdg-shell-unstable-v6.xml
@@ -1,9 +1,6 @@
-// package zxdg acts as a client for the xdg_shell_unstable_v6 wayland protocol.
+// Code generated by wl-scanner from xdg-shell-unstable-v6.xml. DO NOT EDIT.
 
-// generated by wl-scanner
-// https://github.com/dkolbly/wl-scanner
-// from: https://raw.githubusercontent.com/wayland-project/wayland-protocols/master/unstable/xdg-shell/xdg-shell-unstable-v6.xml
-// on 2018-02-19 14:50:40 -0600
+// Package zxdg acts as a client for the xdg_shell_unstable_v6 protocol.
 package zxdg
 
 import (
@@ -36,24 +33,41 @@ func (p *Shell) RemovePingHandler(h ShellPingHandler) {
 
 	for i, e := range p.pingHandlers {
 		if e == h {
-			p.pingHandlers = append(p.pingHandlers[:i], p.pingHandlers[i+1:]...)
+			// copied, as Dispatch may be iterating over the old slice
+			p.pingHandlers = append(p.pingHandlers[:i:i], p.pingHandlers[i+1:]...)
 			break
 		}
 	}
 }
 
+type shellPingFunc struct {
+	f func(ShellPingEvent)
+}
+
+func (h *shellPingFunc) HandleShellPing(ev ShellPingEvent) {
+	h.f(ev)
+}
+
+// OnPing calls f for every ping event, until cancel is called.
+func (p *Shell) OnPing(f func(ShellPingEvent)) (cancel func()) {
+	h := &shellPingFunc{f}
+	p.AddPingHandler(h)
+	return func() { p.RemovePingHandler(h) }
+}
+
 func (p *Shell) Dispatch(ctx context.Context, event *wl.Event) {
 	switch event.Opcode {
 	case 0:
-		if len(p.pingHandlers) > 0 {
+		p.mu.RLock()
+		handlers := p.pingHandlers
+		p.mu.RUnlock()
+		if len(handlers) > 0 {
 			ev := ShellPingEvent{}
 			ev.EventContext = ctx
 			ev.Serial = event.Uint32()
-			p.mu.RLock()
-			for _, h := range p.pingHandlers {
+			for _, h := range handlers {
 				h.HandleShellPing(ev)
 			}
-			p.mu.RUnlock()
 		}
 	}
 }
@@ -70,34 +84,67 @@ func NewShell(ctx *wl.Context) *Shell {
 	return ret
 }
 
+var shellInterface = &wl.Interface{
+	Name:    "zxdg_shell_v6",
+	Version: 1,
+	New:     func() wl.Proxy { return new(Shell) },
+	Requests: []wl.Message{
+		{Name: "destroy"},
+		{Name: "create_positioner", Signature: "n", Types: []string{"zxdg_positioner_v6"}},
+		{Name: "get_xdg_surface", Signature: "no", Types: []string{"zxdg_surface_v6", "wl_surface"}},
+		{Name: "pong", Signature: "u"},
+	},
+	Events: []wl.Message{
+		{Name: "ping", Signature: "u"},
+	},
+	Errors: map[uint32]string{
+		ShellErrorRole:                "role",
+		ShellErrorDefunctSurfaces:     "defunct_surfaces",
+		ShellErrorNotTheTopmostPopup:  "not_the_topmost_popup",
+		ShellErrorInvalidPopupParent:  "invalid_popup_parent",
+		ShellErrorInvalidSurfaceState: "invalid_surface_state",
+		ShellErrorInvalidPositioner:   "invalid_positioner",
+	},
+}
+
+// Interface returns the description of the zxdg_shell_v6 interface.
+func (p *Shell) Interface() *wl.Interface {
+	return shellInterface
+}
+
 // Destroy will destroy xdg_shell.
 //
-//
 // Destroy this xdg_shell object.
 //
 // Destroying a bound xdg_shell object while there are surfaces
 // still alive created by this xdg_shell object instance is illegal
 // and will result in a protocol error.
-//
 func (p *Shell) Destroy() error {
-	return p.Context().SendRequest(p, 0)
+	if err := p.Context().SendRequest(p, 0); err != nil {
+		return err
+	}
+	p.Context().Unregister(p)
+	return nil
 }
 
 // CreatePositioner will create a positioner object.
 //
-//
 // Create a positioner object. A positioner object is used to position
 // surfaces relative to some parent surface. See the interface description
 // and xdg_surface.get_popup for details.
-//
 func (p *Shell) CreatePositioner() (*Positioner, error) {
 	ret := NewPositioner(p.Context())
-	return ret, p.Context().SendRequest(p, 1, wl.Proxy(ret))
+	ret.SetQueue(p.Queue())
+	ret.SetVersion(p.Version())
+	if err := p.Context().SendRequest(p, 1, wl.Proxy(ret)); err != nil {
+		p.Context().Discard(ret)
+		return nil, err
+	}
+	return ret, nil
 }
 
 // GetXdgSurface will create a shell surface from a surface.
 //
-//
 // This creates an xdg_surface for the given surface. While xdg_surface
 // itself is not a role, the corresponding surface may only be assigned
 // a role extending xdg_surface, such as xdg_toplevel or xdg_popup.
@@ -109,22 +156,26 @@ func (p *Shell) CreatePositioner() (*Positioner, error) {
 //
 // See the documentation of xdg_surface for more details about what an
 // xdg_surface is and how it is used.
-//
 func (p *Shell) GetXdgSurface(surface *wl.Surface) (*Surface, error) {
 	ret := NewSurface(p.Context())
-	return ret, p.Context().SendRequest(p, 2, wl.Proxy(ret), surface)
+	ret.SetQueue(p.Queue())
+	ret.SetVersion(p.Version())
+	if err := p.Context().SendRequest(p, 2, wl.Proxy(ret), surface); err != nil {
+		p.Context().Discard(ret)
+		return nil, err
+	}
+	return ret, nil
 }
 
 // Pong will respond to a ping event.
 //
-//
 // A client must respond to a ping event with a pong request or
 // the client may be deemed unresponsive. See xdg_shell.ping.
-//
 func (p *Shell) Pong(serial uint32) error {
 	return p.Context().SendRequest(p, 3, serial)
 }
 
+// Error codes of zxdg_shell_v6, reported with wl_display.error.
 const (
 	ShellErrorRole                = 0
 	ShellErrorDefunctSurfaces     = 1
@@ -144,31 +195,53 @@ func NewPositioner(ctx *wl.Context) *Positioner {
 	return ret
 }
 
+var positionerInterface = &wl.Interface{
+	Name:    "zxdg_positioner_v6",
+	Version: 1,
+	New:     func() wl.Proxy { return new(Positioner) },
+	Requests: []wl.Message{
+		{Name: "destroy"},
+		{Name: "set_size", Signature: "ii"},
+		{Name: "set_anchor_rect", Signature: "iiii"},
+		{Name: "set_anchor", Signature: "u"},
+		{Name: "set_gravity", Signature: "u"},
+		{Name: "set_constraint_adjustment", Signature: "u"},
+		{Name: "set_offset", Signature: "ii"},
+	},
+	Errors: map[uint32]string{
+		PositionerErrorInvalidInput: "invalid_input",
+	},
+}
+
+// Interface returns the description of the zxdg_positioner_v6 interface.
+func (p *Positioner) Interface() *wl.Interface {
+	return positionerInterface
+}
+
 // Destroy will destroy the xdg_positioner object.
 //
-//
 // Notify the compositor that the xdg_positioner will no longer be used.
-//
 func (p *Positioner) Destroy() error {
-	return p.Context().SendRequest(p, 0)
+	if err := p.Context().SendRequest(p, 0); err != nil {
+		return err
+	}
+	p.Context().Unregister(p)
+	return nil
 }
 
 // SetSize will set the size of the to-be positioned rectangle.
 //
-//
 // Set the size of the surface that is to be positioned with the positioner
 // object. The size is in surface-local coordinates and corresponds to the
 // window geometry. See xdg_surface.set_window_geometry.
 //
 // If a zero or negative size is set the invalid_input error is raised.
-//
 func (p *Positioner) SetSize(width int32, height int32) error {
 	return p.Context().SendRequest(p, 1, width, height)
 }
 
 // SetAnchorRect will set the anchor rectangle within the parent surface.
 //
-//
 // Specify the anchor rectangle within the parent surface that the child
 // surface will be placed relative to. The rectangle is relative to the
 // window geometry as defined by xdg_surface.set_window_geometry of the
@@ -179,14 +252,12 @@ func (p *Positioner) SetSize(width int32, height int32) error {
 // positioned child's parent surface.
 //
 // If a zero or negative size is set the invalid_input error is raised.
-//
 func (p *Positioner) SetAnchorRect(x int32, y int32, width int32, height int32) error {
 	return p.Context().SendRequest(p, 2, x, y, width, height)
 }
 
 // SetAnchor will set anchor rectangle anchor edges.
 //
-//
 // Defines a set of edges for the anchor rectangle. These are used to
 // derive an anchor point that the child surface will be positioned
 // relative to. If two orthogonal edges are specified (e.g. 'top' and
@@ -197,14 +268,12 @@ func (p *Positioner) SetAnchorRect(x int32, y int32, width int32, height int32)
 //
 // If two parallel anchor edges are specified (e.g. 'left' and 'right'),
 // the invalid_input error is raised.
-//
 func (p *Positioner) SetAnchor(anchor uint32) error {
 	return p.Context().SendRequest(p, 3, anchor)
 }
 
 // SetGravity will set child surface gravity.
 //
-//
 // Defines in what direction a surface should be positioned, relative to
 // the anchor point of the parent surface. If two orthogonal gravities are
 // specified (e.g. 'bottom' and 'right'), then the child surface will be
@@ -214,14 +283,12 @@ func (p *Positioner) SetAnchor(anchor uint32) error {
 //
 // If two parallel gravities are specified (e.g. 'left' and 'right'), the
 // invalid_input error is raised.
-//
 func (p *Positioner) SetGravity(gravity uint32) error {
 	return p.Context().SendRequest(p, 4, gravity)
 }
 
 // SetConstraintAdjustment will set the adjustment to be done when constrained.
 //
-//
 // Specify how the window should be positioned if the originally intended
 // position caused the surface to be constrained, meaning at least
 // partially outside positioning boundaries set by the compositor. The
@@ -235,14 +302,12 @@ func (p *Positioner) SetGravity(gravity uint32) error {
 // are applied is specified in the corresponding adjustment descriptions.
 //
 // The default adjustment is none.
-//
 func (p *Positioner) SetConstraintAdjustment(constraint_adjustment uint32) error {
 	return p.Context().SendRequest(p, 5, constraint_adjustment)
 }
 
 // SetOffset will set surface position offset.
 //
-//
 // Specify the surface position offset relative to the position of the
 // anchor on the anchor rectangle and the anchor on the surface. For
 // example if the anchor of the anchor rectangle is at (x, y), the surface
@@ -254,15 +319,17 @@ func (p *Positioner) SetConstraintAdjustment(constraint_adjustment uint32) error
 // An example use case is placing a popup menu on top of a user interface
 // element, while aligning the user interface element of the parent surface
 // with some user interface element placed somewhere in the popup surface.
-//
 func (p *Positioner) SetOffset(x int32, y int32) error {
 	return p.Context().SendRequest(p, 6, x, y)
 }
 
+// Error codes of zxdg_positioner_v6, reported with wl_display.error.
 const (
 	PositionerErrorInvalidInput = 0
 )
 
+// Values of the anchor bitfield of zxdg_positioner_v6, for the anchor
+// argument of zxdg_positioner_v6.set_anchor.
 const (
 	PositionerAnchorNone   = 0
 	PositionerAnchorTop    = 1
@@ -271,6 +338,8 @@ const (
 	PositionerAnchorRight  = 8
 )
 
+// Values of the gravity bitfield of zxdg_positioner_v6, for the gravity
+// argument of zxdg_positioner_v6.set_gravity.
 const (
 	PositionerGravityNone   = 0
 	PositionerGravityTop    = 1
@@ -279,6 +348,9 @@ const (
 	PositionerGravityRight  = 8
 )
 
+// Values of the constraint_adjustment bitfield of zxdg_positioner_v6,
+// for the constraint_adjustment argument of
+// zxdg_positioner_v6.set_constraint_adjustment.
 const (
 	PositionerConstraintAdjustmentNone    = 0
 	PositionerConstraintAdjustmentSlideX  = 1
@@ -312,24 +384,41 @@ func (p *Surface) RemoveConfigureHandler(h SurfaceConfigureHandler) {
 
 	for i, e := range p.configureHandlers {
 		if e == h {
-			p.configureHandlers = append(p.configureHandlers[:i], p.configureHandlers[i+1:]...)
+			// copied, as Dispatch may be iterating over the old slice
+			p.configureHandlers = append(p.configureHandlers[:i:i], p.configureHandlers[i+1:]...)
 			break
 		}
 	}
 }
 
+type surfaceConfigureFunc struct {
+	f func(SurfaceConfigureEvent)
+}
+
+func (h *surfaceConfigureFunc) HandleSurfaceConfigure(ev SurfaceConfigureEvent) {
+	h.f(ev)
+}
+
+// OnConfigure calls f for every configure event, until cancel is called.
+func (p *Surface) OnConfigure(f func(SurfaceConfigureEvent)) (cancel func()) {
+	h := &surfaceConfigureFunc{f}
+	p.AddConfigureHandler(h)
+	return func() { p.RemoveConfigureHandler(h) }
+}
+
 func (p *Surface) Dispatch(ctx context.Context, event *wl.Event) {
 	switch event.Opcode {
 	case 0:
-		if len(p.configureHandlers) > 0 {
+		p.mu.RLock()
+		handlers := p.configureHandlers
+		p.mu.RUnlock()
+		if len(handlers) > 0 {
 			ev := SurfaceConfigureEvent{}
 			ev.EventContext = ctx
 			ev.Serial = event.Uint32()
-			p.mu.RLock()
-			for _, h := range p.configureHandlers {
+			for _, h := range handlers {
 				h.HandleSurfaceConfigure(ev)
 			}
-			p.mu.RUnlock()
 		}
 	}
 }
@@ -346,47 +435,82 @@ func NewSurface(ctx *wl.Context) *Surface {
 	return ret
 }
 
+var surfaceInterface = &wl.Interface{
+	Name:    "zxdg_surface_v6",
+	Version: 1,
+	New:     func() wl.Proxy { return new(Surface) },
+	Requests: []wl.Message{
+		{Name: "destroy"},
+		{Name: "get_toplevel", Signature: "n", Types: []string{"zxdg_toplevel_v6"}},
+		{Name: "get_popup", Signature: "noo", Types: []string{"zxdg_popup_v6", "zxdg_surface_v6", "zxdg_positioner_v6"}},
+		{Name: "set_window_geometry", Signature: "iiii"},
+		{Name: "ack_configure", Signature: "u"},
+	},
+	Events: []wl.Message{
+		{Name: "configure", Signature: "u"},
+	},
+	Errors: map[uint32]string{
+		SurfaceErrorNotConstructed:     "not_constructed",
+		SurfaceErrorAlreadyConstructed: "already_constructed",
+		SurfaceErrorUnconfiguredBuffer: "unconfigured_buffer",
+	},
+}
+
+// Interface returns the description of the zxdg_surface_v6 interface.
+func (p *Surface) Interface() *wl.Interface {
+	return surfaceInterface
+}
+
 // Destroy will destroy the xdg_surface.
 //
-//
 // Destroy the xdg_surface object. An xdg_surface must only be destroyed
 // after its role object has been destroyed.
-//
 func (p *Surface) Destroy() error {
-	return p.Context().SendRequest(p, 0)
+	if err := p.Context().SendRequest(p, 0); err != nil {
+		return err
+	}
+	p.Context().Unregister(p)
+	return nil
 }
 
 // GetToplevel will assign the xdg_toplevel surface role.
 //
-//
 // This creates an xdg_toplevel object for the given xdg_surface and gives
 // the associated wl_surface the xdg_toplevel role.
 //
 // See the documentation of xdg_toplevel for more details about what an
 // xdg_toplevel is and how it is used.
-//
 func (p *Surface) GetToplevel() (*Toplevel, error) {
 	ret := NewToplevel(p.Context())
-	return ret, p.Context().SendRequest(p, 1, wl.Proxy(ret))
+	ret.SetQueue(p.Queue())
+	ret.SetVersion(p.Version())
+	if err := p.Context().SendRequest(p, 1, wl.Proxy(ret)); err != nil {
+		p.Context().Discard(ret)
+		return nil, err
+	}
+	return ret, nil
 }
 
 // GetPopup will assign the xdg_popup surface role.
 //
-//
 // This creates an xdg_popup object for the given xdg_surface and gives the
 // associated wl_surface the xdg_popup role.
 //
 // See the documentation of xdg_popup for more details about what an
 // xdg_popup is and how it is used.
-//
 func (p *Surface) GetPopup(parent *Surface, positioner *Positioner) (*Popup, error) {
 	ret := NewPopup(p.Context())
-	return ret, p.Context().SendRequest(p, 2, wl.Proxy(ret), parent, positioner)
+	ret.SetQueue(p.Queue())
+	ret.SetVersion(p.Version())
+	if err := p.Context().SendRequest(p, 2, wl.Proxy(ret), parent, positioner); err != nil {
+		p.Context().Discard(ret)
+		return nil, err
+	}
+	return ret, nil
 }
 
 // SetWindowGeometry will set the new window geometry.
 //
-//
 // The window geometry of a surface is its "visible bounds" from the
 // user's perspective. Client-side decorations often have invisible
 // portions like drop-shadows which should be ignored for the
@@ -411,14 +535,12 @@ func (p *Surface) GetPopup(parent *Surface, positioner *Positioner) (*Popup, err
 // the set window geometry clamped to the bounding rectangle of the
 // combined geometry of the surface of the xdg_surface and the associated
 // subsurfaces.
-//
 func (p *Surface) SetWindowGeometry(x int32, y int32, width int32, height int32) error {
 	return p.Context().SendRequest(p, 3, x, y, width, height)
 }
 
 // AckConfigure will ack a configure event.
 //
-//
 // When a configure event is received, if a client commits the
 // surface in response to the configure event, then the client
 // must make an ack_configure request sometime before the commit
@@ -438,11 +560,11 @@ func (p *Surface) SetWindowGeometry(x int32, y int32, width int32, height int32)
 // A client may send multiple ack_configure requests before committing, but
 // only the last request sent before a commit indicates which configure
 // event the client really is responding to.
-//
 func (p *Surface) AckConfigure(serial uint32) error {
 	return p.Context().SendRequest(p, 4, serial)
 }
 
+// Error codes of zxdg_surface_v6, reported with wl_display.error.
 const (
 	SurfaceErrorNotConstructed     = 1
 	SurfaceErrorAlreadyConstructed = 2
@@ -453,7 +575,7 @@ type ToplevelConfigureEvent struct {
 	EventContext context.Context
 	Width        int32
 	Height       int32
-	States       []int32
+	States       wl.Array
 }
 
 type ToplevelConfigureHandler interface {
@@ -474,12 +596,28 @@ func (p *Toplevel) RemoveConfigureHandler(h ToplevelConfigureHandler) {
 
 	for i, e := range p.configureHandlers {
 		if e == h {
-			p.configureHandlers = append(p.configureHandlers[:i], p.configureHandlers[i+1:]...)
+			// copied, as Dispatch may be iterating over the old slice
+			p.configureHandlers = append(p.configureHandlers[:i:i], p.configureHandlers[i+1:]...)
 			break
 		}
 	}
 }
 
+type toplevelConfigureFunc struct {
+	f func(ToplevelConfigureEvent)
+}
+
+func (h *toplevelConfigureFunc) HandleToplevelConfigure(ev ToplevelConfigureEvent) {
+	h.f(ev)
+}
+
+// OnConfigure calls f for every configure event, until cancel is called.
+func (p *Toplevel) OnConfigure(f func(ToplevelConfigureEvent)) (cancel func()) {
+	h := &toplevelConfigureFunc{f}
+	p.AddConfigureHandler(h)
+	return func() { p.RemoveConfigureHandler(h) }
+}
+
 type ToplevelCloseEvent struct {
 	EventContext context.Context
 }
@@ -502,36 +640,54 @@ func (p *Toplevel) RemoveCloseHandler(h ToplevelCloseHandler) {
 
 	for i, e := range p.closeHandlers {
 		if e == h {
-			p.closeHandlers = append(p.closeHandlers[:i], p.closeHandlers[i+1:]...)
+			// copied, as Dispatch may be iterating over the old slice
+			p.closeHandlers = append(p.closeHandlers[:i:i], p.closeHandlers[i+1:]...)
 			break
 		}
 	}
 }
 
+type toplevelCloseFunc struct {
+	f func(ToplevelCloseEvent)
+}
+
+func (h *toplevelCloseFunc) HandleToplevelClose(ev ToplevelCloseEvent) {
+	h.f(ev)
+}
+
+// OnClose calls f for every close event, until cancel is called.
+func (p *Toplevel) OnClose(f func(ToplevelCloseEvent)) (cancel func()) {
+	h := &toplevelCloseFunc{f}
+	p.AddCloseHandler(h)
+	return func() { p.RemoveCloseHandler(h) }
+}
+
 func (p *Toplevel) Dispatch(ctx context.Context, event *wl.Event) {
 	switch event.Opcode {
 	case 0:
-		if len(p.configureHandlers) > 0 {
+		p.mu.RLock()
+		handlers := p.configureHandlers
+		p.mu.RUnlock()
+		if len(handlers) > 0 {
 			ev := ToplevelConfigureEvent{}
 			ev.EventContext = ctx
 			ev.Width = event.Int32()
 			ev.Height = event.Int32()
 			ev.States = event.Array()
-			p.mu.RLock()
-			for _, h := range p.configureHandlers {
+			for _, h := range handlers {
 				h.HandleToplevelConfigure(ev)
 			}
-			p.mu.RUnlock()
 		}
 	case 1:
-		if len(p.closeHandlers) > 0 {
+		p.mu.RLock()
+		handlers := p.closeHandlers
+		p.mu.RUnlock()
+		if len(handlers) > 0 {
 			ev := ToplevelCloseEvent{}
 			ev.EventContext = ctx
-			p.mu.RLock()
-			for _, h := range p.closeHandlers {
+			for _, h := range handlers {
 				h.HandleToplevelClose(ev)
 			}
-			p.mu.RUnlock()
 		}
 	}
 }
@@ -549,20 +705,52 @@ func NewToplevel(ctx *wl.Context) *Toplevel {
 	return ret
 }
 
+var toplevelInterface = &wl.Interface{
+	Name:    "zxdg_toplevel_v6",
+	Version: 1,
+	New:     func() wl.Proxy { return new(Toplevel) },
+	Requests: []wl.Message{
+		{Name: "destroy"},
+		{Name: "set_parent", Signature: "?o", Types: []string{"zxdg_toplevel_v6"}},
+		{Name: "set_title", Signature: "s"},
+		{Name: "set_app_id", Signature: "s"},
+		{Name: "show_window_menu", Signature: "ouii", Types: []string{"wl_seat", "", "", ""}},
+		{Name: "move", Signature: "ou", Types: []string{"wl_seat", ""}},
+		{Name: "resize", Signature: "ouu", Types: []string{"wl_seat", "", ""}},
+		{Name: "set_max_size", Signature: "ii"},
+		{Name: "set_min_size", Signature: "ii"},
+		{Name: "set_maximized"},
+		{Name: "unset_maximized"},
+		{Name: "set_fullscreen", Signature: "?o", Types: []string{"wl_output"}},
+		{Name: "unset_fullscreen"},
+		{Name: "set_minimized"},
+	},
+	Events: []wl.Message{
+		{Name: "configure", Signature: "iia"},
+		{Name: "close"},
+	},
+}
+
+// Interface returns the description of the zxdg_toplevel_v6 interface.
+func (p *Toplevel) Interface() *wl.Interface {
+	return toplevelInterface
+}
+
 // Destroy will destroy the xdg_toplevel.
 //
-//
 // Unmap and destroy the window. The window will be effectively
 // hidden from the user's point of view, and all state like
 // maximization, fullscreen, and so on, will be lost.
-//
 func (p *Toplevel) Destroy() error {
-	return p.Context().SendRequest(p, 0)
+	if err := p.Context().SendRequest(p, 0); err != nil {
+		return err
+	}
+	p.Context().Unregister(p)
+	return nil
 }
 
 // SetParent will set the parent of this surface.
 //
-//
 // Set the "parent" of this surface. This window should be stacked
 // above a parent. The parent surface must be mapped as long as this
 // surface is mapped.
@@ -570,14 +758,12 @@ func (p *Toplevel) Destroy() error {
 // Parent windows should be set on dialogs, toolboxes, or other
 // "auxiliary" surfaces, so that the parent is raised when the dialog
 // is raised.
-//
 func (p *Toplevel) SetParent(parent *Toplevel) error {
-	return p.Context().SendRequest(p, 1, parent)
+	return p.Context().SendRequest(p, 1, wl.NullableProxy(parent))
 }
 
 // SetTitle will set surface title.
 //
-//
 // Set a short title for the surface.
 //
 // This string may be used to identify the surface in a task bar,
@@ -585,14 +771,12 @@ func (p *Toplevel) SetParent(parent *Toplevel) error {
 // compositor.
 //
 // The string must be encoded in UTF-8.
-//
 func (p *Toplevel) SetTitle(title string) error {
 	return p.Context().SendRequest(p, 2, title)
 }
 
 // SetAppId will set application ID.
 //
-//
 // Set an application identifier for the surface.
 //
 // The app ID identifies the general class of applications to which
@@ -613,14 +797,12 @@ func (p *Toplevel) SetTitle(title string) error {
 // names and .desktop files.
 //
 // [0] http://standards.freedesktop.org/desktop-entry-spec/
-//
 func (p *Toplevel) SetAppId(app_id string) error {
 	return p.Context().SendRequest(p, 3, app_id)
 }
 
 // ShowWindowMenu will show the window menu.
 //
-//
 // Clients implementing client-side decorations might want to show
 // a context menu when right-clicking on the decorations, giving the
 // user a menu that they can use to maximize or minimize the window.
@@ -632,14 +814,12 @@ func (p *Toplevel) SetAppId(app_id string) error {
 //
 // This request must be used in response to some sort of user action
 // like a button press, key press, or touch down event.
-//
 func (p *Toplevel) ShowWindowMenu(seat *wl.Seat, serial uint32, x int32, y int32) error {
 	return p.Context().SendRequest(p, 4, seat, serial, x, y)
 }
 
 // Move will start an interactive move.
 //
-//
 // Start an interactive, user-driven move of the surface.
 //
 // This request must be used in response to some sort of user action
@@ -656,14 +836,12 @@ func (p *Toplevel) ShowWindowMenu(seat *wl.Seat, serial uint32, x int32, y int32
 // compositor to visually indicate that the move is taking place, such as
 // updating a pointer cursor, during the move. There is no guarantee
 // that the device focus will return when the move is completed.
-//
 func (p *Toplevel) Move(seat *wl.Seat, serial uint32) error {
 	return p.Context().SendRequest(p, 5, seat, serial)
 }
 
 // Resize will start an interactive resize.
 //
-//
 // Start a user-driven, interactive resize of the surface.
 //
 // This request must be used in response to some sort of user action
@@ -694,14 +872,12 @@ func (p *Toplevel) Move(seat *wl.Seat, serial uint32) error {
 // example when dragging the top left corner. The compositor may also
 // use this information to adapt its behavior, e.g. choose an
 // appropriate cursor image.
-//
 func (p *Toplevel) Resize(seat *wl.Seat, serial uint32, edges uint32) error {
 	return p.Context().SendRequest(p, 6, seat, serial, edges)
 }
 
 // SetMaxSize will set the maximum size.
 //
-//
 // Set a maximum size for the window.
 //
 // The client can specify a maximum size so that the compositor does
@@ -736,14 +912,12 @@ func (p *Toplevel) Resize(seat *wl.Seat, serial uint32, edges uint32) error {
 // The width and height must be greater than or equal to zero. Using
 // strictly negative values for width and height will result in a
 // protocol error.
-//
 func (p *Toplevel) SetMaxSize(width int32, height int32) error {
 	return p.Context().SendRequest(p, 7, width, height)
 }
 
 // SetMinSize will set the minimum size.
 //
-//
 // Set a minimum size for the window.
 //
 // The client can specify a minimum size so that the compositor does
@@ -778,14 +952,12 @@ func (p *Toplevel) SetMaxSize(width int32, height int32) error {
 // The width and height must be greater than or equal to zero. Using
 // strictly negative values for width and height will result in a
 // protocol error.
-//
 func (p *Toplevel) SetMinSize(width int32, height int32) error {
 	return p.Context().SendRequest(p, 8, width, height)
 }
 
 // SetMaximized will maximize the window.
 //
-//
 // Maximize the surface.
 //
 // After requesting that the surface should be maximized, the compositor
@@ -802,14 +974,12 @@ func (p *Toplevel) SetMinSize(width int32, height int32) error {
 //
 // If the surface was already maximized, the compositor will still emit
 // a configure event with the "maximized" state.
-//
 func (p *Toplevel) SetMaximized() error {
 	return p.Context().SendRequest(p, 9)
 }
 
 // UnsetMaximized will unmaximize the window.
 //
-//
 // Unmaximize the surface.
 //
 // After requesting that the surface should be unmaximized, the compositor
@@ -827,14 +997,12 @@ func (p *Toplevel) SetMaximized() error {
 //
 // If the surface was already not maximized, the compositor will still
 // emit a configure event without the "maximized" state.
-//
 func (p *Toplevel) UnsetMaximized() error {
 	return p.Context().SendRequest(p, 10)
 }
 
 // SetFullscreen will set the window as fullscreen on a monitor.
 //
-//
 // Make the surface fullscreen.
 //
 // You can specify an output that you would prefer to be fullscreen.
@@ -844,21 +1012,17 @@ func (p *Toplevel) UnsetMaximized() error {
 // If the surface doesn't cover the whole output, the compositor will
 // position the surface in the center of the output and compensate with
 // black borders filling the rest of the output.
-//
 func (p *Toplevel) SetFullscreen(output *wl.Output) error {
-	return p.Context().SendRequest(p, 11, output)
+	return p.Context().SendRequest(p, 11, wl.NullableProxy(output))
 }
 
 // UnsetFullscreen will .
-//
-//
 func (p *Toplevel) UnsetFullscreen() error {
 	return p.Context().SendRequest(p, 12)
 }
 
 // SetMinimized will set the window as minimized.
 //
-//
 // Request that the compositor minimize your surface. There is no
 // way to know if the surface is currently minimized, nor is there
 // any way to unset minimization on this surface.
@@ -867,11 +1031,12 @@ func (p *Toplevel) UnsetFullscreen() error {
 // instead use the wl_surface.frame event for this, as this will
 // also work with live previews on windows in Alt-Tab, Expose or
 // similar compositor features.
-//
 func (p *Toplevel) SetMinimized() error {
 	return p.Context().SendRequest(p, 13)
 }
 
+// Values of the resize_edge enum of zxdg_toplevel_v6, for the edges
+// argument of zxdg_toplevel_v6.resize.
 const (
 	ToplevelResizeEdgeNone        = 0
 	ToplevelResizeEdgeTop         = 1
@@ -884,6 +1049,9 @@ const (
 	ToplevelResizeEdgeBottomRight = 10
 )
 
+// ToplevelState is the state enum of zxdg_toplevel_v6.
+type ToplevelState uint32
+
 const (
 	ToplevelStateMaximized  = 1
 	ToplevelStateFullscreen = 2
@@ -917,12 +1085,28 @@ func (p *Popup) RemoveConfigureHandler(h PopupConfigureHandler) {
 
 	for i, e := range p.configureHandlers {
 		if e == h {
-			p.configureHandlers = append(p.configureHandlers[:i], p.configureHandlers[i+1:]...)
+			// copied, as Dispatch may be iterating over the old slice
+			p.configureHandlers = append(p.configureHandlers[:i:i], p.configureHandlers[i+1:]...)
 			break
 		}
 	}
 }
 
+type popupConfigureFunc struct {
+	f func(PopupConfigureEvent)
+}
+
+func (h *popupConfigureFunc) HandlePopupConfigure(ev PopupConfigureEvent) {
+	h.f(ev)
+}
+
+// OnConfigure calls f for every configure event, until cancel is called.
+func (p *Popup) OnConfigure(f func(PopupConfigureEvent)) (cancel func()) {
+	h := &popupConfigureFunc{f}
+	p.AddConfigureHandler(h)
+	return func() { p.RemoveConfigureHandler(h) }
+}
+
 type PopupPopupDoneEvent struct {
 	EventContext context.Context
 }
@@ -945,37 +1129,55 @@ func (p *Popup) RemovePopupDoneHandler(h PopupPopupDoneHandler) {
 
 	for i, e := range p.popupDoneHandlers {
 		if e == h {
-			p.popupDoneHandlers = append(p.popupDoneHandlers[:i], p.popupDoneHandlers[i+1:]...)
+			// copied, as Dispatch may be iterating over the old slice
+			p.popupDoneHandlers = append(p.popupDoneHandlers[:i:i], p.popupDoneHandlers[i+1:]...)
 			break
 		}
 	}
 }
 
+type popupPopupDoneFunc struct {
+	f func(PopupPopupDoneEvent)
+}
+
+func (h *popupPopupDoneFunc) HandlePopupPopupDone(ev PopupPopupDoneEvent) {
+	h.f(ev)
+}
+
+// OnPopupDone calls f for every popup_done event, until cancel is called.
+func (p *Popup) OnPopupDone(f func(PopupPopupDoneEvent)) (cancel func()) {
+	h := &popupPopupDoneFunc{f}
+	p.AddPopupDoneHandler(h)
+	return func() { p.RemovePopupDoneHandler(h) }
+}
+
 func (p *Popup) Dispatch(ctx context.Context, event *wl.Event) {
 	switch event.Opcode {
 	case 0:
-		if len(p.configureHandlers) > 0 {
+		p.mu.RLock()
+		handlers := p.configureHandlers
+		p.mu.RUnlock()
+		if len(handlers) > 0 {
 			ev := PopupConfigureEvent{}
 			ev.EventContext = ctx
 			ev.X = event.Int32()
 			ev.Y = event.Int32()
 			ev.Width = event.Int32()
 			ev.Height = event.Int32()
-			p.mu.RLock()
-			for _, h := range p.configureHandlers {
+			for _, h := range handlers {
 				h.HandlePopupConfigure(ev)
 			}
-			p.mu.RUnlock()
 		}
 	case 1:
-		if len(p.popupDoneHandlers) > 0 {
+		p.mu.RLock()
+		handlers := p.popupDoneHandlers
+		p.mu.RUnlock()
+		if len(handlers) > 0 {
 			ev := PopupPopupDoneEvent{}
 			ev.EventContext = ctx
-			p.mu.RLock()
-			for _, h := range p.popupDoneHandlers {
+			for _, h := range handlers {
 				h.HandlePopupPopupDone(ev)
 			}
-			p.mu.RUnlock()
 		}
 	}
 }
@@ -993,22 +1195,45 @@ func NewPopup(ctx *wl.Context) *Popup {
 	return ret
 }
 
+var popupInterface = &wl.Interface{
+	Name:    "zxdg_popup_v6",
+	Version: 1,
+	New:     func() wl.Proxy { return new(Popup) },
+	Requests: []wl.Message{
+		{Name: "destroy"},
+		{Name: "grab", Signature: "ou", Types: []string{"wl_seat", ""}},
+	},
+	Events: []wl.Message{
+		{Name: "configure", Signature: "iiii"},
+		{Name: "popup_done"},
+	},
+	Errors: map[uint32]string{
+		PopupErrorInvalidGrab: "invalid_grab",
+	},
+}
+
+// Interface returns the description of the zxdg_popup_v6 interface.
+func (p *Popup) Interface() *wl.Interface {
+	return popupInterface
+}
+
 // Destroy will remove xdg_popup interface.
 //
-//
 // This destroys the popup. Explicitly destroying the xdg_popup
 // object will also dismiss the popup, and unmap the surface.
 //
 // If this xdg_popup is not the "topmost" popup, a protocol error
 // will be sent.
-//
 func (p *Popup) Destroy() error {
-	return p.Context().SendRequest(p, 0)
+	if err := p.Context().SendRequest(p, 0); err != nil {
+		return err
+	}
+	p.Context().Unregister(p)
+	return nil
 }
 
 // Grab will make the popup take an explicit grab.
 //
-//
 // This request makes the created popup take an explicit grab. An explicit
 // grab will be dismissed when the user dismisses the popup, or when the
 // client destroys the xdg_popup. This can be done by the user clicking
@@ -1050,11 +1275,19 @@ func (p *Popup) Destroy() error {
 // and touch events for all their surfaces as normal (similar to an
 // "owner-events" grab in X11 parlance), while the top most grabbing popup
 // will always have keyboard focus.
-//
 func (p *Popup) Grab(seat *wl.Seat, serial uint32) error {
 	return p.Context().SendRequest(p, 1, seat, serial)
 }
 
+// Error codes of zxdg_popup_v6, reported with wl_display.error.
 const (
 	PopupErrorInvalidGrab = 0
 )
+
+func init() {
+	wl.RegisterInterface(shellInterface)
+	wl.RegisterInterface(positionerInterface)
+	wl.RegisterInterface(surfaceInterface)
+	wl.RegisterInterface(toplevelInterface)
+	wl.RegisterInterface(popupInterface)
+}
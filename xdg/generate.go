@@ -0,0 +1,3 @@
+package xdg
+
+//go:generate go run ../cmd/wl-scanner -pkg xdg -prefix xdg_ -o shell.go ../protocol/xdg-shell.xml